@@ -33,5 +33,9 @@ func (ai *authInteractor) Login(userID, password string) error {
 		return errors.Wrap(err, "failed to verify password")
 	}
 
+	// 退会の猶予期間中にログインしたら取り消す
+	if _, err = ai.UserService.CancelDeletion(user); err != nil {
+		return errors.Wrap(err, "failed to cancel deletion")
+	}
 	return nil
 }
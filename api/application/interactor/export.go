@@ -0,0 +1,172 @@
+package interactor
+
+import (
+	"app/api/domain/entity"
+	"app/api/domain/service"
+
+	"github.com/pkg/errors"
+)
+
+type ExportInteractor interface {
+	Create(requestUserID, threadID string, isPublic int, password string) (*entity.Export, error)
+	Build(exportID, password string) (*entity.Export, error)
+	GetByThreadID(requestUserID, threadID string) ([]*entity.Export, error)
+	CheckDownloadable(requestUserID, threadID, exportID, password string) error
+	Download(exportID string) ([]byte, error)
+}
+
+type exportInteractor struct {
+	exportService  service.ExportService
+	threadService  service.ThreadService
+	messageService service.MessageService
+	userService    service.UserService
+	authService    service.AuthService
+}
+
+func NewExportInteractor(es service.ExportService, ts service.ThreadService, ms service.MessageService, us service.UserService, as service.AuthService) ExportInteractor {
+	return &exportInteractor{
+		exportService:  es,
+		threadService:  ts,
+		messageService: ms,
+		userService:    us,
+		authService:    as,
+	}
+}
+
+// Create passwordはbcryptのハッシュにして保存する。zipの中身はBuildで作る
+func (ei *exportInteractor) Create(requestUserID, threadID string, isPublic int, password string) (*entity.Export, error) {
+	thread, err := ei.threadService.GetByID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get thread")
+	}
+	creator, err := ei.userService.GetByUserID(requestUserID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user")
+	}
+	var hash string
+	if password != "" {
+		if hash, err = ei.authService.PasswordEncrypt(password); err != nil {
+			return nil, errors.Wrap(err, "failed to encrypt password")
+		}
+	}
+	export, err := ei.exportService.New(thread, creator, isPublic, hash)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create export")
+	}
+	return export, nil
+}
+
+// Build 失敗したらfailedにする。zipの暗号化にはハッシュにする前のpasswordを使う
+func (ei *exportInteractor) Build(exportID, password string) (*entity.Export, error) {
+	export, err := ei.exportService.GetByID(exportID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get export")
+	}
+	if err = ei.build(export, password); err != nil {
+		if ferr := ei.exportService.Fail(export); ferr != nil {
+			return export, errors.Wrapf(ferr, "failed to mark export as failed (%v)", err)
+		}
+		return export, err
+	}
+	return export, nil
+}
+
+func (ei *exportInteractor) build(export *entity.Export, password string) error {
+	thread, err := ei.threadService.GetByID(export.Thread.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get thread")
+	}
+	export.Thread = thread
+	if export.CreatedBy, err = ei.userService.GetByID(export.CreatedBy.ID); err != nil {
+		return errors.Wrap(err, "failed to get user")
+	}
+	members, err := ei.threadService.GetMembersByThreadID(thread.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get members")
+	}
+	roles, err := ei.threadService.GetRoles(thread.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get roles")
+	}
+	messages, err := ei.messageService.GetAllByThreadID(thread.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get messages")
+	}
+	if err = ei.exportService.Build(export, password, members, roles, messages); err != nil {
+		return errors.Wrap(err, "failed to build export")
+	}
+	return nil
+}
+
+// GetByThreadID 非公開の書き出しは書き出しの権限があるメンバーにだけ返す
+func (ei *exportInteractor) GetByThreadID(requestUserID, threadID string) ([]*entity.Export, error) {
+	user, err := ei.userService.GetByUserID(requestUserID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user")
+	}
+	role, err := ei.threadService.GetRole(threadID, user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get role")
+	}
+	if role == "" {
+		return nil, errors.New("not member of thread")
+	}
+	exports, err := ei.exportService.GetByThreadID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get exports")
+	}
+	var visible []*entity.Export
+	for _, export := range exports {
+		if export.IsPublic == 0 && !role.Can(entity.PermissionExport) {
+			continue
+		}
+		if export.CreatedBy, err = ei.userService.GetByID(export.CreatedBy.ID); err != nil {
+			return nil, errors.Wrap(err, "failed to get user")
+		}
+		visible = append(visible, export)
+	}
+	return visible, nil
+}
+
+// CheckDownloadable メンバーでなければダウンロードできない。パスワード付きならハッシュと照合する
+func (ei *exportInteractor) CheckDownloadable(requestUserID, threadID, exportID, password string) error {
+	user, err := ei.userService.GetByUserID(requestUserID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get user")
+	}
+	role, err := ei.threadService.GetRole(threadID, user.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get role")
+	}
+	if role == "" {
+		return errors.New("not member of thread")
+	}
+	export, err := ei.exportService.GetByID(exportID)
+	if err != nil || export.Thread.ID != threadID {
+		return errors.New("export is not found")
+	}
+	if export.IsPublic == 0 && !role.Can(entity.PermissionExport) {
+		return errors.New("no permission to " + string(entity.PermissionExport))
+	}
+	if export.Status != entity.ExportReady {
+		return errors.New("export is " + string(export.Status))
+	}
+	if export.IsProtected() {
+		if err = ei.authService.VerifyPassword(export.Password, password); err != nil {
+			return errors.New("password is wrong")
+		}
+	}
+	return nil
+}
+
+func (ei *exportInteractor) Download(exportID string) ([]byte, error) {
+	export, err := ei.exportService.GetByID(exportID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get export")
+	}
+	file, err := ei.exportService.Load(export)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load export")
+	}
+	return file, nil
+}
@@ -0,0 +1,214 @@
+package interactor
+
+import (
+	"app/api/domain/entity"
+	"app/api/domain/service"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type InviteInteractor interface {
+	Create(requestUserID, threadID, inviteeUserID string, maxUses int, expiresAt *time.Time) (*entity.ThreadInvite, error)
+	GetByCode(code string) (*entity.ThreadInvite, error)
+	GetByThreadID(threadID string) ([]*entity.ThreadInvite, error)
+	GetForUser(userID string) ([]*entity.ThreadInvite, error)
+	Revoke(threadID, inviteID string) (bool, error)
+	CheckJoinable(code, userID string) error
+	Join(code, userID string) (*entity.Thread, *entity.WaitlistEntry, error)
+}
+
+type inviteInteractor struct {
+	inviteService service.InviteService
+	threadService service.ThreadService
+	userService   service.UserService
+}
+
+func NewInviteInteractor(is service.InviteService, ts service.ThreadService, us service.UserService) InviteInteractor {
+	return &inviteInteractor{
+		inviteService: is,
+		threadService: ts,
+		userService:   us,
+	}
+}
+
+// Create inviteeUserIDが空なら誰でも使える招待コードを作る
+func (ii *inviteInteractor) Create(requestUserID, threadID, inviteeUserID string, maxUses int, expiresAt *time.Time) (*entity.ThreadInvite, error) {
+	thread, err := ii.threadService.GetByID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get thread")
+	}
+	creator, err := ii.userService.GetByUserID(requestUserID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user")
+	}
+	var invitee *entity.User
+	if inviteeUserID != "" {
+		if invitee, err = ii.userService.GetByUserID(inviteeUserID); err != nil {
+			return nil, errors.Wrap(err, "failed to get invitee")
+		}
+	}
+	invite, err := ii.inviteService.New(thread, creator, invitee, maxUses, expiresAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create invite")
+	}
+	return invite, nil
+}
+
+// GetByCode 招待リンクを開いたときに表示するスレッドも詰める
+func (ii *inviteInteractor) GetByCode(code string) (*entity.ThreadInvite, error) {
+	invite, err := ii.inviteService.GetByCode(code)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get invite")
+	}
+	if err = ii.fillInvite(invite); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+func (ii *inviteInteractor) GetByThreadID(threadID string) ([]*entity.ThreadInvite, error) {
+	invites, err := ii.inviteService.GetByThreadID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get invites")
+	}
+	for _, invite := range invites {
+		if err = ii.fillInvite(invite); err != nil {
+			return nil, err
+		}
+		for _, use := range invite.UsedBy {
+			if use.User, err = ii.userService.GetByID(use.User.ID); err != nil {
+				return nil, errors.Wrap(err, "failed to get user")
+			}
+		}
+	}
+	return invites, nil
+}
+
+func (ii *inviteInteractor) GetForUser(userID string) ([]*entity.ThreadInvite, error) {
+	user, err := ii.userService.GetByUserID(userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user")
+	}
+	invites, err := ii.inviteService.GetForUser(user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get invites")
+	}
+	for _, invite := range invites {
+		if err = ii.fillInvite(invite); err != nil {
+			return nil, err
+		}
+	}
+	return invites, nil
+}
+
+// Revoke 他のスレッドの招待は取り消せない
+func (ii *inviteInteractor) Revoke(threadID, inviteID string) (bool, error) {
+	invite, err := ii.inviteService.GetByID(inviteID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get invite")
+	}
+	if invite.Thread.ID != threadID {
+		return false, errors.New("invite is not for this thread")
+	}
+	revoked, err := ii.inviteService.Revoke(invite.ID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to revoke invite")
+	}
+	return revoked, nil
+}
+
+// CheckJoinable 招待が使えて、userIDのユーザがまだメンバーでも参加待ちでもなく、BANもされていないか
+func (ii *inviteInteractor) CheckJoinable(code, userID string) error {
+	_, _, err := ii.getJoinable(code, userID)
+	return err
+}
+
+// Join 招待を使ってスレッドに参加する。満員なら参加待ちを作って返す
+func (ii *inviteInteractor) Join(code, userID string) (*entity.Thread, *entity.WaitlistEntry, error) {
+	invite, user, err := ii.getJoinable(code, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry, err := ii.inviteService.Use(invite, user)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to use invite")
+	}
+	if entry != nil {
+		waiting, err := ii.threadService.GetWaitlistEntry(invite.Thread.ID, user.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if waiting != nil {
+			entry.Position = waiting.Position
+		}
+	}
+	return invite.Thread, entry, nil
+}
+
+func (ii *inviteInteractor) getJoinable(code, userID string) (*entity.ThreadInvite, *entity.User, error) {
+	invite, err := ii.inviteService.GetByCode(code)
+	if err != nil {
+		return nil, nil, errors.New("invite is not found")
+	}
+	user, err := ii.userService.GetByUserID(userID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get user")
+	}
+	if !invite.IsFor(user) {
+		return nil, nil, errors.New("invite is for another user")
+	}
+	if !invite.IsUsable(time.Now()) {
+		return nil, nil, errors.New("invite is no longer valid")
+	}
+	role, err := ii.threadService.GetRole(invite.Thread.ID, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if role != "" {
+		return nil, nil, errors.New("already member of thread")
+	}
+	banned, err := ii.threadService.IsBanned(invite.Thread.ID, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if banned {
+		return nil, nil, errors.New("user is banned from thread")
+	}
+	waiting, err := ii.threadService.GetWaitlistEntry(invite.Thread.ID, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if waiting != nil {
+		return nil, nil, errors.New("already on waitlist")
+	}
+	thread, err := ii.threadService.GetByID(invite.Thread.ID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get thread")
+	}
+	if thread.IsArchived() {
+		return nil, nil, errors.New("thread is archived")
+	}
+	invite.Thread = thread
+	return invite, user, nil
+}
+
+func (ii *inviteInteractor) fillInvite(invite *entity.ThreadInvite) error {
+	thread, err := ii.threadService.GetByID(invite.Thread.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get thread")
+	}
+	if thread.Author, err = ii.userService.GetByID(thread.Author.ID); err != nil {
+		return errors.Wrap(err, "failed to get author")
+	}
+	invite.Thread = thread
+	if invite.CreatedBy, err = ii.userService.GetByID(invite.CreatedBy.ID); err != nil {
+		return errors.Wrap(err, "failed to get user")
+	}
+	if invite.Invitee != nil {
+		if invite.Invitee, err = ii.userService.GetByID(invite.Invitee.ID); err != nil {
+			return errors.Wrap(err, "failed to get user")
+		}
+	}
+	return nil
+}
@@ -1,17 +1,36 @@
 package interactor
 
 import (
+	"app/api/constants"
 	"app/api/domain/entity"
 	"app/api/domain/service"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 type MessageInteractor interface {
-	Create(message string, grade int, authorID string, threadID string) (*entity.Message, error)
+	Create(message string, grade int, authorID string, threadID string, parentID string) (*entity.Message, error)
+	GetReplyChain(threadID, messageID, userID string) (*entity.ReplyChain, error)
 	GetByID(id string) (*entity.Message, error)
-	GetByThreadID(threadID string) ([]*entity.Message, error)
-	AddFavorite(messageID, userID string) error
+	CheckEditable(threadID, messageID, userID string) error
+	Edit(threadID, messageID, userID, body string) (*entity.Message, error)
+	GetRevisions(threadID, messageID string) ([]*entity.MessageRevision, error)
+	CheckDeletable(threadID, messageID, userID string) error
+	Delete(threadID, messageID, userID, reason string) (*entity.Message, error)
+	GetDeletions(threadID string) ([]*entity.MessageDeletion, error)
+	GetByThreadID(threadID, userID string, before, after *entity.MessageCursor, limit int) (*entity.MessagePage, error)
+	GetMissed(threadID, lastMessageID string, since *time.Time, limit int) ([]*entity.Message, bool, error)
+	AddReaction(threadID, messageID, userID, reaction string) (*entity.Message, bool, error)
+	RemoveReaction(threadID, messageID, userID, reaction string) (*entity.Message, bool, error)
+	MarkAsRead(threadID, messageID, userID string) (*entity.ReadMarker, bool, error)
+	GetReadMarkers(threadID string) ([]*entity.ReadMarker, error)
+	GetUnreadCounts(userID string) (map[string]*entity.UnreadCount, error)
+	Pin(threadID, messageID, userID string) (*entity.Pin, bool, error)
+	Unpin(threadID, messageID, userID string) (bool, error)
+	GetPins(threadID string) ([]*entity.Pin, error)
+	Search(userID string, query *entity.MessageSearchQuery) (*entity.MessageSearchResult, error)
+	GetMentions(userID string, before *entity.MessageCursor, limit int) ([]*entity.Message, *entity.MessageCursor, error)
 }
 
 type messageInteractor struct {
@@ -28,7 +47,8 @@ func NewMessageInteractor(ms service.MessageService, ts service.ThreadService, u
 	}
 }
 
-func (mi *messageInteractor) Create(message string, grade int, authorID string, threadID string) (*entity.Message, error) {
+// Create parentIDが空でなければそのメッセージへの返信にする
+func (mi *messageInteractor) Create(message string, grade int, authorID string, threadID string, parentID string) (*entity.Message, error) {
 	thread, err := mi.threadService.GetByID(threadID)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to find thread")
@@ -37,7 +57,13 @@ func (mi *messageInteractor) Create(message string, grade int, authorID string,
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to find user")
 	}
-	msg, err := mi.messageService.New(message, grade, author, thread)
+	var parent *entity.Message
+	if parentID != "" {
+		if parent, err = mi.messageService.GetByID(parentID); err != nil {
+			return nil, errors.Wrap(err, "failed to find parent message")
+		}
+	}
+	msg, err := mi.messageService.New(message, grade, author, thread, parent)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create message")
 	}
@@ -66,22 +92,372 @@ func (mi *messageInteractor) GetByID(id string) (*entity.Message, error) {
 	return message, nil
 }
 
-func (mi *messageInteractor) GetByThreadID(threadID string) ([]*entity.Message, error) {
-	messages, err := mi.messageService.GetByThreadID(threadID)
+func (mi *messageInteractor) GetReplyChain(threadID, messageID, userID string) (*entity.ReplyChain, error) {
+	message, user, err := mi.getInThread(threadID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := mi.messageService.GetReplyChain(message, constants.MessagePageSizeMax)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get reply chain")
+	}
+	messages := append(append([]*entity.Message{chain.Message}, chain.Ancestors...), chain.Replies...)
+	if err = mi.messageService.AttachReactions(messages, user.ID); err != nil {
+		return nil, errors.Wrap(err, "failed to get reactions")
+	}
+	return chain, nil
+}
+
+// CheckEditable userIDのユーザがメッセージを編集できなければ理由をerrorで返す
+func (mi *messageInteractor) CheckEditable(threadID, messageID, userID string) error {
+	_, _, err := mi.getEditable(threadID, messageID, userID)
+	return err
+}
+
+func (mi *messageInteractor) Edit(threadID, messageID, userID, body string) (*entity.Message, error) {
+	message, editor, err := mi.getEditable(threadID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	message, err = mi.messageService.Edit(message, body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to edit message")
+	}
+	message.Author = editor
+	thread, err := mi.threadService.GetByID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get thread")
+	}
+	message.Thread = thread
+	return message, nil
+}
+
+func (mi *messageInteractor) getEditable(threadID, messageID, userID string) (*entity.Message, *entity.User, error) {
+	editor, err := mi.userService.GetByUserID(userID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get user")
+	}
+	message, err := mi.messageService.GetByID(messageID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get message")
+	}
+	if message.Thread.ID != threadID {
+		return nil, nil, errors.New("message is not in thread")
+	}
+	if err = mi.messageService.CheckEditable(message, editor); err != nil {
+		return nil, nil, err
+	}
+	return message, editor, nil
+}
+
+// CheckDeletable userIDのユーザがメッセージを削除できなければ理由をerrorで返す
+func (mi *messageInteractor) CheckDeletable(threadID, messageID, userID string) error {
+	_, _, err := mi.getDeletable(threadID, messageID, userID)
+	return err
+}
+
+func (mi *messageInteractor) Delete(threadID, messageID, userID, reason string) (*entity.Message, error) {
+	message, user, err := mi.getDeletable(threadID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	message, err = mi.messageService.Delete(message, user, reason)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to delete message")
+	}
+	return message, nil
+}
+
+func (mi *messageInteractor) getDeletable(threadID, messageID, userID string) (*entity.Message, *entity.User, error) {
+	user, err := mi.userService.GetByUserID(userID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get user")
+	}
+	message, err := mi.messageService.GetByID(messageID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get message")
+	}
+	if message.Thread.ID != threadID {
+		return nil, nil, errors.New("message is not in thread")
+	}
+	isAdmin, err := mi.threadService.IsAdmin(threadID, user.ID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get member")
+	}
+	if err = mi.messageService.CheckDeletable(message, user, isAdmin); err != nil {
+		return nil, nil, err
+	}
+	return message, user, nil
+}
+
+func (mi *messageInteractor) GetDeletions(threadID string) ([]*entity.MessageDeletion, error) {
+	deletions, err := mi.messageService.GetDeletionsByThreadID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get deletions")
+	}
+	for _, deletion := range deletions {
+		author, err := mi.userService.GetByID(deletion.Author.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get author")
+		}
+		deletedBy, err := mi.userService.GetByID(deletion.DeletedBy.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get user")
+		}
+		deletion.Author = author
+		deletion.Message.Author = author
+		deletion.DeletedBy = deletedBy
+	}
+	return deletions, nil
+}
+
+func (mi *messageInteractor) GetRevisions(threadID, messageID string) ([]*entity.MessageRevision, error) {
+	message, err := mi.messageService.GetByID(messageID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get message")
+	}
+	if message.Thread.ID != threadID {
+		return nil, errors.New("message is not in thread")
+	}
+	revisions, err := mi.messageService.GetRevisions(messageID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get revisions")
+	}
+	return revisions, nil
+}
+
+// GetByThreadID userIDはリアクションしたかの判定に使う
+func (mi *messageInteractor) GetByThreadID(threadID, userID string, before, after *entity.MessageCursor, limit int) (*entity.MessagePage, error) {
+	user, err := mi.userService.GetByUserID(userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user")
+	}
+	page, err := mi.messageService.GetPageByThreadID(threadID, before, after, limit)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get messages")
 	}
-	return messages, nil
+	if err = mi.messageService.AttachReactions(page.Messages, user.ID); err != nil {
+		return nil, errors.Wrap(err, "failed to get reactions")
+	}
+	return page, nil
+}
+
+// GetMissed lastMessageIDかsince以降に作成されたメッセージを古い順に返す
+// limit件を超える場合は新しいlimit件だけを返し、truncatedをtrueにする
+func (mi *messageInteractor) GetMissed(threadID, lastMessageID string, since *time.Time, limit int) ([]*entity.Message, bool, error) {
+	var cursorID string
+	cursorAt := since
+	if lastMessageID != "" {
+		last, err := mi.messageService.GetByID(lastMessageID)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to get last message")
+		}
+		if last.Thread.ID != threadID {
+			return nil, false, errors.New("last message is not in thread")
+		}
+		cursorID = last.ID
+		cursorAt = last.CreatedAt
+	}
+	if cursorAt == nil {
+		return nil, false, errors.New("last message id or timestamp is required")
+	}
+
+	messages, err := mi.messageService.GetLatestByThreadIDAfter(threadID, cursorAt, cursorID, limit+1)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to get messages")
+	}
+	truncated := len(messages) > limit
+	if truncated {
+		messages = messages[1:]
+	}
+	return messages, truncated, nil
 }
 
-func (mi *messageInteractor) AddFavorite(messageID, userID string) error {
+// AddReaction 追加した場合はtrueと、集計を付けたメッセージを返す
+func (mi *messageInteractor) AddReaction(threadID, messageID, userID, reaction string) (*entity.Message, bool, error) {
+	message, user, err := mi.getInThread(threadID, messageID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	added, err := mi.messageService.AddReaction(message, user, reaction)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to add reaction")
+	}
+	if err = mi.messageService.AttachReactions([]*entity.Message{message}, user.ID); err != nil {
+		return nil, false, errors.Wrap(err, "failed to get reactions")
+	}
+	return message, added, nil
+}
+
+// RemoveReaction 削除した場合はtrueと、集計を付けたメッセージを返す
+func (mi *messageInteractor) RemoveReaction(threadID, messageID, userID, reaction string) (*entity.Message, bool, error) {
+	message, user, err := mi.getInThread(threadID, messageID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	removed, err := mi.messageService.RemoveReaction(message, user, reaction)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to remove reaction")
+	}
+	if err = mi.messageService.AttachReactions([]*entity.Message{message}, user.ID); err != nil {
+		return nil, false, errors.Wrap(err, "failed to get reactions")
+	}
+	return message, removed, nil
+}
+
+func (mi *messageInteractor) getInThread(threadID, messageID, userID string) (*entity.Message, *entity.User, error) {
+	user, err := mi.userService.GetByUserID(userID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get user")
+	}
+	message, err := mi.messageService.GetByID(messageID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get message")
+	}
+	if message.Thread.ID != threadID {
+		return nil, nil, errors.New("message is not in thread")
+	}
+	return message, user, nil
+}
+
+// MarkAsRead 既読位置を進めた場合はtrueを返す
+func (mi *messageInteractor) MarkAsRead(threadID, messageID, userID string) (*entity.ReadMarker, bool, error) {
 	user, err := mi.userService.GetByUserID(userID)
 	if err != nil {
-		return errors.Wrap(err, "failed to get user")
+		return nil, false, errors.Wrap(err, "failed to get user")
+	}
+	message, err := mi.messageService.GetByID(messageID)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to get message")
+	}
+	if message.Thread.ID != threadID {
+		return nil, false, errors.New("message is not in thread")
+	}
+	marker, updated, err := mi.messageService.MarkAsRead(user, message)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to mark as read")
+	}
+	return marker, updated, nil
+}
+
+func (mi *messageInteractor) GetReadMarkers(threadID string) ([]*entity.ReadMarker, error) {
+	markers, err := mi.messageService.GetReadMarkersByThreadID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get read markers")
+	}
+	for _, marker := range markers {
+		user, err := mi.userService.GetByID(marker.User.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get user")
+		}
+		marker.User = user
+	}
+	return markers, nil
+}
+
+func (mi *messageInteractor) GetUnreadCounts(userID string) (map[string]*entity.UnreadCount, error) {
+	user, err := mi.userService.GetByUserID(userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user")
+	}
+	counts, err := mi.messageService.GetUnreadCounts(user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get unread counts")
+	}
+	return counts, nil
+}
+
+// GetMentions userIDのユーザがメンションされたメッセージを新しい順に返す
+func (mi *messageInteractor) GetMentions(userID string, before *entity.MessageCursor, limit int) ([]*entity.Message, *entity.MessageCursor, error) {
+	user, err := mi.userService.GetByUserID(userID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get user")
+	}
+	messages, next, err := mi.messageService.GetMentioned(user.ID, before, limit)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get mentions")
+	}
+	if err = mi.messageService.AttachReactions(messages, user.ID); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get reactions")
+	}
+	return messages, next, nil
+}
+
+// Pin 管理者かどうかは呼び出し側で確認する
+func (mi *messageInteractor) Pin(threadID, messageID, userID string) (*entity.Pin, bool, error) {
+	message, user, err := mi.getInThread(threadID, messageID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	pin, added, err := mi.messageService.Pin(message, user)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to pin message")
+	}
+	return pin, added, nil
+}
+
+func (mi *messageInteractor) Unpin(threadID, messageID, userID string) (bool, error) {
+	message, _, err := mi.getInThread(threadID, messageID, userID)
+	if err != nil {
+		return false, err
+	}
+	removed, err := mi.messageService.Unpin(message)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to unpin message")
+	}
+	return removed, nil
+}
+
+func (mi *messageInteractor) GetPins(threadID string) ([]*entity.Pin, error) {
+	pins, err := mi.messageService.GetPinsByThreadID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get pins")
+	}
+	for _, pin := range pins {
+		pinnedBy, err := mi.userService.GetByID(pin.PinnedBy.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get user")
+		}
+		pin.PinnedBy = pinnedBy
+	}
+	return pins, nil
+}
+
+// Search query.ThreadIDsとquery.AuthorIDはリクエストのまま(スレッドIDとuser_id)受け取り、
+// userIDのユーザが参加しているスレッドに絞ってから検索する
+func (mi *messageInteractor) Search(userID string, query *entity.MessageSearchQuery) (*entity.MessageSearchResult, error) {
+	user, err := mi.userService.GetByUserID(userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user")
+	}
+	threads, err := mi.threadService.GetByUserID(user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get threads")
+	}
+	requested := make(map[string]bool, len(query.ThreadIDs))
+	for _, threadID := range query.ThreadIDs {
+		requested[threadID] = true
+	}
+	var threadIDs []string
+	for _, thread := range threads {
+		if len(requested) == 0 || requested[thread.ID] {
+			threadIDs = append(threadIDs, thread.ID)
+		}
+	}
+	if len(requested) > 0 && len(threadIDs) == 0 {
+		return nil, errors.New("not member of thread")
+	}
+	query.ThreadIDs = threadIDs
+	if query.AuthorID != "" {
+		author, err := mi.userService.GetByUserID(query.AuthorID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get author")
+		}
+		query.AuthorID = author.ID
 	}
-	err = mi.messageService.AddFavorite(messageID, user.ID)
+	result, err := mi.messageService.Search(query)
 	if err != nil {
-		return errors.Wrap(err, "failed to add favorite")
+		return nil, errors.Wrap(err, "failed to search messages")
 	}
-	return nil
+	return result, nil
 }
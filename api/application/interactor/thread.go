@@ -3,23 +3,45 @@ package interactor
 import (
 	"app/api/domain/entity"
 	"app/api/domain/service"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 type ThreadInteractor interface {
-	Create(name, description string, limitUsers, isPublic int, authorID string) (*entity.Thread, error)
+	Create(name, description string, limitUsers, isPublic int, joinPolicy entity.JoinPolicy, authorID string) (*entity.Thread, error)
 	GetAll() ([]*entity.Thread, error)
 	GetByID(id string) (*entity.Thread, error)
 	GetByUserID(userID string) ([]*entity.Thread, error)
 	GetOnlyPublic() ([]*entity.Thread, error)
+	Search(userID string, query *entity.ThreadSearchQuery) (*entity.ThreadSearchResult, error)
+	GetRecommendations(userID string, limit int) ([]*entity.ThreadRecommendation, error)
 	GetMembersByThreadID(id string) ([]*entity.User, error)
-	Update(id, name, description string, limitUsers, isPublic int) (*entity.Thread, error)
+	Update(id, name, description string, limitUsers, isPublic int, joinPolicy entity.JoinPolicy) (*entity.Thread, error)
 	Delete(id string) error
-	AddMember(threadID, userID string) error
-	RemoveMember(threadID, userID string) error
-	ForceToLeave(requestUserID, threadID, leavedUserID string) error
+	Archive(id string) (*entity.Thread, bool, error)
+	Restore(id string) (bool, error)
+	CheckWritable(id string) error
+	CheckJoinable(threadID, userID string) error
+	AddMember(threadID, userID string) (*entity.JoinRequest, *entity.WaitlistEntry, error)
+	GetWaitlist(threadID string) ([]*entity.WaitlistEntry, error)
+	LeaveWaitlist(threadID, userID string) (bool, error)
+	PromoteWaitlist(threadID string) ([]*entity.User, error)
+	GetJoinRequests(threadID string, status entity.JoinRequestStatus) ([]*entity.JoinRequest, error)
+	GetJoinRequestsByUserID(userID string) ([]*entity.JoinRequest, error)
+	DecideJoinRequest(requestUserID, threadID, requestID string, approve bool) (*entity.JoinRequest, bool, error)
+	CheckLeavable(threadID, userID string) error
+	RemoveMember(threadID, userID string) (*entity.Succession, error)
+	TransferOwnership(requestUserID, threadID, targetUserID string) (*entity.Thread, *entity.User, error)
+	ForceToLeave(requestUserID, threadID, leavedUserID string, ban bool, reason string, expiresAt *time.Time) (*entity.User, error)
+	Unban(requestUserID, threadID, bannedUserID string) (bool, error)
+	CheckRemovable(requestUserID, threadID, targetUserID string) error
+	GetBans(threadID string) ([]*entity.ThreadBan, error)
 	IsParticipated(string, string) bool
+	IsAdmin(threadID, userID string) bool
+	CheckPermission(threadID, userID string, permission entity.Permission) error
+	GetRoles(threadID string) (map[string]entity.Role, error)
+	ChangeRole(requestUserID, threadID, targetUserID string, role entity.Role) (*entity.User, error)
 }
 
 type threadInteractor struct {
@@ -38,17 +60,17 @@ func NewThreadInteractor(ts service.ThreadService, us service.UserService, tas s
 	}
 }
 
-func (ti *threadInteractor) Create(name, description string, limitUsers, isPublic int, authorID string) (*entity.Thread, error) {
+func (ti *threadInteractor) Create(name, description string, limitUsers, isPublic int, joinPolicy entity.JoinPolicy, authorID string) (*entity.Thread, error) {
 	author, err := ti.userService.GetByUserID(authorID)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get author")
 	}
-	thread, err := ti.threadService.New(name, description, limitUsers, isPublic, author)
+	thread, err := ti.threadService.New(name, description, limitUsers, isPublic, joinPolicy, author)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create new thread")
 	}
 	thread.Author = author
-	err = ti.threadService.AddMember(thread.ID, author.ID, 1) // TODO: めっちゃハードコーディングやん
+	err = ti.threadService.AddMember(thread.ID, author.ID, entity.RoleOwner)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to add member")
 	}
@@ -73,6 +95,63 @@ func (ti *threadInteractor) GetAll() ([]*entity.Thread, error) {
 	return result, nil
 }
 
+// Search 見つかったスレッドにだけ作成者とタグを付ける
+func (ti *threadInteractor) Search(userID string, query *entity.ThreadSearchQuery) (*entity.ThreadSearchResult, error) {
+	user, err := ti.userService.GetByUserID(userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user")
+	}
+	query.UserID = user.ID
+	result, err := ti.threadService.Search(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search threads")
+	}
+	for _, hit := range result.Hits {
+		author, err := ti.userService.GetByID(hit.Thread.Author.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get author")
+		}
+		hit.Thread.Author = author
+		threadTags, err := ti.tagService.GetByThreadID(hit.Thread.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get tags of thread")
+		}
+		hit.Thread.Tags = AddCategoryToTag(threadTags, ti.categoryService)
+	}
+	return result, nil
+}
+
+// GetRecommendations 一致したタグの説明のためにスレッドとユーザのタグを読む
+func (ti *threadInteractor) GetRecommendations(userID string, limit int) ([]*entity.ThreadRecommendation, error) {
+	user, err := ti.userService.GetByUserID(userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user")
+	}
+	recommendations, err := ti.threadService.GetRecommendations(user.ID, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get recommendations")
+	}
+	userTags, err := ti.tagService.GetByUserUUID(user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tags of user")
+	}
+	userTags = AddCategoryToTag(userTags, ti.categoryService)
+	for _, rec := range recommendations {
+		author, err := ti.userService.GetByID(rec.Thread.Author.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get author")
+		}
+		rec.Thread.Author = author
+		threadTags, err := ti.tagService.GetByThreadID(rec.Thread.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get tags of thread")
+		}
+		rec.Thread.Tags = AddCategoryToTag(threadTags, ti.categoryService)
+		ti.threadService.ExplainRecommendation(rec, userTags)
+	}
+	return recommendations, nil
+}
+
 func (ti *threadInteractor) GetByID(id string) (*entity.Thread, error) {
 	thread, err := ti.threadService.GetByID(id)
 	if err != nil {
@@ -143,7 +222,7 @@ func (ti *threadInteractor) GetMembersByThreadID(id string) ([]*entity.User, err
 	return result, nil
 }
 
-func (ti *threadInteractor) Update(id, name, description string, limitUsers, isPublic int) (*entity.Thread, error) {
+func (ti *threadInteractor) Update(id, name, description string, limitUsers, isPublic int, joinPolicy entity.JoinPolicy) (*entity.Thread, error) {
 	oldThread, err := ti.threadService.GetByID(id)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get thread")
@@ -152,7 +231,7 @@ func (ti *threadInteractor) Update(id, name, description string, limitUsers, isP
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get author")
 	}
-	thread, err := ti.threadService.Update(oldThread, name, description, limitUsers, isPublic)
+	thread, err := ti.threadService.Update(oldThread, name, description, limitUsers, isPublic, joinPolicy)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to update thread")
 	}
@@ -177,36 +256,373 @@ func (ti *threadInteractor) Delete(id string) error {
 	return nil
 }
 
-func (ti *threadInteractor) AddMember(threadID, userID string) error {
-	_, err := ti.threadService.GetByID(threadID)
+// Archive 既にアーカイブ中ならfalseを返す
+func (ti *threadInteractor) Archive(id string) (*entity.Thread, bool, error) {
+	thread, err := ti.GetByID(id)
 	if err != nil {
-		return errors.Wrap(err, "failed to get thread")
+		return nil, false, err
+	}
+	archived, err := ti.threadService.Archive(thread)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to archive thread")
+	}
+	return thread, archived, nil
+}
+
+// Restore アーカイブしていなければfalseを返す
+func (ti *threadInteractor) Restore(id string) (bool, error) {
+	thread, err := ti.threadService.GetByID(id)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get thread")
+	}
+	restored, err := ti.threadService.Restore(thread)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to restore thread")
+	}
+	return restored, nil
+}
+
+// CheckWritable 権限を問わない書き込み(自分の投稿の編集やリアクション)の前に呼ぶ
+func (ti *threadInteractor) CheckWritable(id string) error {
+	return ti.threadService.CheckWritable(id)
+}
+
+// CheckJoinable 招待なしで参加か参加申請ができるか
+func (ti *threadInteractor) CheckJoinable(threadID, userID string) error {
+	_, _, err := ti.getJoinable(threadID, userID)
+	return err
+}
+
+// AddMember スレッドの参加方針に従う。承認制なら参加申請を、満員なら参加待ちを作って返し、
+// そのまま参加したときはどちらもnilを返す。招待制のスレッドにはInviteInteractor.Joinで参加する
+func (ti *threadInteractor) AddMember(threadID, userID string) (*entity.JoinRequest, *entity.WaitlistEntry, error) {
+	thread, user, err := ti.getJoinable(threadID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if thread.JoinPolicy == entity.JoinPolicyRequest {
+		request, err := ti.threadService.RequestToJoin(thread, user)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to request to join")
+		}
+		return request, nil, nil
+	}
+	entry, err := ti.threadService.AddMemberOrWait(thread, user)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to add member")
+	}
+	return nil, entry, nil
+}
+
+func (ti *threadInteractor) getJoinable(threadID, userID string) (*entity.Thread, *entity.User, error) {
+	thread, err := ti.threadService.GetByID(threadID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get thread")
+	}
+	if thread.IsArchived() {
+		return nil, nil, errors.New("thread is archived")
+	}
+	if thread.JoinPolicy == entity.JoinPolicyInviteOnly {
+		return nil, nil, errors.New("invite is required to join this thread")
 	}
 	user, err := ti.userService.GetByUserID(userID)
 	if err != nil {
-		return errors.Wrap(err, "failed to get user")
+		return nil, nil, errors.Wrap(err, "failed to get user")
 	}
-	if err = ti.threadService.AddMember(threadID, user.ID, 0); err != nil {
-		// TODO: めっちゃハードコーディングやん
-		return errors.Wrap(err, "failed to add member")
+	role, err := ti.threadService.GetRole(threadID, user.ID)
+	if err != nil {
+		return nil, nil, err
 	}
-	return nil
+	if role != "" {
+		return nil, nil, errors.New("already member of thread")
+	}
+	banned, err := ti.threadService.IsBanned(threadID, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if banned {
+		return nil, nil, errors.New("user is banned from thread")
+	}
+	waiting, err := ti.threadService.GetWaitlistEntry(threadID, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if waiting != nil {
+		return nil, nil, errors.New("already on waitlist")
+	}
+	if thread.JoinPolicy == entity.JoinPolicyRequest {
+		pending, err := ti.threadService.GetPendingJoinRequest(threadID, user.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pending != nil {
+			return nil, nil, errors.New("already requested to join")
+		}
+	}
+	return thread, user, nil
 }
 
-func (ti *threadInteractor) RemoveMember(threadID, userID string) error {
+func (ti *threadInteractor) GetWaitlist(threadID string) ([]*entity.WaitlistEntry, error) {
+	entries, err := ti.threadService.GetWaitlist(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get waitlist")
+	}
+	for _, entry := range entries {
+		if entry.User, err = ti.userService.GetByID(entry.User.ID); err != nil {
+			return nil, errors.Wrap(err, "failed to get user")
+		}
+	}
+	return entries, nil
+}
+
+// LeaveWaitlist 参加待ちでなければfalse
+func (ti *threadInteractor) LeaveWaitlist(threadID, userID string) (bool, error) {
 	user, err := ti.userService.GetByUserID(userID)
 	if err != nil {
+		return false, errors.Wrap(err, "failed to get user")
+	}
+	removed, err := ti.threadService.LeaveWaitlist(threadID, user.ID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to leave waitlist")
+	}
+	return removed, nil
+}
+
+// PromoteWaitlist メンバーが抜けたり人数制限を変えたりした後に呼ぶ。メンバーになったユーザを返す
+func (ti *threadInteractor) PromoteWaitlist(threadID string) ([]*entity.User, error) {
+	entries, err := ti.threadService.PromoteWaitlist(threadID)
+	users := make([]*entity.User, 0, len(entries))
+	for _, entry := range entries {
+		user, err := ti.userService.GetByID(entry.User.ID)
+		if err != nil {
+			return users, errors.Wrap(err, "failed to get user")
+		}
+		users = append(users, user)
+	}
+	if err != nil {
+		return users, errors.Wrap(err, "failed to promote waitlist")
+	}
+	return users, nil
+}
+
+func (ti *threadInteractor) GetJoinRequests(threadID string, status entity.JoinRequestStatus) ([]*entity.JoinRequest, error) {
+	requests, err := ti.threadService.GetJoinRequests(threadID, status)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get join requests")
+	}
+	for _, request := range requests {
+		if err = ti.fillJoinRequest(request); err != nil {
+			return nil, err
+		}
+	}
+	return requests, nil
+}
+
+func (ti *threadInteractor) GetJoinRequestsByUserID(userID string) ([]*entity.JoinRequest, error) {
+	user, err := ti.userService.GetByUserID(userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user")
+	}
+	requests, err := ti.threadService.GetJoinRequestsByUserID(user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get join requests")
+	}
+	for _, request := range requests {
+		if err = ti.fillJoinRequest(request); err != nil {
+			return nil, err
+		}
+	}
+	return requests, nil
+}
+
+// DecideJoinRequest 既に決まっていた申請ならfalseを返す
+func (ti *threadInteractor) DecideJoinRequest(requestUserID, threadID, requestID string, approve bool) (*entity.JoinRequest, bool, error) {
+	decider, err := ti.userService.GetByUserID(requestUserID)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to get user")
+	}
+	request, err := ti.threadService.GetJoinRequest(requestID)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to get join request")
+	}
+	if request.Thread.ID != threadID {
+		return nil, false, errors.New("join request is not for this thread")
+	}
+	if err = ti.fillJoinRequest(request); err != nil {
+		return nil, false, err
+	}
+	if approve && request.Thread.IsArchived() {
+		return nil, false, errors.New("thread is archived")
+	}
+	decided, err := ti.threadService.DecideJoinRequest(request, decider, approve)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to decide join request")
+	}
+	return request, decided, nil
+}
+
+func (ti *threadInteractor) fillJoinRequest(request *entity.JoinRequest) error {
+	thread, err := ti.threadService.GetByID(request.Thread.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get thread")
+	}
+	if thread.Author, err = ti.userService.GetByID(thread.Author.ID); err != nil {
+		return errors.Wrap(err, "failed to get author")
+	}
+	request.Thread = thread
+	if request.User, err = ti.userService.GetByID(request.User.ID); err != nil {
 		return errors.Wrap(err, "failed to get user")
 	}
-	if err := ti.threadService.RemoveMember(threadID, user.ID); err != nil {
-		return errors.Wrap(err, "failed to remove member")
+	if request.DecidedBy != nil {
+		if request.DecidedBy, err = ti.userService.GetByID(request.DecidedBy.ID); err != nil {
+			return errors.Wrap(err, "failed to get user")
+		}
 	}
 	return nil
 }
 
-func (ti *threadInteractor) ForceToLeave(requestUserID, threadID, leavedUserID string) error {
-	// TODO: implement
-	return nil
+// RemoveMember ownerが抜けたときは後任を詰めたSuccessionを返す
+// CheckLeavable userIDのユーザがメンバーで、スレッドから抜けられるか
+func (ti *threadInteractor) CheckLeavable(threadID, userID string) error {
+	_, _, err := ti.getLeavable(threadID, userID)
+	return err
+}
+
+func (ti *threadInteractor) getLeavable(threadID, userID string) (*entity.Thread, *entity.User, error) {
+	thread, err := ti.threadService.GetByID(threadID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get thread")
+	}
+	user, err := ti.userService.GetByUserID(userID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get user")
+	}
+	role, err := ti.threadService.GetRole(threadID, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if role == "" {
+		return nil, nil, errors.New("not member of thread")
+	}
+	return thread, user, nil
+}
+
+func (ti *threadInteractor) RemoveMember(threadID, userID string) (*entity.Succession, error) {
+	thread, user, err := ti.getLeavable(threadID, userID)
+	if err != nil {
+		return nil, err
+	}
+	succession, err := ti.threadService.Leave(thread, user)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to remove member")
+	}
+	if succession != nil && succession.NewOwner != nil {
+		if succession.NewOwner, err = ti.userService.GetByID(succession.NewOwner.ID); err != nil {
+			return nil, errors.Wrap(err, "failed to get new owner")
+		}
+	}
+	return succession, nil
+}
+
+// TransferOwnership 譲ったあとのスレッドと、moderatorになった元のownerを返す
+func (ti *threadInteractor) TransferOwnership(requestUserID, threadID, targetUserID string) (*entity.Thread, *entity.User, error) {
+	owner, err := ti.userService.GetByUserID(requestUserID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get user")
+	}
+	target, err := ti.userService.GetByUserID(targetUserID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get target user")
+	}
+	thread, err := ti.GetByID(threadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = ti.threadService.TransferOwnership(thread, owner, target); err != nil {
+		return nil, nil, err
+	}
+	if thread, err = ti.GetByID(threadID); err != nil {
+		return nil, nil, err
+	}
+	return thread, owner, nil
+}
+
+// ForceToLeave banがfalseならメンバーから外すだけで、再参加できる。外したユーザを返す
+func (ti *threadInteractor) ForceToLeave(requestUserID, threadID, leavedUserID string, ban bool, reason string, expiresAt *time.Time) (*entity.User, error) {
+	thread, requester, target, err := ti.getRemovable(requestUserID, threadID, leavedUserID)
+	if err != nil {
+		return nil, err
+	}
+	if ban {
+		if _, err = ti.threadService.Ban(thread, target, requester, reason, expiresAt); err != nil {
+			return nil, errors.Wrap(err, "failed to ban member")
+		}
+		return target, nil
+	}
+	if !ti.IsParticipated(threadID, target.ID) {
+		return nil, errors.New("user is not member of thread")
+	}
+	if err = ti.threadService.RemoveMember(threadID, target.ID); err != nil {
+		return nil, errors.Wrap(err, "failed to remove member")
+	}
+	return target, nil
+}
+
+// Unban BANできるユーザだけが解除できる
+func (ti *threadInteractor) Unban(requestUserID, threadID, bannedUserID string) (bool, error) {
+	_, _, target, err := ti.getRemovable(requestUserID, threadID, bannedUserID)
+	if err != nil {
+		return false, err
+	}
+	removed, err := ti.threadService.Unban(threadID, target.ID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to unban")
+	}
+	return removed, nil
+}
+
+func (ti *threadInteractor) GetBans(threadID string) ([]*entity.ThreadBan, error) {
+	bans, err := ti.threadService.GetBans(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get bans")
+	}
+	for _, ban := range bans {
+		user, err := ti.userService.GetByID(ban.User.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get user")
+		}
+		bannedBy, err := ti.userService.GetByID(ban.BannedBy.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get user")
+		}
+		ban.User = user
+		ban.BannedBy = bannedBy
+	}
+	return bans, nil
+}
+
+func (ti *threadInteractor) CheckRemovable(requestUserID, threadID, targetUserID string) error {
+	_, _, _, err := ti.getRemovable(requestUserID, threadID, targetUserID)
+	return err
+}
+
+func (ti *threadInteractor) getRemovable(requestUserID, threadID, targetUserID string) (*entity.Thread, *entity.User, *entity.User, error) {
+	thread, err := ti.threadService.GetByID(threadID)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "failed to get thread")
+	}
+	requester, err := ti.userService.GetByUserID(requestUserID)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "failed to get user")
+	}
+	target, err := ti.userService.GetByUserID(targetUserID)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "failed to get target user")
+	}
+	if err = ti.threadService.CheckRemovable(thread.ID, requester, target); err != nil {
+		return nil, nil, nil, err
+	}
+	return thread, requester, target, nil
 }
 
 func (ti *threadInteractor) IsParticipated(ThreadID string, UserID string) bool {
@@ -221,3 +637,50 @@ func (ti *threadInteractor) IsParticipated(ThreadID string, UserID string) bool
 	}
 	return false
 }
+
+// IsAdmin userIDのユーザがスレッドの管理者か
+func (ti *threadInteractor) IsAdmin(threadID, userID string) bool {
+	user, err := ti.userService.GetByUserID(userID)
+	if err != nil {
+		return false
+	}
+	isAdmin, err := ti.threadService.IsAdmin(threadID, user.ID)
+	if err != nil {
+		return false
+	}
+	return isAdmin
+}
+
+// CheckPermission スレッド内の操作の権限はすべてここで確認する
+func (ti *threadInteractor) CheckPermission(threadID, userID string, permission entity.Permission) error {
+	user, err := ti.userService.GetByUserID(userID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get user")
+	}
+	return ti.threadService.CheckPermission(threadID, user.ID, permission)
+}
+
+// GetRoles ユーザのUUIDをキーにする
+func (ti *threadInteractor) GetRoles(threadID string) (map[string]entity.Role, error) {
+	roles, err := ti.threadService.GetRoles(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get roles")
+	}
+	return roles, nil
+}
+
+// ChangeRole 役割を変えたユーザを返す
+func (ti *threadInteractor) ChangeRole(requestUserID, threadID, targetUserID string, role entity.Role) (*entity.User, error) {
+	requester, err := ti.userService.GetByUserID(requestUserID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user")
+	}
+	target, err := ti.userService.GetByUserID(targetUserID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get target user")
+	}
+	if err = ti.threadService.ChangeRole(threadID, requester, target, role); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
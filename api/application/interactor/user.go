@@ -16,7 +16,8 @@ type UserInteractor interface {
 	GetByUserID(userID string) (*entity.User, error)
 	GetByMail(mail string) (*entity.User, error)
 	GetAll() ([]*entity.User, error)
-	Delete(userID string) error
+	Delete(userID string) (*entity.AccountDeletion, error)
+	PurgeScheduled() ([]*entity.AccountDeletion, error)
 	GetFollows(id string) ([]*entity.User, error)
 	AddFollow(userID, followedUserID string) error
 	DeleteFollow(userID, followedUserID string) error
@@ -159,14 +160,45 @@ func (ui *userInteractor) GetAll() ([]*entity.User, error) {
 	return users, nil
 }
 
-func (ui *userInteractor) Delete(userID string) error {
+// Delete 猶予期間があれば削除を予約する。すぐに削除したときは後任のownerを詰めて返す
+func (ui *userInteractor) Delete(userID string) (*entity.AccountDeletion, error) {
 	user, err := ui.userService.GetByUserID(userID)
 	if err != nil {
-		return errors.Wrap(err, "failed to get user")
+		return nil, errors.Wrap(err, "failed to get user")
 	}
-	err = ui.userService.Delete(user.ID)
+	deletion, err := ui.userService.Delete(user)
 	if err != nil {
-		return errors.Wrap(err, "failed to delete")
+		return nil, errors.Wrap(err, "failed to delete")
+	}
+	if err = ui.fillSuccessions(deletion); err != nil {
+		return nil, err
+	}
+	return deletion, nil
+}
+
+// PurgeScheduled 猶予期間を過ぎたユーザを削除する
+func (ui *userInteractor) PurgeScheduled() ([]*entity.AccountDeletion, error) {
+	deletions, err := ui.userService.PurgeScheduled()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to purge users")
+	}
+	for _, deletion := range deletions {
+		if err = ui.fillSuccessions(deletion); err != nil {
+			return nil, err
+		}
+	}
+	return deletions, nil
+}
+
+func (ui *userInteractor) fillSuccessions(deletion *entity.AccountDeletion) error {
+	var err error
+	for _, succession := range deletion.Successions {
+		if succession.NewOwner == nil {
+			continue
+		}
+		if succession.NewOwner, err = ui.userService.GetByID(succession.NewOwner.ID); err != nil {
+			return errors.Wrap(err, "failed to get new owner")
+		}
 	}
 	return nil
 }
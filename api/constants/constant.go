@@ -1,5 +1,7 @@
 package constants
 
+import "time"
+
 const (
 	ServerPort  = "8080"
 	DBUser      = "worker"
@@ -10,3 +12,55 @@ const (
 	ImgPath     = "/images"
 	DefaultIcon = "./api/constants/def_icon.jpg"
 )
+
+// message
+const (
+	MessagePageSize    = 50
+	MessagePageSizeMax = 100
+	MessageEditWindow  = 15 * time.Minute
+	FileMessageGrade   = 10
+	DefaultReaction    = "heart" // users_favoritesのいいねはこれに移行した
+	ReactionMaxLength  = 32
+	MentionMax         = 20 // 1メッセージで解決するメンションの上限
+	ThreadPinLimit     = 10 // スレッドごとのピン留めの上限。THREAD_PIN_LIMITで変更できる
+	SnippetLength      = 80 // 検索結果の抜粋の文字数
+)
+
+// thread
+const (
+	ThreadPageSize    = 20
+	ThreadPageSizeMax = 100
+
+	// おすすめスレッドのスコアの重み
+	RecommendTagWeight      = 3
+	RecommendCategoryWeight = 1
+	RecommendFollowWeight   = 2
+	RecommendActivityWindow = 7 * 24 * time.Hour // フォローしているユーザの発言を数える期間
+
+	InviteCodeLength = 10
+	InviteMaxUses    = 1000 // 招待1つで参加できる人数の上限
+)
+
+// account
+const (
+	DeletedUserID                            = "00000000-0000-0000-0000-000000000000" // 退会したユーザのメッセージなどを付け替える先
+	AccountDeletionGracePeriod time.Duration = 0                                      // 退会を申し込んでから消すまでの猶予。ACCOUNT_DELETION_GRACE_PERIODで変更できる
+	AccountPurgeInterval                     = time.Hour
+)
+
+// export
+const (
+	ExportPasswordMaxLength = 70 // bcryptで保存するため
+	ExportFileDir           = "files"
+)
+
+// websocket
+const (
+	WSWriteWait      = 10 * time.Second
+	WSPongWait       = 60 * time.Second
+	WSPingPeriod     = 50 * time.Second
+	WSMaxMessageSize = 4096
+	WSReplayLimit    = 200
+	WSIdleTimeout    = 5 * time.Minute
+	WSTypingTimeout  = 5 * time.Second
+)
@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+// ExportStatus 書き出しはバックグラウンドで行うので、終わるまではpending
+type ExportStatus string
+
+const (
+	ExportPending ExportStatus = "pending"
+	ExportReady   ExportStatus = "ready"
+	ExportFailed  ExportStatus = "failed"
+)
+
+// Export スレッドのメッセージ、メンバー、ファイルをまとめたzip。archivesに保存する
+// Passwordはbcryptのハッシュで、空ならzipも暗号化しない
+type Export struct {
+	ID        string
+	Thread    *Thread
+	Path      string
+	IsPublic  int // 0なら書き出しの権限があるメンバーだけがダウンロードできる
+	Password  string
+	Status    ExportStatus
+	CreatedBy *User
+	CreatedAt *time.Time
+}
+
+func (e *Export) IsProtected() bool {
+	return e.Password != ""
+}
+
+// ExportFile zipに入れるファイル。Nameはzip内のパス
+type ExportFile struct {
+	Name string
+	Body []byte
+}
@@ -0,0 +1,42 @@
+package entity
+
+import "time"
+
+// ThreadInvite Inviteeがnilなら招待コードを知っていれば誰でも使える
+type ThreadInvite struct {
+	ID        string
+	Thread    *Thread
+	Code      string
+	CreatedBy *User
+	Invitee   *User
+	MaxUses   int // 0なら無制限
+	Uses      int
+	ExpiresAt *time.Time
+	RevokedAt *time.Time
+	CreatedAt *time.Time
+	UsedBy    []*ThreadInviteUse
+}
+
+// ThreadInviteUse 招待を使って参加した記録
+type ThreadInviteUse struct {
+	ID        string
+	Invite    *ThreadInvite
+	User      *User
+	CreatedAt *time.Time
+}
+
+// IsUsable at時点で取り消されておらず、期限と回数の上限に達していないか
+func (i *ThreadInvite) IsUsable(at time.Time) bool {
+	if i.RevokedAt != nil {
+		return false
+	}
+	if i.ExpiresAt != nil && !i.ExpiresAt.After(at) {
+		return false
+	}
+	return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
+
+// IsFor userが使える招待か
+func (i *ThreadInvite) IsFor(user *User) bool {
+	return i.Invitee == nil || i.Invitee.ID == user.ID
+}
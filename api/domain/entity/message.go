@@ -3,10 +3,93 @@ package entity
 import "time"
 
 type Message struct {
+	ID         string
+	Message    string
+	Grade      int
+	Author     *User
+	Thread     *Thread
+	Parent     *Message // 返信元。返信でなければnil
+	ReplyCount int
+	Reactions  []*Reaction
+	Mentions   []*Mention
+	CreatedAt  *time.Time
+	EditedAt   *time.Time
+	DeletedAt  *time.Time
+}
+
+// Reaction メッセージへのリアクションをreactionごとに集計したもの
+type Reaction struct {
+	Reaction string
+	Count    int
+	Reacted  bool // 取得したユーザがリアクションしているか
+}
+
+// Mention 本文の@user_idから解決したユーザ
+type Mention struct {
+	ID   string
+	User *User
+}
+
+// Pin スレッドにピン留めされたメッセージ
+type Pin struct {
 	ID        string
-	Message   string
-	Grade     int
-	Author    *User
+	Message   *Message
 	Thread    *Thread
+	PinnedBy  *User
 	CreatedAt *time.Time
 }
+
+// ReplyChain Ancestorsは返信元を古い順に並べたもの
+type ReplyChain struct {
+	Ancestors []*Message
+	Message   *Message
+	Replies   []*Message
+}
+
+// MessageDeletion メッセージ削除の監査ログ。本文は残さない
+type MessageDeletion struct {
+	ID        string
+	Message   *Message
+	Thread    *Thread
+	Author    *User
+	DeletedBy *User
+	Reason    string
+	CreatedAt *time.Time
+}
+
+// MessageRevision 編集で置き換えられる前の本文
+type MessageRevision struct {
+	ID       string
+	Message  *Message
+	Body     string
+	EditedAt *time.Time
+}
+
+// MessageCursor (created_at, id)の順に並べたときの位置
+type MessageCursor struct {
+	CreatedAt *time.Time
+	ID        string
+}
+
+// MessagePage Messagesは古い順。Prev, Nextはその先にメッセージがなければnil
+type MessagePage struct {
+	Messages []*Message
+	Prev     *MessageCursor
+	Next     *MessageCursor
+}
+
+// ReadMarker ユーザがスレッドのどのメッセージまで読んだか
+type ReadMarker struct {
+	ID      string
+	User    *User
+	Thread  *Thread
+	Message *Message
+	ReadAt  *time.Time
+}
+
+// UnreadCount 自分以外が投稿した未読メッセージの数
+type UnreadCount struct {
+	ThreadID          string
+	LastReadMessageID string
+	Count             int
+}
@@ -0,0 +1,81 @@
+package entity
+
+// Role スレッド内での役割。users_threads.roleに保存する
+type Role string
+
+const (
+	RoleOwner     Role = "owner"
+	RoleModerator Role = "moderator"
+	RoleMember    Role = "member"
+	RoleReadOnly  Role = "read_only"
+)
+
+// Permission スレッド内でできる操作
+type Permission string
+
+const (
+	PermissionPost         Permission = "post"
+	PermissionUpload       Permission = "upload"
+	PermissionPin          Permission = "pin"
+	PermissionKick         Permission = "kick"
+	PermissionEditThread   Permission = "edit_thread"
+	PermissionManageTags   Permission = "manage_tags"
+	PermissionChangeIcon   Permission = "change_icon"
+	PermissionInvite       Permission = "invite"
+	PermissionApprove      Permission = "approve"
+	PermissionArchive      Permission = "archive"
+	PermissionExport       Permission = "export"
+	PermissionDeleteThread Permission = "delete_thread"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleOwner: {
+		PermissionPost, PermissionUpload, PermissionPin, PermissionKick,
+		PermissionEditThread, PermissionManageTags, PermissionChangeIcon, PermissionInvite, PermissionApprove,
+		PermissionArchive, PermissionExport, PermissionDeleteThread,
+	},
+	RoleModerator: {
+		PermissionPost, PermissionUpload, PermissionPin, PermissionKick,
+		PermissionManageTags, PermissionChangeIcon, PermissionInvite, PermissionApprove, PermissionExport,
+	},
+	RoleMember: {
+		PermissionPost, PermissionUpload,
+	},
+	RoleReadOnly: {},
+}
+
+var roleRanks = map[Role]int{
+	RoleOwner:     3,
+	RoleModerator: 2,
+	RoleMember:    1,
+	RoleReadOnly:  0,
+}
+
+func (r Role) IsValid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Outranks rより下の役割のメンバーだけを外したり役割を変えたりできる
+func (r Role) Outranks(other Role) bool {
+	return roleRanks[r] > roleRanks[other]
+}
+
+// IsAdmin モデレーター以上
+func (r Role) IsAdmin() bool {
+	return r == RoleOwner || r == RoleModerator
+}
+
+// Permissions 役割が持つ権限の一覧
+func (r Role) Permissions() []Permission {
+	return rolePermissions[r]
+}
@@ -0,0 +1,61 @@
+package entity
+
+import "time"
+
+// MessageSearchQuery Termsは全て含むメッセージを探す。空のフィールドは絞り込まない
+type MessageSearchQuery struct {
+	Terms     []string
+	ThreadIDs []string // 検索対象のスレッド。空なら何も返さない
+	AuthorID  string
+	Since     *time.Time
+	Until     *time.Time
+	HasFile   *bool
+	Before    *MessageCursor
+	Limit     int
+}
+
+// MessageSearchHit Snippetは一致した語を<mark>で囲んだ本文の抜粋。HTMLエスケープ済み
+type MessageSearchHit struct {
+	Message *Message
+	Snippet string
+}
+
+// MessageSearchResult Hitsは新しい順。Nextは続きがなければnil
+type MessageSearchResult struct {
+	Hits []*MessageSearchHit
+	Next *MessageCursor
+}
+
+// スレッド検索の並び順
+const (
+	ThreadSortCreated = "created" // 作成が新しい順
+	ThreadSortActive  = "active"  // 最後の発言が新しい順
+	ThreadSortMembers = "members" // 参加人数が多い順
+	ThreadSortName    = "name"    // 名前順
+)
+
+// ThreadSearchQuery 空のフィールドは絞り込まない。非公開スレッドはUserIDのユーザが参加しているものだけ返す
+type ThreadSearchQuery struct {
+	UserID      string
+	Keywords    []string // 名前か説明に全て含む
+	TagIDs      []string // いずれかのタグが付いている
+	CategoryID  string
+	IsPublic    *int
+	HasVacancy  bool // 上限人数に達していない
+	ActiveSince *time.Time
+	Sort        string
+	Offset      int
+	Limit       int
+}
+
+// ThreadSearchHit LastActiveAtは発言がなければnil
+type ThreadSearchHit struct {
+	Thread       *Thread
+	MemberCount  int
+	LastActiveAt *time.Time
+}
+
+type ThreadSearchResult struct {
+	Hits    []*ThreadSearchHit
+	HasNext bool
+}
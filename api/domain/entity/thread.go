@@ -2,14 +2,100 @@ package entity
 
 import "time"
 
+// Thread LimitUsersが0なら人数制限なし
 type Thread struct {
-	ID          string
-	Name        string
-	Description string
-	LimitUsers  int
-	Author      *User
-	IsPublic    int
-	CreatedAt   *time.Time
-	UpdatedAt   *time.Time
-	Tags        []*Tag
+	ID            string
+	Name          string
+	Description   string
+	LimitUsers    int
+	Author        *User
+	IsPublic      int
+	JoinPolicy    JoinPolicy
+	ArchivedAt    *time.Time // nilでなければ読み取り専用
+	MemberCount   int
+	WaitlistCount int
+	CreatedAt     *time.Time
+	UpdatedAt     *time.Time
+	Tags          []*Tag
+}
+
+func (t *Thread) IsArchived() bool {
+	return t.ArchivedAt != nil
+}
+
+// Succession ownerが抜けたあとのスレッド。NewOwnerがnilなら誰も残っておらず、
+// 履歴がなければ削除(Deleted)、あればアーカイブしている
+type Succession struct {
+	Thread   *Thread
+	NewOwner *User
+	Deleted  bool
+}
+
+// ThreadRecommendation Scoreの内訳と、ユーザのタグと一致したタグ・カテゴリを持つ
+type ThreadRecommendation struct {
+	Thread            *Thread
+	Score             int
+	TagMatches        int
+	CategoryMatches   int
+	FollowedActive    int // 最近発言したフォロー中のユーザ数
+	MatchedTags       []*Tag
+	MatchedCategories []*Category
+}
+
+// ThreadBan ExpiresAtがnilなら無期限
+type ThreadBan struct {
+	ID        string
+	Thread    *Thread
+	User      *User
+	BannedBy  *User
+	Reason    string
+	ExpiresAt *time.Time
+	CreatedAt *time.Time
+}
+
+// IsActive at時点でBANが有効か
+func (b *ThreadBan) IsActive(at time.Time) bool {
+	return b.ExpiresAt == nil || b.ExpiresAt.After(at)
+}
+
+// JoinPolicy 招待があればどの方針でも参加できる
+type JoinPolicy string
+
+const (
+	JoinPolicyOpen       JoinPolicy = "open"        // 誰でも参加できる
+	JoinPolicyRequest    JoinPolicy = "request"     // 管理者の承認が必要
+	JoinPolicyInviteOnly JoinPolicy = "invite_only" // 招待がないと参加できない
+)
+
+func (p JoinPolicy) IsValid() bool {
+	return p == JoinPolicyOpen || p == JoinPolicyRequest || p == JoinPolicyInviteOnly
+}
+
+type JoinRequestStatus string
+
+const (
+	JoinRequestPending  JoinRequestStatus = "pending"
+	JoinRequestApproved JoinRequestStatus = "approved"
+	JoinRequestRejected JoinRequestStatus = "rejected"
+)
+
+// JoinRequest 承認制のスレッドへの参加申請。DecidedByは承認か却下したユーザ
+type JoinRequest struct {
+	ID        string
+	Thread    *Thread
+	User      *User
+	Status    JoinRequestStatus
+	DecidedBy *User
+	DecidedAt *time.Time
+	CreatedAt *time.Time
+	Waitlist  *WaitlistEntry // 承認したときに満員だったら参加待ちに入る
+}
+
+// WaitlistEntry 満員のスレッドの参加待ち。Positionは1から始まる順番
+type WaitlistEntry struct {
+	ID        string
+	Thread    *Thread
+	User      *User
+	Position  int
+	CreatedAt *time.Time
 }
@@ -16,3 +16,11 @@ type User struct {
 	Password  string
 	Tags      []*Tag
 }
+
+// AccountDeletion 退会の結果。ScheduledAtがnilならすぐに削除しており、
+// Successionsにownerだったスレッドの後任が入る
+type AccountDeletion struct {
+	User        *User
+	ScheduledAt *time.Time
+	Successions []*Succession
+}
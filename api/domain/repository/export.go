@@ -0,0 +1,10 @@
+package repository
+
+import "app/api/domain/entity"
+
+type ExportRepository interface {
+	Create(export *entity.Export) error
+	FindByID(id string) (*entity.Export, error)
+	FindByThreadID(threadID string) ([]*entity.Export, error)
+	UpdateStatus(id string, status entity.ExportStatus, path string) error
+}
@@ -1,5 +1,7 @@
 package repository
 
+import "app/api/domain/entity"
+
 type FileRepository interface {
 	CreateFile(threadID string, fileName string, file []byte) error
 	GetFile(threadID string, fileName string) ([]byte, error)
@@ -9,4 +11,8 @@ type FileRepository interface {
 	GetThreadIcon(threadID string) ([]byte, error)
 	CreateUserDir(userID string) error
 	CreateThreadDir(threadID string) error
+	DeleteUserDir(userID string) error
+	CreateExport(threadID string, exportID string, password string, files []*entity.ExportFile) (string, error)
+	GetExport(path string) ([]byte, error)
+	DeleteExports(threadID string) error
 }
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"app/api/domain/entity"
+	"time"
+)
+
+type InviteRepository interface {
+	Create(invite *entity.ThreadInvite) error
+	FindByID(id string) (*entity.ThreadInvite, error)
+	FindByCode(code string) (*entity.ThreadInvite, error)
+	FindByThreadID(threadID string) ([]*entity.ThreadInvite, error)
+	FindByInviteeID(userID string, at time.Time) ([]*entity.ThreadInvite, error)
+	FindUsesByThreadID(threadID string) ([]*entity.ThreadInviteUse, error)
+	Revoke(id string, revokedAt time.Time) (bool, error)
+	Use(use *entity.ThreadInviteUse, entry *entity.WaitlistEntry) (bool, error)
+}
@@ -1,10 +1,31 @@
 package repository
 
-import "app/api/domain/entity"
+import (
+	"app/api/domain/entity"
+	"time"
+)
 
 type MessageRepository interface {
 	Create(message *entity.Message) error
-	GetByThreadID(threadID string) ([]*entity.Message, error)
+	GetByThreadIDBefore(threadID string, cursor *entity.MessageCursor, limit int) ([]*entity.Message, error)
+	GetByThreadIDAfter(threadID string, cursor *entity.MessageCursor, limit int) ([]*entity.Message, error)
+	GetReplies(parentID string, limit int) ([]*entity.Message, error)
+	GetLatestByThreadIDAfter(threadID string, createdAt *time.Time, id string, limit int) ([]*entity.Message, error)
 	GetByID(id string) (*entity.Message, error)
-	AddFavorite(id, messageID, userUUID string) error
+	FindByIDs(ids []string) ([]*entity.Message, error)
+	Update(message *entity.Message, revision *entity.MessageRevision) error
+	FindRevisionsByMessageID(messageID string) ([]*entity.MessageRevision, error)
+	Delete(message *entity.Message, deletion *entity.MessageDeletion) error
+	FindDeletionsByThreadID(threadID string) ([]*entity.MessageDeletion, error)
+	AddReaction(id, messageID, userUUID, reaction string, createdAt *time.Time) (bool, error)
+	RemoveReaction(messageID, userUUID, reaction string) (bool, error)
+	CountReactions(messageIDs []string, userUUID string) (map[string][]*entity.Reaction, error)
+	AddPin(pin *entity.Pin, limit int) (bool, error)
+	RemovePin(threadID, messageID string) (bool, error)
+	FindPinsByThreadID(threadID string) ([]*entity.Pin, error)
+	FindMentionedByUserID(userUUID string, cursor *entity.MessageCursor, limit int) ([]*entity.Message, error)
+	FindReadMarker(threadID, userUUID string) (*entity.ReadMarker, error)
+	FindReadMarkersByThreadID(threadID string) ([]*entity.ReadMarker, error)
+	SaveReadMarker(marker *entity.ReadMarker) error
+	CountUnreadByUserID(userUUID string) ([]*entity.UnreadCount, error)
 }
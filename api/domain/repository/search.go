@@ -0,0 +1,13 @@
+package repository
+
+import "app/api/domain/entity"
+
+// MessageSearchIndex メッセージの全文検索。MySQLのFULLTEXTとプロセス内の索引で差し替えられる
+type MessageSearchIndex interface {
+	Index(message *entity.Message) error
+	Remove(messageID string) error
+	// Search 一致したメッセージのIDを新しい順にquery.Limit件返す
+	Search(query *entity.MessageSearchQuery) ([]string, error)
+	// Persistent falseならプロセス起動時に全メッセージを入れ直す必要がある
+	Persistent() bool
+}
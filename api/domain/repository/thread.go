@@ -1,16 +1,44 @@
 package repository
 
-import "app/api/domain/entity"
+import (
+	"app/api/domain/entity"
+	"time"
+)
 
 type ThreadRepository interface {
 	Create(thread *entity.Thread) error
 	FindAll() ([]*entity.Thread, error)
+	FindAllIDs() ([]string, error)
 	FindByID(id string) (*entity.Thread, error)
 	FindByUserID(userID string) ([]*entity.Thread, error)
 	FindOnlyPublic() ([]*entity.Thread, error)
+	Search(query *entity.ThreadSearchQuery) ([]*entity.ThreadSearchHit, error)
+	FindRecommendations(userUUID string, activeSince *time.Time, limit int) ([]*entity.ThreadRecommendation, error)
 	FindMembersByThreadID(id string) ([]*entity.User, error)
 	Update(thread *entity.Thread) error
-	AddMember(id, threadID, userID string, isAdmin int) error
+	Archive(threadID string, archivedAt time.Time) (bool, error)
+	Restore(threadID string) (bool, error)
+	AddMember(id, threadID, userID string, role entity.Role) (bool, error)
+	AddMemberOrWait(entry *entity.WaitlistEntry) (bool, error)
 	RemoveMember(threadID, userID string) error
+	FindRole(threadID, userID string) (entity.Role, error)
+	FindRolesByThreadID(threadID string) (map[string]entity.Role, error)
+	UpdateRole(threadID, userID string, role entity.Role) (bool, error)
+	TransferOwner(threadID, ownerID, userID string) (bool, error)
+	RemoveOwner(threadID, ownerID string) (string, error)
+	SaveBan(ban *entity.ThreadBan) error
+	RemoveBan(threadID, userID string) (bool, error)
+	FindBan(threadID, userID string) (*entity.ThreadBan, error)
+	FindBansByThreadID(threadID string) ([]*entity.ThreadBan, error)
+	SaveJoinRequest(request *entity.JoinRequest) error
+	FindJoinRequestByID(id string) (*entity.JoinRequest, error)
+	FindJoinRequest(threadID, userID string) (*entity.JoinRequest, error)
+	FindJoinRequestsByThreadID(threadID string, status entity.JoinRequestStatus) ([]*entity.JoinRequest, error)
+	FindJoinRequestsByUserID(userID string) ([]*entity.JoinRequest, error)
+	DecideJoinRequest(request *entity.JoinRequest, entry *entity.WaitlistEntry) (bool, error)
+	FindWaitlistByThreadID(threadID string) ([]*entity.WaitlistEntry, error)
+	RemoveFromWaitlist(threadID, userID string) (bool, error)
+	PromoteFromWaitlist(threadID, memberID string) (*entity.WaitlistEntry, error)
 	Delete(id string) error
+	DeleteIfEmpty(id string) (bool, error)
 }
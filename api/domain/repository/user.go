@@ -14,7 +14,10 @@ type UserRepository interface {
 	FindByID(id string) (*entity.User, error)
 	FindByUserID(userID string) (*entity.User, error)
 	FindByMail(mail string) (*entity.User, error)
-	DeleteByID(id string) error
+	ScheduleDeletion(id string, at time.Time) error
+	CancelDeletion(id string) (bool, error)
+	FindIDsScheduledForDeletion(before time.Time) ([]string, error)
+	Purge(id string, at time.Time) ([]*entity.Succession, error)
 	FindFollows(id string) ([]*entity.User, error)
 	AddFollow(id, userID, followedUserID string) error
 	DeleteFollow(userID, followedUserID string) error
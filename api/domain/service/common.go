@@ -1,6 +1,8 @@
 package service
 
 import (
+	"crypto/rand"
+
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 )
@@ -12,3 +14,17 @@ func GenerateUUID() (string, error) {
 	}
 	return id.String(), nil
 }
+
+const codeLetters = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// GenerateCode 見間違えやすい文字を除いたランダムな文字列
+func GenerateCode(length int) (string, error) {
+	buf := make([]byte, length)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.Wrap(err, "failed to generate code")
+	}
+	for i, b := range buf {
+		buf[i] = codeLetters[int(b)%len(codeLetters)]
+	}
+	return string(buf), nil
+}
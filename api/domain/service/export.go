@@ -0,0 +1,229 @@
+package service
+
+import (
+	"app/api/constants"
+	"app/api/domain/entity"
+	"app/api/domain/repository"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type ExportService interface {
+	New(thread *entity.Thread, creator *entity.User, isPublic int, passwordHash string) (*entity.Export, error)
+	GetByID(id string) (*entity.Export, error)
+	GetByThreadID(threadID string) ([]*entity.Export, error)
+	Build(export *entity.Export, password string, members []*entity.User, roles map[string]entity.Role, messages []*entity.Message) error
+	Fail(export *entity.Export) error
+	Load(export *entity.Export) ([]byte, error)
+}
+
+type exportService struct {
+	exportRepository repository.ExportRepository
+	fileRepository   repository.FileRepository
+	userRepository   repository.UserRepository
+}
+
+func NewExportService(er repository.ExportRepository, fr repository.FileRepository, ur repository.UserRepository) ExportService {
+	return &exportService{
+		exportRepository: er,
+		fileRepository:   fr,
+		userRepository:   ur,
+	}
+}
+
+// zipに入れるJSON。APIのレスポンスが変わっても過去の書き出しと形が変わらないようにする
+type exportThread struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	ArchivedAt  *time.Time `json:"archived_at"`
+	CreatedAt   *time.Time `json:"created_at"`
+	ExportedBy  string     `json:"exported_by"`
+	ExportedAt  *time.Time `json:"exported_at"`
+}
+
+type exportUser struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	Role   string `json:"role,omitempty"`
+}
+
+type exportMessage struct {
+	ID        string      `json:"id"`
+	Author    *exportUser `json:"author"`
+	Message   string      `json:"message"`
+	File      string      `json:"file,omitempty"`
+	ParentID  string      `json:"parent_id,omitempty"`
+	CreatedAt *time.Time  `json:"created_at"`
+	EditedAt  *time.Time  `json:"edited_at"`
+	DeletedAt *time.Time  `json:"deleted_at"`
+}
+
+// New 書き出しはBuildで行うので、pendingで登録する
+func (es *exportService) New(thread *entity.Thread, creator *entity.User, isPublic int, passwordHash string) (*entity.Export, error) {
+	id, err := GenerateUUID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate uuid")
+	}
+	now := time.Now()
+	export := &entity.Export{
+		ID:        id,
+		Thread:    thread,
+		IsPublic:  isPublic,
+		Password:  passwordHash,
+		Status:    entity.ExportPending,
+		CreatedBy: creator,
+		CreatedAt: &now,
+	}
+	if err = es.exportRepository.Create(export); err != nil {
+		return nil, errors.Wrap(err, "failed to create export")
+	}
+	return export, nil
+}
+
+func (es *exportService) GetByID(id string) (*entity.Export, error) {
+	export, err := es.exportRepository.FindByID(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get export")
+	}
+	return export, nil
+}
+
+func (es *exportService) GetByThreadID(threadID string) ([]*entity.Export, error) {
+	exports, err := es.exportRepository.FindByThreadID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get exports")
+	}
+	return exports, nil
+}
+
+// Build スレッド情報、メンバー、メッセージ、アップロードされたファイルをzipにまとめてreadyにする。
+// passwordは平文で、空でなければzipを暗号化する。rolesはユーザのUUIDがキー
+func (es *exportService) Build(export *entity.Export, password string, members []*entity.User, roles map[string]entity.Role, messages []*entity.Message) error {
+	now := time.Now()
+	thread := &exportThread{
+		ID:          export.Thread.ID,
+		Name:        export.Thread.Name,
+		Description: export.Thread.Description,
+		ArchivedAt:  export.Thread.ArchivedAt,
+		CreatedAt:   export.Thread.CreatedAt,
+		ExportedBy:  export.CreatedBy.UserID,
+		ExportedAt:  &now,
+	}
+
+	users := make(map[string]*exportUser, len(members))
+	memberList := make([]*exportUser, 0, len(members))
+	for _, member := range members {
+		u := &exportUser{
+			ID:     member.ID,
+			UserID: member.UserID,
+			Name:   member.Name,
+			Role:   string(roles[member.ID]),
+		}
+		users[member.ID] = u
+		memberList = append(memberList, u)
+	}
+
+	var files []*entity.ExportFile
+	var transcript strings.Builder
+	messageList := make([]*exportMessage, 0, len(messages))
+	for _, message := range messages {
+		author := es.findAuthor(users, message.Author.ID)
+		m := &exportMessage{
+			ID:        message.ID,
+			Author:    author,
+			Message:   message.Message,
+			CreatedAt: message.CreatedAt,
+			EditedAt:  message.EditedAt,
+			DeletedAt: message.DeletedAt,
+		}
+		if message.Parent != nil {
+			m.ParentID = message.Parent.ID
+		}
+		body := message.Message
+		switch {
+		case message.DeletedAt != nil:
+			body = "(deleted)"
+		case message.Grade == constants.FileMessageGrade && isExportableFileName(message.Message):
+			// 削除されたファイルはメッセージだけ残す
+			file, err := es.fileRepository.GetFile(export.Thread.ID, message.Message)
+			if err == nil {
+				m.File = constants.ExportFileDir + "/" + message.Message
+				files = append(files, &entity.ExportFile{Name: m.File, Body: file})
+				body = "(file) " + m.File
+			}
+		}
+		messageList = append(messageList, m)
+		transcript.WriteString("[" + message.CreatedAt.Format("2006-01-02 15:04:05") + "] " + author.Name + " (@" + author.UserID + "): " + body + "\n")
+	}
+
+	meta := []struct {
+		name string
+		v    interface{}
+	}{
+		{"thread.json", thread},
+		{"members.json", memberList},
+		{"messages.json", messageList},
+	}
+	bundle := make([]*entity.ExportFile, 0, len(meta)+1+len(files))
+	for _, m := range meta {
+		body, err := json.MarshalIndent(m.v, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal "+m.name)
+		}
+		bundle = append(bundle, &entity.ExportFile{Name: m.name, Body: body})
+	}
+	bundle = append(bundle, &entity.ExportFile{Name: "transcript.txt", Body: []byte(transcript.String())})
+	bundle = append(bundle, files...)
+
+	path, err := es.fileRepository.CreateExport(export.Thread.ID, export.ID, password, bundle)
+	if err != nil {
+		return errors.Wrap(err, "failed to create zip")
+	}
+	if err = es.exportRepository.UpdateStatus(export.ID, entity.ExportReady, path); err != nil {
+		return errors.Wrap(err, "failed to update export")
+	}
+	export.Path = path
+	export.Status = entity.ExportReady
+	return nil
+}
+
+// findAuthor 退出したユーザも名前を引いてusersに加える。引けなければIDだけ残す
+func (es *exportService) findAuthor(users map[string]*exportUser, id string) *exportUser {
+	if u, ok := users[id]; ok {
+		return u
+	}
+	u := &exportUser{ID: id}
+	if user, err := es.userRepository.FindByID(id); err == nil {
+		u.UserID = user.UserID
+		u.Name = user.Name
+	}
+	users[id] = u
+	return u
+}
+
+func (es *exportService) Fail(export *entity.Export) error {
+	if err := es.exportRepository.UpdateStatus(export.ID, entity.ExportFailed, ""); err != nil {
+		return errors.Wrap(err, "failed to update export")
+	}
+	export.Status = entity.ExportFailed
+	return nil
+}
+
+func (es *exportService) Load(export *entity.Export) ([]byte, error) {
+	file, err := es.fileRepository.GetExport(export.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load export")
+	}
+	return file, nil
+}
+
+// isExportableFileName パスを含む名前はthreadの外を指すのでzipに入れない
+func isExportableFileName(name string) bool {
+	return name != "." && name != ".." && filepath.Base(name) == name
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"app/api/constants"
+	"app/api/domain/entity"
+	"app/api/domain/repository"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type InviteService interface {
+	New(thread *entity.Thread, creator, invitee *entity.User, maxUses int, expiresAt *time.Time) (*entity.ThreadInvite, error)
+	GetByID(id string) (*entity.ThreadInvite, error)
+	GetByCode(code string) (*entity.ThreadInvite, error)
+	GetByThreadID(threadID string) ([]*entity.ThreadInvite, error)
+	GetForUser(userID string) ([]*entity.ThreadInvite, error)
+	Revoke(id string) (bool, error)
+	Use(invite *entity.ThreadInvite, user *entity.User) (*entity.WaitlistEntry, error)
+}
+
+type inviteService struct {
+	inviteRepository repository.InviteRepository
+}
+
+func NewInviteService(ir repository.InviteRepository) InviteService {
+	return &inviteService{
+		inviteRepository: ir,
+	}
+}
+
+// New inviteeがnilならコードを知っている誰でも使える招待を作る
+func (is *inviteService) New(thread *entity.Thread, creator, invitee *entity.User, maxUses int, expiresAt *time.Time) (*entity.ThreadInvite, error) {
+	id, err := GenerateUUID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate uuid")
+	}
+	code, err := GenerateCode(constants.InviteCodeLength)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate invite code")
+	}
+	now := time.Now()
+	invite := &entity.ThreadInvite{
+		ID:        id,
+		Thread:    thread,
+		Code:      code,
+		CreatedBy: creator,
+		Invitee:   invitee,
+		MaxUses:   maxUses,
+		ExpiresAt: expiresAt,
+		CreatedAt: &now,
+	}
+	if err = is.inviteRepository.Create(invite); err != nil {
+		return nil, errors.Wrap(err, "failed to create invite")
+	}
+	return invite, nil
+}
+
+func (is *inviteService) GetByID(id string) (*entity.ThreadInvite, error) {
+	invite, err := is.inviteRepository.FindByID(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get invite")
+	}
+	return invite, nil
+}
+
+func (is *inviteService) GetByCode(code string) (*entity.ThreadInvite, error) {
+	invite, err := is.inviteRepository.FindByCode(code)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get invite")
+	}
+	return invite, nil
+}
+
+// GetByThreadID 招待ごとに使って参加したユーザを詰めて返す
+func (is *inviteService) GetByThreadID(threadID string) ([]*entity.ThreadInvite, error) {
+	invites, err := is.inviteRepository.FindByThreadID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get invites")
+	}
+	uses, err := is.inviteRepository.FindUsesByThreadID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get invite uses")
+	}
+	byID := make(map[string]*entity.ThreadInvite, len(invites))
+	for _, invite := range invites {
+		byID[invite.ID] = invite
+	}
+	for _, use := range uses {
+		if invite, ok := byID[use.Invite.ID]; ok {
+			use.Invite = invite
+			invite.UsedBy = append(invite.UsedBy, use)
+		}
+	}
+	return invites, nil
+}
+
+// GetForUser ユーザ宛てのまだ使える招待
+func (is *inviteService) GetForUser(userID string) ([]*entity.ThreadInvite, error) {
+	invites, err := is.inviteRepository.FindByInviteeID(userID, time.Now())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get invites")
+	}
+	return invites, nil
+}
+
+func (is *inviteService) Revoke(id string) (bool, error) {
+	revoked, err := is.inviteRepository.Revoke(id, time.Now())
+	if err != nil {
+		return false, errors.Wrap(err, "failed to revoke invite")
+	}
+	return revoked, nil
+}
+
+// Use 招待を使ってuserをメンバーにする。満員なら参加待ちに入れて返し、メンバーになれたときはnilを返す
+func (is *inviteService) Use(invite *entity.ThreadInvite, user *entity.User) (*entity.WaitlistEntry, error) {
+	now := time.Now()
+	if !invite.IsFor(user) {
+		return nil, errors.New("invite is for another user")
+	}
+	if !invite.IsUsable(now) {
+		return nil, errors.New("invite is no longer valid")
+	}
+	useID, err := GenerateUUID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate uuid")
+	}
+	entryID, err := GenerateUUID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate uuid")
+	}
+	use := &entity.ThreadInviteUse{
+		ID:        useID,
+		Invite:    invite,
+		User:      user,
+		CreatedAt: &now,
+	}
+	entry := &entity.WaitlistEntry{
+		ID:        entryID,
+		Thread:    invite.Thread,
+		User:      user,
+		CreatedAt: &now,
+	}
+	added, err := is.inviteRepository.Use(use, entry)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to use invite")
+	}
+	invite.Uses++
+	if added {
+		return nil, nil
+	}
+	return entry, nil
+}
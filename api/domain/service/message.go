@@ -1,31 +1,89 @@
 package service
 
 import (
+	"app/api/constants"
 	"app/api/domain/entity"
 	"app/api/domain/repository"
+	"html"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/pkg/errors"
 )
 
 type MessageService interface {
-	New(message string, grade int, author *entity.User, thread *entity.Thread) (*entity.Message, error)
+	New(message string, grade int, author *entity.User, thread *entity.Thread, parent *entity.Message) (*entity.Message, error)
 	GetByID(id string) (*entity.Message, error)
-	GetByThreadID(threadID string) ([]*entity.Message, error)
-	AddFavorite(messageID, userUUID string) error
+	CheckEditable(message *entity.Message, editor *entity.User) error
+	Edit(message *entity.Message, body string) (*entity.Message, error)
+	GetRevisions(messageID string) ([]*entity.MessageRevision, error)
+	CheckDeletable(message *entity.Message, user *entity.User, isThreadAdmin bool) error
+	Delete(message *entity.Message, user *entity.User, reason string) (*entity.Message, error)
+	GetDeletionsByThreadID(threadID string) ([]*entity.MessageDeletion, error)
+	GetReplyChain(message *entity.Message, limit int) (*entity.ReplyChain, error)
+	GetPageByThreadID(threadID string, before, after *entity.MessageCursor, limit int) (*entity.MessagePage, error)
+	GetAllByThreadID(threadID string) ([]*entity.Message, error)
+	GetLatestByThreadIDAfter(threadID string, createdAt *time.Time, id string, limit int) ([]*entity.Message, error)
+	AddReaction(message *entity.Message, user *entity.User, reaction string) (bool, error)
+	RemoveReaction(message *entity.Message, user *entity.User, reaction string) (bool, error)
+	AttachReactions(messages []*entity.Message, userUUID string) error
+	Pin(message *entity.Message, user *entity.User) (*entity.Pin, bool, error)
+	Unpin(message *entity.Message) (bool, error)
+	GetPinsByThreadID(threadID string) ([]*entity.Pin, error)
+	Search(query *entity.MessageSearchQuery) (*entity.MessageSearchResult, error)
+	BuildSearchIndex() error
+	GetMentioned(userUUID string, before *entity.MessageCursor, limit int) ([]*entity.Message, *entity.MessageCursor, error)
+	MarkAsRead(user *entity.User, message *entity.Message) (*entity.ReadMarker, bool, error)
+	GetReadMarkersByThreadID(threadID string) ([]*entity.ReadMarker, error)
+	GetUnreadCounts(userUUID string) (map[string]*entity.UnreadCount, error)
 }
 
 type messageService struct {
 	messageRepository repository.MessageRepository
+	userRepository    repository.UserRepository
+	threadRepository  repository.ThreadRepository
+	searchIndex       repository.MessageSearchIndex
+	editWindow        time.Duration
+	pinLimit          int
 }
 
-func NewMessageService(mr repository.MessageRepository) MessageService {
+// mentionPattern @の後ろの空白までをuser_idとみなす。末尾の句読点はparseMentionsで落とす
+var mentionPattern = regexp.MustCompile(`@([^\s@]+)`)
+
+func NewMessageService(mr repository.MessageRepository, ur repository.UserRepository, tr repository.ThreadRepository, si repository.MessageSearchIndex) MessageService {
+	editWindow := constants.MessageEditWindow
+	if v, err := time.ParseDuration(os.Getenv("MESSAGE_EDIT_WINDOW")); err == nil && v > 0 {
+		editWindow = v
+	}
+	pinLimit := constants.ThreadPinLimit
+	if v, err := strconv.Atoi(os.Getenv("THREAD_PIN_LIMIT")); err == nil && v > 0 {
+		pinLimit = v
+	}
 	return &messageService{
 		messageRepository: mr,
+		userRepository:    ur,
+		threadRepository:  tr,
+		searchIndex:       si,
+		editWindow:        editWindow,
+		pinLimit:          pinLimit,
 	}
 }
 
-func (ms *messageService) New(message string, grade int, author *entity.User, thread *entity.Thread) (*entity.Message, error) {
+// New parentがあればその返信にする。parentは同じスレッドの削除されていないメッセージに限る
+// 本文の@user_idはメンションとして一緒に保存する
+func (ms *messageService) New(message string, grade int, author *entity.User, thread *entity.Thread, parent *entity.Message) (*entity.Message, error) {
+	if parent != nil {
+		if parent.Thread.ID != thread.ID {
+			return nil, errors.New("parent message is not in thread")
+		}
+		if parent.DeletedAt != nil {
+			return nil, errors.New("parent message is deleted")
+		}
+	}
 	id, err := GenerateUUID()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to generate id")
@@ -38,13 +96,288 @@ func (ms *messageService) New(message string, grade int, author *entity.User, th
 		CreatedAt: &now,
 		Author:    author,
 		Thread:    thread,
+		Parent:    parent,
+	}
+	if grade != constants.FileMessageGrade {
+		if msg.Mentions, err = ms.resolveMentions(message, author, thread); err != nil {
+			return nil, errors.Wrap(err, "failed to resolve mentions")
+		}
 	}
 	if err = ms.messageRepository.Create(msg); err != nil {
 		return nil, errors.Wrap(err, "failed to create message")
 	}
+	if err = ms.searchIndex.Index(msg); err != nil {
+		return nil, errors.Wrap(err, "failed to index message")
+	}
 	return msg, nil
 }
 
+// resolveMentions 存在しないuser_idと自分自身は無視する。非公開スレッドではメンバー以外も無視する
+func (ms *messageService) resolveMentions(message string, author *entity.User, thread *entity.Thread) ([]*entity.Mention, error) {
+	var members map[string]bool
+	if thread.IsPublic != 1 {
+		users, err := ms.threadRepository.FindMembersByThreadID(thread.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get members")
+		}
+		members = make(map[string]bool, len(users))
+		for _, user := range users {
+			members[user.ID] = true
+		}
+	}
+	var mentions []*entity.Mention
+	seen := map[string]bool{author.UserID: true}
+	for _, userID := range parseMentions(message) {
+		if len(mentions) >= constants.MentionMax {
+			break
+		}
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		// 見つからないuser_idはただの文字列として扱う
+		user, err := ms.userRepository.FindByUserID(userID)
+		if err != nil {
+			continue
+		}
+		if members != nil && !members[user.ID] {
+			continue
+		}
+		id, err := GenerateUUID()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to generate id")
+		}
+		mentions = append(mentions, &entity.Mention{ID: id, User: user})
+	}
+	return mentions, nil
+}
+
+// parseMentions 本文に出てくる順にuser_idを返す
+func parseMentions(message string) []string {
+	var userIDs []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(message, -1) {
+		if userID := strings.TrimRight(match[1], ".,!?:;)]}"); userID != "" {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs
+}
+
+// Pin messageをそのスレッドにピン留めする。既にピン留めされていればfalse
+func (ms *messageService) Pin(message *entity.Message, user *entity.User) (*entity.Pin, bool, error) {
+	if message.DeletedAt != nil {
+		return nil, false, errors.New("message is deleted")
+	}
+	id, err := GenerateUUID()
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to generate id")
+	}
+	now := time.Now()
+	pin := &entity.Pin{
+		ID:        id,
+		Message:   message,
+		Thread:    message.Thread,
+		PinnedBy:  user,
+		CreatedAt: &now,
+	}
+	added, err := ms.messageRepository.AddPin(pin, ms.pinLimit)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to pin message")
+	}
+	return pin, added, nil
+}
+
+// Unpin ピン留めされていなければfalse
+func (ms *messageService) Unpin(message *entity.Message) (bool, error) {
+	removed, err := ms.messageRepository.RemovePin(message.Thread.ID, message.ID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to unpin message")
+	}
+	return removed, nil
+}
+
+func (ms *messageService) GetPinsByThreadID(threadID string) ([]*entity.Pin, error) {
+	pins, err := ms.messageRepository.FindPinsByThreadID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get pins")
+	}
+	return pins, nil
+}
+
+// Search 一致したメッセージを新しい順に返す。索引にあってもDBで削除されていれば除く
+func (ms *messageService) Search(query *entity.MessageSearchQuery) (*entity.MessageSearchResult, error) {
+	limit := query.Limit
+	q := *query
+	q.Limit = limit + 1
+	ids, err := ms.searchIndex.Search(&q)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search messages")
+	}
+	hasNext := len(ids) > limit
+	if hasNext {
+		ids = ids[:limit]
+	}
+	messages, err := ms.messageRepository.FindByIDs(ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get messages")
+	}
+	byID := make(map[string]*entity.Message, len(messages))
+	for _, message := range messages {
+		byID[message.ID] = message
+	}
+	result := &entity.MessageSearchResult{}
+	for _, id := range ids {
+		message, ok := byID[id]
+		if !ok || message.DeletedAt != nil {
+			continue
+		}
+		result.Hits = append(result.Hits, &entity.MessageSearchHit{
+			Message: message,
+			Snippet: buildSnippet(message.Message, query.Terms),
+		})
+	}
+	if hasNext && len(result.Hits) > 0 {
+		last := result.Hits[len(result.Hits)-1].Message
+		result.Next = &entity.MessageCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	return result, nil
+}
+
+// BuildSearchIndex 全スレッドのメッセージを索引に入れ直す。プロセス内の索引を使うときに起動時に呼ぶ。
+// アーカイブしたスレッドもメンバーは検索できるので含める
+func (ms *messageService) BuildSearchIndex() error {
+	threadIDs, err := ms.threadRepository.FindAllIDs()
+	if err != nil {
+		return errors.Wrap(err, "failed to get threads")
+	}
+	for _, threadID := range threadIDs {
+		var cursor *entity.MessageCursor
+		for {
+			messages, err := ms.messageRepository.GetByThreadIDBefore(threadID, cursor, constants.MessagePageSizeMax)
+			if err != nil {
+				return errors.Wrap(err, "failed to get messages")
+			}
+			for _, message := range messages {
+				if message.DeletedAt != nil {
+					continue
+				}
+				if err = ms.searchIndex.Index(message); err != nil {
+					return errors.Wrap(err, "failed to index message")
+				}
+			}
+			if len(messages) < constants.MessagePageSizeMax {
+				break
+			}
+			last := messages[len(messages)-1]
+			cursor = &entity.MessageCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		}
+	}
+	return nil
+}
+
+// buildSnippet 最初に一致した語の周りを切り出し、一致した語を<mark>で囲む。それ以外はHTMLエスケープする
+func buildSnippet(text string, terms []string) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	lowerTerms := make([][]rune, 0, len(terms))
+	for _, term := range terms {
+		if term == "" {
+			continue
+		}
+		lowerTerm := []rune(term)
+		for i, r := range lowerTerm {
+			lowerTerm[i] = unicode.ToLower(r)
+		}
+		lowerTerms = append(lowerTerms, lowerTerm)
+	}
+
+	// 一致した範囲を前から重ならないように集める
+	var marks [][2]int
+	for i := 0; i < len(lower); {
+		matched := 0
+		for _, term := range lowerTerms {
+			if len(term) > matched && hasRunePrefix(lower[i:], term) {
+				matched = len(term)
+			}
+		}
+		if matched == 0 {
+			i++
+			continue
+		}
+		marks = append(marks, [2]int{i, i + matched})
+		i += matched
+	}
+
+	start := 0
+	if len(marks) > 0 {
+		start = marks[0][0] - constants.SnippetLength/3
+		if start < 0 {
+			start = 0
+		}
+	}
+	end := start + constants.SnippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, mark := range marks {
+		if mark[1] <= start || mark[0] >= end {
+			continue
+		}
+		from, to := mark[0], mark[1]
+		if from < pos {
+			from = pos
+		}
+		if to > end {
+			to = end
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:from])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[from:to])))
+		b.WriteString("</mark>")
+		pos = to
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:end])))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func hasRunePrefix(s, prefix []rune) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	for i, r := range prefix {
+		if s[i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+// GetMentioned userUUIDのユーザがメンションされたメッセージを新しい順に返す。続きがあれば次のbeforeに渡すcursorも返す
+func (ms *messageService) GetMentioned(userUUID string, before *entity.MessageCursor, limit int) ([]*entity.Message, *entity.MessageCursor, error) {
+	messages, err := ms.messageRepository.FindMentionedByUserID(userUUID, before, limit+1)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get mentioned messages")
+	}
+	if len(messages) <= limit {
+		return messages, nil, nil
+	}
+	messages = messages[:limit]
+	last := messages[len(messages)-1]
+	return messages, &entity.MessageCursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
+}
+
 func (ms *messageService) GetByID(id string) (*entity.Message, error) {
 	message, err := ms.messageRepository.GetByID(id)
 	if err != nil {
@@ -53,21 +386,296 @@ func (ms *messageService) GetByID(id string) (*entity.Message, error) {
 	return message, nil
 }
 
-func (ms *messageService) GetByThreadID(threadID string) ([]*entity.Message, error) {
-	messages, err := ms.messageRepository.GetByThreadID(threadID)
+// GetReplyChain messageの返信元を根まで辿り、messageへの返信を古い順にlimit件付ける
+func (ms *messageService) GetReplyChain(message *entity.Message, limit int) (*entity.ReplyChain, error) {
+	var ancestors []*entity.Message
+	for parent := message.Parent; parent != nil && len(ancestors) < limit; {
+		ancestor, err := ms.messageRepository.GetByID(parent.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get parent message")
+		}
+		ancestors = append([]*entity.Message{ancestor}, ancestors...)
+		parent = ancestor.Parent
+	}
+	replies, err := ms.messageRepository.GetReplies(message.ID, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get replies")
+	}
+	return &entity.ReplyChain{
+		Ancestors: ancestors,
+		Message:   message,
+		Replies:   replies,
+	}, nil
+}
+
+// CheckEditable 投稿者本人が、投稿からeditWindow以内のファイル以外のメッセージだけ編集できる
+func (ms *messageService) CheckEditable(message *entity.Message, editor *entity.User) error {
+	if message.DeletedAt != nil {
+		return errors.New("message is deleted")
+	}
+	if message.Author.ID != editor.ID {
+		return errors.New("only the author can edit the message")
+	}
+	if message.Grade == constants.FileMessageGrade {
+		return errors.New("file message cannot be edited")
+	}
+	if time.Since(*message.CreatedAt) > ms.editWindow {
+		return errors.New("edit window has expired")
+	}
+	return nil
+}
+
+// Edit 編集前の本文をrevisionとして残す
+func (ms *messageService) Edit(message *entity.Message, body string) (*entity.Message, error) {
+	id, err := GenerateUUID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate id")
+	}
+	now := time.Now()
+	revision := &entity.MessageRevision{
+		ID:       id,
+		Message:  message,
+		Body:     message.Message,
+		EditedAt: &now,
+	}
+	message.Message = body
+	message.EditedAt = &now
+	if err = ms.messageRepository.Update(message, revision); err != nil {
+		return nil, errors.Wrap(err, "failed to update message")
+	}
+	if err = ms.searchIndex.Index(message); err != nil {
+		return nil, errors.Wrap(err, "failed to index message")
+	}
+	return message, nil
+}
+
+// CheckDeletable 投稿者本人かスレッドの管理者だけ削除できる
+func (ms *messageService) CheckDeletable(message *entity.Message, user *entity.User, isThreadAdmin bool) error {
+	if message.DeletedAt != nil {
+		return errors.New("message is already deleted")
+	}
+	if message.Author.ID != user.ID && !isThreadAdmin {
+		return errors.New("only the author or thread admins can delete the message")
+	}
+	return nil
+}
+
+func (ms *messageService) Delete(message *entity.Message, user *entity.User, reason string) (*entity.Message, error) {
+	id, err := GenerateUUID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate id")
+	}
+	now := time.Now()
+	message.Message = ""
+	message.DeletedAt = &now
+	deletion := &entity.MessageDeletion{
+		ID:        id,
+		Message:   message,
+		Thread:    message.Thread,
+		Author:    message.Author,
+		DeletedBy: user,
+		Reason:    reason,
+		CreatedAt: &now,
+	}
+	if err = ms.messageRepository.Delete(message, deletion); err != nil {
+		return nil, errors.Wrap(err, "failed to delete message")
+	}
+	if err = ms.searchIndex.Remove(message.ID); err != nil {
+		return nil, errors.Wrap(err, "failed to remove message from index")
+	}
+	return message, nil
+}
+
+func (ms *messageService) GetDeletionsByThreadID(threadID string) ([]*entity.MessageDeletion, error) {
+	deletions, err := ms.messageRepository.FindDeletionsByThreadID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get deletions")
+	}
+	return deletions, nil
+}
+
+func (ms *messageService) GetRevisions(messageID string) ([]*entity.MessageRevision, error) {
+	revisions, err := ms.messageRepository.FindRevisionsByMessageID(messageID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get revisions")
+	}
+	return revisions, nil
+}
+
+// GetAllByThreadID 削除されたものも含めて古い順に全件返す
+func (ms *messageService) GetAllByThreadID(threadID string) ([]*entity.Message, error) {
+	var messages []*entity.Message
+	var before *entity.MessageCursor
+	for {
+		page, err := ms.messageRepository.GetByThreadIDBefore(threadID, before, constants.MessagePageSizeMax)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get messages")
+		}
+		messages = append(messages, page...)
+		if len(page) < constants.MessagePageSizeMax {
+			break
+		}
+		last := page[len(page)-1]
+		before = &entity.MessageCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// GetPageByThreadID afterがあればその後ろから、なければbefore(nilなら最新)の手前からlimit件を古い順に返す
+func (ms *messageService) GetPageByThreadID(threadID string, before, after *entity.MessageCursor, limit int) (*entity.MessagePage, error) {
+	var messages []*entity.Message
+	var err error
+	var hasOlder, hasNewer bool
+	if after != nil {
+		// 1件多く取って次のページがあるか判定する
+		messages, err = ms.messageRepository.GetByThreadIDAfter(threadID, after, limit+1)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get messages")
+		}
+		hasNewer = len(messages) > limit
+		if hasNewer {
+			messages = messages[:limit]
+		}
+		hasOlder = true
+	} else {
+		messages, err = ms.messageRepository.GetByThreadIDBefore(threadID, before, limit+1)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get messages")
+		}
+		hasOlder = len(messages) > limit
+		if hasOlder {
+			messages = messages[:limit]
+		}
+		hasNewer = before != nil
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	page := &entity.MessagePage{Messages: messages}
+	if len(messages) == 0 {
+		return page, nil
+	}
+	if hasOlder {
+		page.Prev = &entity.MessageCursor{CreatedAt: messages[0].CreatedAt, ID: messages[0].ID}
+	}
+	if hasNewer {
+		last := messages[len(messages)-1]
+		page.Next = &entity.MessageCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	return page, nil
+}
+
+// GetLatestByThreadIDAfter cursorより後のメッセージのうち新しいものからlimit件を古い順に並べて返す
+func (ms *messageService) GetLatestByThreadIDAfter(threadID string, createdAt *time.Time, id string, limit int) ([]*entity.Message, error) {
+	messages, err := ms.messageRepository.GetLatestByThreadIDAfter(threadID, createdAt, id, limit)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get messages")
 	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
 	return messages, nil
 }
 
-func (ms *messageService) AddFavorite(messageID, userUUID string) error {
+// AddReaction 既に同じリアクションをしていればfalse
+func (ms *messageService) AddReaction(message *entity.Message, user *entity.User, reaction string) (bool, error) {
+	if message.DeletedAt != nil {
+		return false, errors.New("message is deleted")
+	}
 	id, err := GenerateUUID()
 	if err != nil {
-		return errors.Wrap(err, "failed to generate id")
+		return false, errors.Wrap(err, "failed to generate id")
+	}
+	now := time.Now()
+	added, err := ms.messageRepository.AddReaction(id, message.ID, user.ID, reaction, &now)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to add reaction")
+	}
+	return added, nil
+}
+
+// RemoveReaction リアクションしていなければfalse
+func (ms *messageService) RemoveReaction(message *entity.Message, user *entity.User, reaction string) (bool, error) {
+	removed, err := ms.messageRepository.RemoveReaction(message.ID, user.ID, reaction)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to remove reaction")
+	}
+	return removed, nil
+}
+
+// AttachReactions messagesにリアクションの集計を付ける。userUUIDのユーザがリアクションしたかも判定する
+func (ms *messageService) AttachReactions(messages []*entity.Message, userUUID string) error {
+	ids := make([]string, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+	reactions, err := ms.messageRepository.CountReactions(ids, userUUID)
+	if err != nil {
+		return errors.Wrap(err, "failed to count reactions")
 	}
-	if err = ms.messageRepository.AddFavorite(id, messageID, userUUID); err != nil {
-		return errors.Wrap(err, "failed to add relation")
+	for _, message := range messages {
+		message.Reactions = reactions[message.ID]
 	}
 	return nil
 }
+
+// MarkAsRead 既読位置をmessageまで進める。既にmessage以降まで読んでいる場合は更新せずfalseを返す
+func (ms *messageService) MarkAsRead(user *entity.User, message *entity.Message) (*entity.ReadMarker, bool, error) {
+	marker, err := ms.messageRepository.FindReadMarker(message.Thread.ID, user.ID)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to get read marker")
+	}
+	if marker != nil && !isAfter(message, marker.Message) {
+		marker.User = user
+		return marker, false, nil
+	}
+	if marker == nil {
+		id, err := GenerateUUID()
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to generate id")
+		}
+		marker = &entity.ReadMarker{ID: id}
+	}
+	now := time.Now()
+	marker.User = user
+	marker.Thread = message.Thread
+	marker.Message = message
+	marker.ReadAt = &now
+	if err = ms.messageRepository.SaveReadMarker(marker); err != nil {
+		return nil, false, errors.Wrap(err, "failed to save read marker")
+	}
+	return marker, true, nil
+}
+
+func (ms *messageService) GetReadMarkersByThreadID(threadID string) ([]*entity.ReadMarker, error) {
+	markers, err := ms.messageRepository.FindReadMarkersByThreadID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get read markers")
+	}
+	return markers, nil
+}
+
+// GetUnreadCounts スレッドIDをkeyにした未読数
+func (ms *messageService) GetUnreadCounts(userUUID string) (map[string]*entity.UnreadCount, error) {
+	counts, err := ms.messageRepository.CountUnreadByUserID(userUUID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count unread messages")
+	}
+	result := make(map[string]*entity.UnreadCount, len(counts))
+	for _, count := range counts {
+		result[count.ThreadID] = count
+	}
+	return result, nil
+}
+
+// isAfter (created_at, id)の順でaがbより後か
+func isAfter(a, b *entity.Message) bool {
+	if a.CreatedAt.Equal(*b.CreatedAt) {
+		return a.ID > b.ID
+	}
+	return a.CreatedAt.After(*b.CreatedAt)
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"app/api/constants"
 	"app/api/domain/entity"
 	"app/api/domain/repository"
 	"time"
@@ -9,16 +10,45 @@ import (
 )
 
 type ThreadService interface {
-	New(name, description string, limitUsers, isPublic int, author *entity.User) (*entity.Thread, error)
+	New(name, description string, limitUsers, isPublic int, joinPolicy entity.JoinPolicy, author *entity.User) (*entity.Thread, error)
 	GetAll() ([]*entity.Thread, error)
 	GetByID(id string) (*entity.Thread, error)
 	GetByUserID(userID string) ([]*entity.Thread, error)
 	GetOnlyPublic() ([]*entity.Thread, error)
+	Search(query *entity.ThreadSearchQuery) (*entity.ThreadSearchResult, error)
+	GetRecommendations(userUUID string, limit int) ([]*entity.ThreadRecommendation, error)
+	ExplainRecommendation(rec *entity.ThreadRecommendation, userTags []*entity.Tag)
 	GetMembersByThreadID(id string) ([]*entity.User, error)
-	Update(thread *entity.Thread, name, description string, limitUsers, isPublic int) (*entity.Thread, error)
+	Update(thread *entity.Thread, name, description string, limitUsers, isPublic int, joinPolicy entity.JoinPolicy) (*entity.Thread, error)
 	Delete(id string) error
-	AddMember(threadID, userID string, isAdmin int) error
+	Archive(thread *entity.Thread) (bool, error)
+	Restore(thread *entity.Thread) (bool, error)
+	CheckWritable(threadID string) error
+	AddMember(threadID, userID string, role entity.Role) error
+	AddMemberOrWait(thread *entity.Thread, user *entity.User) (*entity.WaitlistEntry, error)
+	GetWaitlist(threadID string) ([]*entity.WaitlistEntry, error)
+	GetWaitlistEntry(threadID, userID string) (*entity.WaitlistEntry, error)
+	LeaveWaitlist(threadID, userID string) (bool, error)
+	PromoteWaitlist(threadID string) ([]*entity.WaitlistEntry, error)
 	RemoveMember(threadID, userID string) error
+	Leave(thread *entity.Thread, user *entity.User) (*entity.Succession, error)
+	TransferOwnership(thread *entity.Thread, owner, target *entity.User) error
+	IsAdmin(threadID, userID string) (bool, error)
+	GetRole(threadID, userID string) (entity.Role, error)
+	GetRoles(threadID string) (map[string]entity.Role, error)
+	CheckPermission(threadID, userID string, permission entity.Permission) error
+	ChangeRole(threadID string, requester, target *entity.User, role entity.Role) error
+	CheckRemovable(threadID string, requester, target *entity.User) error
+	Ban(thread *entity.Thread, target, requester *entity.User, reason string, expiresAt *time.Time) (*entity.ThreadBan, error)
+	Unban(threadID, userID string) (bool, error)
+	GetBans(threadID string) ([]*entity.ThreadBan, error)
+	IsBanned(threadID, userID string) (bool, error)
+	RequestToJoin(thread *entity.Thread, user *entity.User) (*entity.JoinRequest, error)
+	GetJoinRequest(id string) (*entity.JoinRequest, error)
+	GetPendingJoinRequest(threadID, userID string) (*entity.JoinRequest, error)
+	GetJoinRequests(threadID string, status entity.JoinRequestStatus) ([]*entity.JoinRequest, error)
+	GetJoinRequestsByUserID(userID string) ([]*entity.JoinRequest, error)
+	DecideJoinRequest(request *entity.JoinRequest, decider *entity.User, approve bool) (bool, error)
 }
 
 type threadService struct {
@@ -33,7 +63,7 @@ func NewThreadService(tr repository.ThreadRepository, fr repository.FileReposito
 	}
 }
 
-func (ts *threadService) New(name, description string, limitUsers, isPublic int, author *entity.User) (*entity.Thread, error) {
+func (ts *threadService) New(name, description string, limitUsers, isPublic int, joinPolicy entity.JoinPolicy, author *entity.User) (*entity.Thread, error) {
 	id, err := GenerateUUID()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to generate uuid")
@@ -45,6 +75,7 @@ func (ts *threadService) New(name, description string, limitUsers, isPublic int,
 		Description: description,
 		LimitUsers:  limitUsers,
 		IsPublic:    isPublic,
+		JoinPolicy:  joinPolicy,
 		Author:      author,
 		CreatedAt:   &now,
 		UpdatedAt:   &now,
@@ -66,6 +97,57 @@ func (ts *threadService) GetAll() ([]*entity.Thread, error) {
 	return threads, nil
 }
 
+// Search 1件多く取って次のページがあるか判定する
+func (ts *threadService) Search(query *entity.ThreadSearchQuery) (*entity.ThreadSearchResult, error) {
+	limit := query.Limit
+	q := *query
+	q.Limit = limit + 1
+	hits, err := ts.threadRepository.Search(&q)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search threads")
+	}
+	result := &entity.ThreadSearchResult{Hits: hits}
+	if len(hits) > limit {
+		result.Hits = hits[:limit]
+		result.HasNext = true
+	}
+	return result, nil
+}
+
+func (ts *threadService) GetRecommendations(userUUID string, limit int) ([]*entity.ThreadRecommendation, error) {
+	activeSince := time.Now().Add(-constants.RecommendActivityWindow)
+	recommendations, err := ts.threadRepository.FindRecommendations(userUUID, &activeSince, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get recommendations")
+	}
+	return recommendations, nil
+}
+
+// ExplainRecommendation rec.Thread.Tagsのうち、userTagsと同じタグと、同じカテゴリの別のタグのカテゴリを記録する
+func (ts *threadService) ExplainRecommendation(rec *entity.ThreadRecommendation, userTags []*entity.Tag) {
+	tagIDs := make(map[string]bool, len(userTags))
+	categoryIDs := make(map[string]bool, len(userTags))
+	for _, tag := range userTags {
+		tagIDs[tag.ID] = true
+		if tag.Category != nil {
+			categoryIDs[tag.Category.ID] = true
+		}
+	}
+	rec.MatchedTags = nil
+	rec.MatchedCategories = nil
+	seen := make(map[string]bool)
+	for _, tag := range rec.Thread.Tags {
+		if tagIDs[tag.ID] {
+			rec.MatchedTags = append(rec.MatchedTags, tag)
+			continue
+		}
+		if tag.Category != nil && categoryIDs[tag.Category.ID] && !seen[tag.Category.ID] {
+			seen[tag.Category.ID] = true
+			rec.MatchedCategories = append(rec.MatchedCategories, tag.Category)
+		}
+	}
+}
+
 func (ts *threadService) GetByID(id string) (*entity.Thread, error) {
 	thread, err := ts.threadRepository.FindByID(id)
 	if err != nil {
@@ -98,13 +180,14 @@ func (ts *threadService) GetMembersByThreadID(id string) ([]*entity.User, error)
 	return members, nil
 }
 
-func (ts *threadService) Update(thread *entity.Thread, name, description string, limitUsers, isPublic int) (*entity.Thread, error) {
+func (ts *threadService) Update(thread *entity.Thread, name, description string, limitUsers, isPublic int, joinPolicy entity.JoinPolicy) (*entity.Thread, error) {
 	now := time.Now()
 	thread.UpdatedAt = &now
 	thread.Name = name
 	thread.Description = description
 	thread.LimitUsers = limitUsers
 	thread.IsPublic = isPublic
+	thread.JoinPolicy = joinPolicy
 	err := ts.threadRepository.Update(thread)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to update thread")
@@ -112,27 +195,486 @@ func (ts *threadService) Update(thread *entity.Thread, name, description string,
 	return thread, nil
 }
 
+// Delete 書き出したzipはDBから消せてから消す
 func (ts *threadService) Delete(id string) error {
 	if err := ts.threadRepository.Delete(id); err != nil {
 		return errors.Wrap(err, "failed to delete")
 	}
+	if err := ts.fileRepository.DeleteExports(id); err != nil {
+		return errors.Wrap(err, "failed to delete exports")
+	}
+	return nil
+}
+
+// Archive 既にアーカイブ中ならfalse
+func (ts *threadService) Archive(thread *entity.Thread) (bool, error) {
+	now := time.Now()
+	archived, err := ts.threadRepository.Archive(thread.ID, now)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to archive thread")
+	}
+	if archived {
+		thread.ArchivedAt = &now
+	}
+	return archived, nil
+}
+
+// Restore アーカイブしていなければfalse
+func (ts *threadService) Restore(thread *entity.Thread) (bool, error) {
+	restored, err := ts.threadRepository.Restore(thread.ID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to restore thread")
+	}
+	if restored {
+		thread.ArchivedAt = nil
+	}
+	return restored, nil
+}
+
+// CheckWritable アーカイブ中のスレッドは読み取り専用
+func (ts *threadService) CheckWritable(threadID string) error {
+	thread, err := ts.GetByID(threadID)
+	if err != nil {
+		return err
+	}
+	if thread.IsArchived() {
+		return errors.New("thread is archived")
+	}
 	return nil
 }
 
-func (ts *threadService) AddMember(threadID, userID string, isAdmin int) error {
+// AddMember 有効なBANがあるユーザは追加できない
+func (ts *threadService) AddMember(threadID, userID string, role entity.Role) error {
+	banned, err := ts.IsBanned(threadID, userID)
+	if err != nil {
+		return err
+	}
+	if banned {
+		return errors.New("user is banned from thread")
+	}
 	id, err := GenerateUUID()
 	if err != nil {
 		return errors.Wrap(err, "failed to generate uuid")
 	}
-	if err = ts.threadRepository.AddMember(id, threadID, userID, isAdmin); err != nil {
+	added, err := ts.threadRepository.AddMember(id, threadID, userID, role)
+	if err != nil {
 		return errors.Wrap(err, "failed to add member")
 	}
+	if !added {
+		return errors.New("thread is full")
+	}
 	return nil
 }
 
+// AddMemberOrWait 満員なら参加待ちに入れて順番付きで返す。メンバーになれたときはnilを返す
+func (ts *threadService) AddMemberOrWait(thread *entity.Thread, user *entity.User) (*entity.WaitlistEntry, error) {
+	banned, err := ts.IsBanned(thread.ID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if banned {
+		return nil, errors.New("user is banned from thread")
+	}
+	id, err := GenerateUUID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate uuid")
+	}
+	now := time.Now()
+	entry := &entity.WaitlistEntry{
+		ID:        id,
+		Thread:    thread,
+		User:      user,
+		CreatedAt: &now,
+	}
+	added, err := ts.threadRepository.AddMemberOrWait(entry)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to add member")
+	}
+	if added {
+		return nil, nil
+	}
+	waiting, err := ts.GetWaitlistEntry(thread.ID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if waiting != nil {
+		entry.Position = waiting.Position
+	}
+	return entry, nil
+}
+
+func (ts *threadService) GetWaitlist(threadID string) ([]*entity.WaitlistEntry, error) {
+	entries, err := ts.threadRepository.FindWaitlistByThreadID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get waitlist")
+	}
+	return entries, nil
+}
+
+// GetWaitlistEntry 参加待ちでなければnilを返す
+func (ts *threadService) GetWaitlistEntry(threadID, userID string) (*entity.WaitlistEntry, error) {
+	entries, err := ts.GetWaitlist(threadID)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.User.ID == userID {
+			return entry, nil
+		}
+	}
+	return nil, nil
+}
+
+// LeaveWaitlist 参加待ちでなければfalse
+func (ts *threadService) LeaveWaitlist(threadID, userID string) (bool, error) {
+	removed, err := ts.threadRepository.RemoveFromWaitlist(threadID, userID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to leave waitlist")
+	}
+	return removed, nil
+}
+
+// PromoteWaitlist 空いた分だけ参加待ちを順にメンバーにして、上げたものを返す
+func (ts *threadService) PromoteWaitlist(threadID string) ([]*entity.WaitlistEntry, error) {
+	var promoted []*entity.WaitlistEntry
+	for {
+		memberID, err := GenerateUUID()
+		if err != nil {
+			return promoted, errors.Wrap(err, "failed to generate uuid")
+		}
+		entry, err := ts.threadRepository.PromoteFromWaitlist(threadID, memberID)
+		if err != nil {
+			return promoted, errors.Wrap(err, "failed to promote waitlist")
+		}
+		if entry == nil {
+			return promoted, nil
+		}
+		promoted = append(promoted, entry)
+	}
+}
+
 func (ts *threadService) RemoveMember(threadID, userID string) error {
 	if err := ts.threadRepository.RemoveMember(threadID, userID); err != nil {
 		return errors.Wrap(err, "failed to remove member")
 	}
 	return nil
 }
+
+// Leave ownerが抜けるときは後任を決める。ownerでなければnilを返す
+func (ts *threadService) Leave(thread *entity.Thread, user *entity.User) (*entity.Succession, error) {
+	role, err := ts.GetRole(thread.ID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if role != entity.RoleOwner {
+		return nil, ts.RemoveMember(thread.ID, user.ID)
+	}
+
+	successorID, err := ts.threadRepository.RemoveOwner(thread.ID, user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to remove owner")
+	}
+	succession := &entity.Succession{Thread: thread}
+	if successorID != "" {
+		succession.NewOwner = &entity.User{ID: successorID}
+		thread.Author = succession.NewOwner
+		return succession, nil
+	}
+	// 誰も残っていなければ、履歴のないスレッドは削除し、あればアーカイブして残す
+	if succession.Deleted, err = ts.threadRepository.DeleteIfEmpty(thread.ID); err != nil {
+		return nil, errors.Wrap(err, "failed to delete thread")
+	}
+	if !succession.Deleted {
+		if _, err = ts.Archive(thread); err != nil {
+			return nil, err
+		}
+	}
+	return succession, nil
+}
+
+// TransferOwnership ownerだけが他のメンバーに譲れる。元のownerはmoderatorになる
+func (ts *threadService) TransferOwnership(thread *entity.Thread, owner, target *entity.User) error {
+	if owner.ID == target.ID {
+		return errors.New("cannot transfer to yourself")
+	}
+	role, err := ts.GetRole(thread.ID, owner.ID)
+	if err != nil {
+		return err
+	}
+	if role != entity.RoleOwner {
+		return errors.New("not owner of thread")
+	}
+	transferred, err := ts.threadRepository.TransferOwner(thread.ID, owner.ID, target.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to transfer owner")
+	}
+	if !transferred {
+		return errors.New("user is not member of thread")
+	}
+	thread.Author = target
+	return nil
+}
+
+// CheckRemovable kick権限があり、自分より下の役割のメンバーだけを外せる。
+// メンバーでないユーザ(BANするだけ)も外せる
+func (ts *threadService) CheckRemovable(threadID string, requester, target *entity.User) error {
+	if requester.ID == target.ID {
+		return errors.New("cannot remove yourself")
+	}
+	if err := ts.CheckPermission(threadID, requester.ID, entity.PermissionKick); err != nil {
+		return err
+	}
+	requesterRole, err := ts.GetRole(threadID, requester.ID)
+	if err != nil {
+		return err
+	}
+	targetRole, err := ts.GetRole(threadID, target.ID)
+	if err != nil {
+		return err
+	}
+	if targetRole != "" && !requesterRole.Outranks(targetRole) {
+		return errors.New("cannot remove a " + string(targetRole))
+	}
+	return nil
+}
+
+// GetRole メンバーでなければ空文字を返す
+func (ts *threadService) GetRole(threadID, userID string) (entity.Role, error) {
+	role, err := ts.threadRepository.FindRole(threadID, userID)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get role")
+	}
+	return role, nil
+}
+
+func (ts *threadService) GetRoles(threadID string) (map[string]entity.Role, error) {
+	roles, err := ts.threadRepository.FindRolesByThreadID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get roles")
+	}
+	return roles, nil
+}
+
+// CheckPermission 権限がなければエラーを返す
+func (ts *threadService) CheckPermission(threadID, userID string, permission entity.Permission) error {
+	role, err := ts.GetRole(threadID, userID)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return errors.New("not member of thread")
+	}
+	if !role.Can(permission) {
+		return errors.New("no permission to " + string(permission))
+	}
+	// アーカイブ中はアーカイブの解除と書き出しと削除しかできない
+	if permission == entity.PermissionArchive || permission == entity.PermissionExport || permission == entity.PermissionDeleteThread {
+		return nil
+	}
+	return ts.CheckWritable(threadID)
+}
+
+// ChangeRole 自分より下の役割のメンバーを、自分より下の役割にだけ変更できる。
+// ownerは移譲でしか変わらない
+func (ts *threadService) ChangeRole(threadID string, requester, target *entity.User, role entity.Role) error {
+	if !role.IsValid() {
+		return errors.New("invalid role")
+	}
+	if role == entity.RoleOwner {
+		return errors.New("owner cannot be assigned")
+	}
+	if requester.ID == target.ID {
+		return errors.New("cannot change your own role")
+	}
+	requesterRole, err := ts.GetRole(threadID, requester.ID)
+	if err != nil {
+		return err
+	}
+	if !requesterRole.IsAdmin() {
+		return errors.New("not admin of thread")
+	}
+	targetRole, err := ts.GetRole(threadID, target.ID)
+	if err != nil {
+		return err
+	}
+	if targetRole == "" {
+		return errors.New("user is not member of thread")
+	}
+	if !requesterRole.Outranks(targetRole) || !requesterRole.Outranks(role) {
+		return errors.New("cannot change role to " + string(role))
+	}
+	updated, err := ts.threadRepository.UpdateRole(threadID, target.ID, role)
+	if err != nil {
+		return errors.Wrap(err, "failed to update role")
+	}
+	if !updated {
+		return errors.New("user is not member of thread")
+	}
+	return nil
+}
+
+// Ban メンバーから外し、expiresAtまで参加できないようにする
+func (ts *threadService) Ban(thread *entity.Thread, target, requester *entity.User, reason string, expiresAt *time.Time) (*entity.ThreadBan, error) {
+	id, err := GenerateUUID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate uuid")
+	}
+	now := time.Now()
+	ban := &entity.ThreadBan{
+		ID:        id,
+		Thread:    thread,
+		User:      target,
+		BannedBy:  requester,
+		Reason:    reason,
+		ExpiresAt: expiresAt,
+		CreatedAt: &now,
+	}
+	if err = ts.threadRepository.SaveBan(ban); err != nil {
+		return nil, errors.Wrap(err, "failed to save ban")
+	}
+	return ban, nil
+}
+
+func (ts *threadService) Unban(threadID, userID string) (bool, error) {
+	removed, err := ts.threadRepository.RemoveBan(threadID, userID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to remove ban")
+	}
+	return removed, nil
+}
+
+// IsBanned 期限切れのBANは無視する
+func (ts *threadService) IsBanned(threadID, userID string) (bool, error) {
+	ban, err := ts.threadRepository.FindBan(threadID, userID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get ban")
+	}
+	return ban != nil && ban.IsActive(time.Now()), nil
+}
+
+// RequestToJoin 承認待ちの申請があればエラーを返す
+func (ts *threadService) RequestToJoin(thread *entity.Thread, user *entity.User) (*entity.JoinRequest, error) {
+	pending, err := ts.GetPendingJoinRequest(thread.ID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		return nil, errors.New("already requested to join")
+	}
+	id, err := GenerateUUID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate uuid")
+	}
+	now := time.Now()
+	request := &entity.JoinRequest{
+		ID:        id,
+		Thread:    thread,
+		User:      user,
+		Status:    entity.JoinRequestPending,
+		CreatedAt: &now,
+	}
+	if err = ts.threadRepository.SaveJoinRequest(request); err != nil {
+		return nil, errors.Wrap(err, "failed to save join request")
+	}
+	return request, nil
+}
+
+func (ts *threadService) GetJoinRequest(id string) (*entity.JoinRequest, error) {
+	request, err := ts.threadRepository.FindJoinRequestByID(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get join request")
+	}
+	return request, nil
+}
+
+// GetPendingJoinRequest 承認待ちの申請がなければnilを返す
+func (ts *threadService) GetPendingJoinRequest(threadID, userID string) (*entity.JoinRequest, error) {
+	request, err := ts.threadRepository.FindJoinRequest(threadID, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get join request")
+	}
+	if request == nil || request.Status != entity.JoinRequestPending {
+		return nil, nil
+	}
+	return request, nil
+}
+
+func (ts *threadService) GetJoinRequests(threadID string, status entity.JoinRequestStatus) ([]*entity.JoinRequest, error) {
+	requests, err := ts.threadRepository.FindJoinRequestsByThreadID(threadID, status)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get join requests")
+	}
+	return requests, nil
+}
+
+func (ts *threadService) GetJoinRequestsByUserID(userID string) ([]*entity.JoinRequest, error) {
+	requests, err := ts.threadRepository.FindJoinRequestsByUserID(userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get join requests")
+	}
+	return requests, nil
+}
+
+// DecideJoinRequest 承認したときはメンバーに追加し、満員なら参加待ちに入れてrequest.Waitlistに返す。
+// 既に決まっていた申請ならfalse
+func (ts *threadService) DecideJoinRequest(request *entity.JoinRequest, decider *entity.User, approve bool) (bool, error) {
+	if request.Status != entity.JoinRequestPending {
+		return false, nil
+	}
+	status := entity.JoinRequestRejected
+	if approve {
+		banned, err := ts.IsBanned(request.Thread.ID, request.User.ID)
+		if err != nil {
+			return false, err
+		}
+		if banned {
+			return false, errors.New("user is banned from thread")
+		}
+		status = entity.JoinRequestApproved
+	}
+	id, err := GenerateUUID()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to generate uuid")
+	}
+	now := time.Now()
+	request.Status = status
+	request.DecidedBy = decider
+	request.DecidedAt = &now
+	entry := &entity.WaitlistEntry{
+		ID:        id,
+		Thread:    request.Thread,
+		User:      request.User,
+		CreatedAt: &now,
+	}
+	decided, err := ts.threadRepository.DecideJoinRequest(request, entry)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to decide join request")
+	}
+	if request.Waitlist != nil {
+		waiting, err := ts.GetWaitlistEntry(request.Thread.ID, request.User.ID)
+		if err != nil {
+			return false, err
+		}
+		if waiting != nil {
+			request.Waitlist.Position = waiting.Position
+		}
+	}
+	return decided, nil
+}
+
+func (ts *threadService) GetBans(threadID string) ([]*entity.ThreadBan, error) {
+	bans, err := ts.threadRepository.FindBansByThreadID(threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get bans")
+	}
+	return bans, nil
+}
+
+// IsAdmin モデレーター以上か
+func (ts *threadService) IsAdmin(threadID, userID string) (bool, error) {
+	role, err := ts.GetRole(threadID, userID)
+	if err != nil {
+		return false, err
+	}
+	return role.IsAdmin(), nil
+}
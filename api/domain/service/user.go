@@ -1,8 +1,10 @@
 package service
 
 import (
+	"app/api/constants"
 	"app/api/domain/entity"
 	"app/api/domain/repository"
+	"os"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,6 +14,7 @@ import (
 type userService struct {
 	userRepository repository.UserRepository
 	fileRepository repository.FileRepository
+	gracePeriod    time.Duration
 }
 
 type UserService interface {
@@ -23,7 +26,9 @@ type UserService interface {
 	GetByUserID(userID string) (*entity.User, error)
 	GetByMail(mail string) (*entity.User, error)
 	GetAll() ([]*entity.User, error)
-	Delete(id string) error
+	Delete(user *entity.User) (*entity.AccountDeletion, error)
+	CancelDeletion(user *entity.User) (bool, error)
+	PurgeScheduled() ([]*entity.AccountDeletion, error)
 	GetFollows(id string) ([]*entity.User, error)
 	AddFollow(userID, followedUserID string) error
 	DeleteFollow(userID, followedUserID string) error
@@ -31,9 +36,14 @@ type UserService interface {
 }
 
 func NewUserService(ur repository.UserRepository, fr repository.FileRepository) UserService {
+	gracePeriod := constants.AccountDeletionGracePeriod
+	if v, err := time.ParseDuration(os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD")); err == nil && v >= 0 {
+		gracePeriod = v
+	}
 	return &userService{
 		userRepository: ur,
 		fileRepository: fr,
+		gracePeriod:    gracePeriod,
 	}
 }
 
@@ -138,12 +148,57 @@ func (us *userService) GetAll() ([]*entity.User, error) {
 	return users, nil
 }
 
-func (us *userService) Delete(id string) error {
-	err := us.userRepository.DeleteByID(id)
+// Delete 猶予期間があれば削除を予約するだけで、期間内にログインすれば取り消される
+func (us *userService) Delete(user *entity.User) (*entity.AccountDeletion, error) {
+	if user.ID == constants.DeletedUserID {
+		return nil, errors.New("cannot delete placeholder user")
+	}
+	if us.gracePeriod > 0 {
+		at := time.Now().Add(us.gracePeriod)
+		if err := us.userRepository.ScheduleDeletion(user.ID, at); err != nil {
+			return nil, errors.Wrap(err, "failed to schedule deletion")
+		}
+		return &entity.AccountDeletion{User: user, ScheduledAt: &at}, nil
+	}
+	return us.purge(user)
+}
+
+// CancelDeletion 削除待ちでなければfalse
+func (us *userService) CancelDeletion(user *entity.User) (bool, error) {
+	canceled, err := us.userRepository.CancelDeletion(user.ID)
 	if err != nil {
-		return errors.Wrap(err, "failed to delete")
+		return false, errors.Wrap(err, "failed to cancel deletion")
 	}
-	return nil
+	return canceled, nil
+}
+
+// PurgeScheduled 猶予期間を過ぎたユーザを削除する
+func (us *userService) PurgeScheduled() ([]*entity.AccountDeletion, error) {
+	ids, err := us.userRepository.FindIDsScheduledForDeletion(time.Now())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get users to delete")
+	}
+	var deletions []*entity.AccountDeletion
+	for _, id := range ids {
+		deletion, err := us.purge(&entity.User{ID: id})
+		if err != nil {
+			return deletions, err
+		}
+		deletions = append(deletions, deletion)
+	}
+	return deletions, nil
+}
+
+// purge ファイルはトランザクションで戻せないので、DBから消せてから消す
+func (us *userService) purge(user *entity.User) (*entity.AccountDeletion, error) {
+	successions, err := us.userRepository.Purge(user.ID, time.Now())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to delete")
+	}
+	if err = us.fileRepository.DeleteUserDir(user.ID); err != nil {
+		return nil, errors.Wrap(err, "failed to delete user directory")
+	}
+	return &entity.AccountDeletion{User: user, Successions: successions}, nil
 }
 
 func (us *userService) GetFollows(id string) ([]*entity.User, error) {
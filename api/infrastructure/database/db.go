@@ -16,6 +16,8 @@ type SQLHandler interface {
 	Exec(query string, args ...interface{}) (SQLResult, error)
 	Query(query string, args ...interface{}) (SQLRows, error)
 	QueryRow(query string, args ...interface{}) SQLRow
+	// Transaction fnがerrorを返すとrollbackする。fnの中ではtxを使うこと
+	Transaction(fn func(tx SQLHandler) error) error
 }
 
 type sqlHandler struct {
@@ -57,6 +59,7 @@ func New() (SQLHandler, error) {
 }
 
 type SQLResult interface {
+	RowsAffected() (int64, error)
 }
 
 type sqlResult struct {
@@ -66,6 +69,7 @@ type sqlResult struct {
 type SQLRows interface {
 	Scan(dest ...interface{}) error
 	Next() bool
+	Close() error
 	CheckNoRows(err error) bool
 }
 
@@ -109,6 +113,63 @@ func (sh *sqlHandler) QueryRow(query string, args ...interface{}) SQLRow {
 	}
 }
 
+func (sh *sqlHandler) Transaction(fn func(tx SQLHandler) error) error {
+	tx, err := sh.DB.Begin()
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	if err = fn(&sqlTx{Tx: tx}); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			llog.Error(errors.Wrap(rbErr, "failed to rollback"))
+		}
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit")
+	}
+	return nil
+}
+
+type sqlTx struct {
+	Tx *sql.Tx
+}
+
+func (t *sqlTx) Exec(query string, args ...interface{}) (SQLResult, error) {
+	res, err := t.Tx.Exec(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to execute query. query=%s", query))
+	}
+	return &sqlResult{
+		Result: res,
+	}, nil
+}
+
+func (t *sqlTx) Query(query string, args ...interface{}) (SQLRows, error) {
+	res, err := t.Tx.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to execute query. query=%s", query))
+	}
+	return &sqlRows{
+		Rows: res,
+	}, nil
+}
+
+func (t *sqlTx) QueryRow(query string, args ...interface{}) SQLRow {
+	res := t.Tx.QueryRow(query, args...)
+	return &sqlRow{
+		Row: res,
+	}
+}
+
+// Transaction 既にtransactionの中なので、そのままfnを実行する
+func (t *sqlTx) Transaction(fn func(tx SQLHandler) error) error {
+	return fn(t)
+}
+
+func (r *sqlResult) RowsAffected() (int64, error) {
+	return r.Result.RowsAffected()
+}
+
 func (r *sqlRows) Scan(dest ...interface{}) error {
 	return r.Rows.Scan(dest...)
 }
@@ -117,6 +178,10 @@ func (r *sqlRows) Next() bool {
 	return r.Rows.Next()
 }
 
+func (r *sqlRows) Close() error {
+	return r.Rows.Close()
+}
+
 func (r *sqlRows) CheckNoRows(err error) bool {
 	return err == sql.ErrNoRows
 }
@@ -40,6 +40,18 @@ func EndSession(w http.ResponseWriter, r *http.Request) error {
 	return deleteCookie(w, r, constants.SessionName)
 }
 
+// EndAllSessions cookieを消し、他の端末のトークンも全て失効させる
+func EndAllSessions(w http.ResponseWriter, r *http.Request) error {
+	userid, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return errors.Wrap(err, "failed to authentication")
+	}
+	if _, err = nosql.DeleteAuthsByUserID(userid); err != nil {
+		return errors.Wrap(err, "failed to revoke sessions")
+	}
+	return EndSession(w, r)
+}
+
 // GetSession session tokenを取得
 func GetSession(r *http.Request) (*jwt.Token, error) {
 	cookie, err := r.Cookie(constants.SessionName)
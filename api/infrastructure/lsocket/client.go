@@ -0,0 +1,123 @@
+package lsocket
+
+import (
+	"app/api/llog"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const sendBufferSize = 512
+
+// Client 1本のwebsocket接続。1ユーザが複数のClientを持てる
+type Client struct {
+	ID     string
+	UserID string
+
+	hub   *Hub
+	conn  *websocket.Conn
+	send  chan []byte
+	rooms map[string]struct{}
+	held  map[string][]*Frame
+
+	// presence
+	mu       sync.Mutex
+	seenAt   time.Time
+	activeAt time.Time
+	storedAt time.Time
+}
+
+func NewClient(hub *Hub, conn *websocket.Conn, userID string) *Client {
+	return &Client{
+		ID:     uuid.New().String(),
+		UserID: userID,
+		hub:    hub,
+		conn:   conn,
+		send:   make(chan []byte, sendBufferSize),
+		rooms:  make(map[string]struct{}),
+		held:   make(map[string][]*Frame),
+	}
+}
+
+// ReadPump 受信したframeをhandleに渡す。接続が切れるかheartbeatが途絶えるまでブロックする
+func (c *Client) ReadPump(handle func(c *Client, frame []byte)) {
+	config := c.hub.config
+	defer func() {
+		c.hub.Unregister(c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(config.MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(config.PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.hub.touch(c, false)
+		return c.conn.SetReadDeadline(time.Now().Add(config.PongWait))
+	})
+	for {
+		_, frame, err := c.conn.ReadMessage()
+		if err != nil {
+			c.closeOnReadError(err)
+			return
+		}
+		// pong以外のframeが届いても生きているとみなす
+		c.conn.SetReadDeadline(time.Now().Add(config.PongWait))
+		c.hub.touch(c, true)
+		handle(c, frame)
+	}
+}
+
+// WritePump sendに積まれたframeの書き込みとpingの送信を行う。connへの書き込みはこのgoroutineだけが行う
+func (c *Client) WritePump() {
+	config := c.hub.config
+	ticker := time.NewTicker(config.PingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case frame, ok := <-c.send:
+			if !ok {
+				// hubから外された
+				c.close(websocket.CloseNormalClosure, "websocket connection closed")
+				return
+			}
+			c.conn.SetWriteDeadline(time.Now().Add(config.WriteWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+				llog.Warn(fmt.Sprintf("%s: failed to write. %s", c.UserID, err))
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(config.WriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				llog.Warn(fmt.Sprintf("%s: failed to ping. %s", c.UserID, err))
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) closeOnReadError(err error) {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		llog.Info(fmt.Sprintf("%s: heartbeat timeout", c.UserID))
+		c.close(websocket.CloseGoingAway, "heartbeat timeout")
+		return
+	}
+	if err == websocket.ErrReadLimit {
+		c.close(websocket.CloseMessageTooBig, "message too large")
+		return
+	}
+	if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+		llog.Error(err)
+	}
+	llog.Info(c.UserID + ": Client disconnected")
+}
+
+// close close frameはWritePumpと並行して送れる
+func (c *Client) close(code int, reason string) {
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(c.hub.config.WriteWait))
+}
@@ -0,0 +1,54 @@
+package lsocket
+
+import (
+	"app/api/constants"
+	"app/api/llog"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config websocket接続ごとのheartbeat, timeoutの設定
+type Config struct {
+	WriteWait      time.Duration
+	PongWait       time.Duration
+	PingPeriod     time.Duration
+	MaxMessageSize int64
+	IdleTimeout    time.Duration
+	TypingTimeout  time.Duration
+}
+
+// NewConfig 環境変数があればそちらを優先する
+func NewConfig() *Config {
+	config := &Config{
+		WriteWait:      durationEnv("WS_WRITE_WAIT", constants.WSWriteWait),
+		PongWait:       durationEnv("WS_PONG_WAIT", constants.WSPongWait),
+		PingPeriod:     durationEnv("WS_PING_PERIOD", constants.WSPingPeriod),
+		MaxMessageSize: constants.WSMaxMessageSize,
+		IdleTimeout:    durationEnv("WS_IDLE_TIMEOUT", constants.WSIdleTimeout),
+		TypingTimeout:  durationEnv("WS_TYPING_TIMEOUT", constants.WSTypingTimeout),
+	}
+	if size, err := strconv.ParseInt(os.Getenv("WS_MAX_MESSAGE_SIZE"), 10, 64); err == nil && size > 0 {
+		config.MaxMessageSize = size
+	}
+	if config.PingPeriod >= config.PongWait {
+		// pongを待ち切る前にpingを送らないと生きている接続まで切ってしまう
+		config.PingPeriod = config.PongWait * 9 / 10
+		llog.Warn(fmt.Sprintf("WS_PING_PERIOD must be shorter than WS_PONG_WAIT. use %s", config.PingPeriod))
+	}
+	return config
+}
+
+func durationEnv(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		llog.Warn(fmt.Sprintf("invalid %s=%s. use %s", key, value, def))
+		return def
+	}
+	return d
+}
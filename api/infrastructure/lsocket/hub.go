@@ -0,0 +1,525 @@
+package lsocket
+
+import (
+	"app/api/infrastructure/nosql"
+	"app/api/llog"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// broker channel names
+const (
+	roomChannelPrefix = "lschat:thread:"
+	userChannelPrefix = "lschat:user:"
+)
+
+// Hub 接続中のクライアントと購読しているルーム(スレッド)を管理する
+// brokerを渡すとroom, user宛のframeはbroker経由で全インスタンスに配送される
+type Hub struct {
+	broker   nosql.Broker
+	presence *Presence
+	config   *Config
+	events   chan *RoomEvent
+
+	register    chan *Client
+	unregister  chan *Client
+	subscribe   chan *subscription
+	unsubscribe chan *subscription
+	release     chan *release
+	outbound    chan *delivery
+	io          *taskQueue
+
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+	rooms   map[string]map[*Client]struct{}
+	users   map[string]map[*Client]struct{}
+}
+
+// RoomEvent このインスタンスでユーザの最初のクライアントがroomに入った、または最後のクライアントが抜けた
+type RoomEvent struct {
+	Room   string
+	UserID string
+	Joined bool
+}
+
+type subscription struct {
+	client *Client
+	room   string
+	held   bool
+}
+
+type release struct {
+	client *Client
+	room   string
+	replay []*Frame
+}
+
+// Frame keyはメッセージIDなど、再送時の重複排除に使う
+type Frame struct {
+	Key  string
+	Data []byte
+}
+
+// delivery room, userID, clientのいずれかを宛先に持つ
+// evictの場合はroomからuserIDのクライアントを外し、disconnectの場合はuserIDのクライアントを切断する
+type delivery struct {
+	room       string
+	userID     string
+	client     *Client
+	frame      *Frame
+	evict      bool
+	disconnect bool
+}
+
+// envelope brokerに流すpayload
+type envelope struct {
+	Frame      json.RawMessage `json:"frame,omitempty"`
+	Key        string          `json:"key,omitempty"`
+	Evict      string          `json:"evict,omitempty"`
+	Disconnect bool            `json:"disconnect,omitempty"`
+}
+
+func NewHub(broker nosql.Broker, presence nosql.PresenceStore, config *Config) *Hub {
+	return &Hub{
+		broker:      broker,
+		presence:    NewPresence(presence, config),
+		config:      config,
+		events:      make(chan *RoomEvent, 256),
+		register:    make(chan *Client),
+		unregister:  make(chan *Client),
+		subscribe:   make(chan *subscription),
+		unsubscribe: make(chan *subscription),
+		release:     make(chan *release),
+		outbound:    make(chan *delivery, 256),
+		io:          newTaskQueue(),
+		clients:     make(map[*Client]struct{}),
+		rooms:       make(map[string]map[*Client]struct{}),
+		users:       make(map[string]map[*Client]struct{}),
+	}
+}
+
+// Run hubのイベントループ。goroutineで1つだけ起動する
+func (h *Hub) Run() {
+	if h.broker != nil {
+		go h.relay()
+	}
+	go h.io.run()
+	for {
+		select {
+		case c := <-h.register:
+			h.addClient(c)
+		case c := <-h.unregister:
+			h.removeClient(c)
+		case s := <-h.subscribe:
+			h.addToRoom(s.client, s.room, s.held)
+		case s := <-h.unsubscribe:
+			h.removeFromRoom(s.client, s.room)
+		case r := <-h.release:
+			h.releaseRoom(r)
+		case d := <-h.outbound:
+			h.deliver(d)
+		}
+	}
+}
+
+func (h *Hub) Register(c *Client) {
+	h.register <- c
+}
+
+func (h *Hub) Unregister(c *Client) {
+	h.unregister <- c
+}
+
+func (h *Hub) Subscribe(c *Client, room string) {
+	h.subscribe <- &subscription{client: c, room: room}
+}
+
+// SubscribeHeld roomを購読するが、Releaseされるまでroom宛のframeは配送せずに溜めておく
+func (h *Hub) SubscribeHeld(c *Client, room string) {
+	h.subscribe <- &subscription{client: c, room: room, held: true}
+}
+
+// Release replayを送ってから溜めていたframeを送り、以降は通常通り配送する
+// replayに含まれるkeyのframeは重複になるので捨てる
+func (h *Hub) Release(c *Client, room string, replay []*Frame) {
+	h.release <- &release{client: c, room: room, replay: replay}
+}
+
+func (h *Hub) Unsubscribe(c *Client, room string) {
+	h.unsubscribe <- &subscription{client: c, room: room}
+}
+
+// BroadcastToRoom roomを購読している全クライアントにframeを送る
+func (h *Hub) BroadcastToRoom(room string, frame []byte) {
+	h.BroadcastToRoomWithKey(room, "", frame)
+}
+
+// BroadcastToRoomWithKey keyを付けてroomにframeを送る
+func (h *Hub) BroadcastToRoomWithKey(room, key string, frame []byte) {
+	h.publish(roomChannelPrefix+room, &envelope{Frame: frame, Key: key}, &delivery{room: room, frame: &Frame{Key: key, Data: frame}})
+}
+
+// SendToUser userIDのユーザが持つ全クライアントにframeを送る
+func (h *Hub) SendToUser(userID string, frame []byte) {
+	h.publish(userChannelPrefix+userID, &envelope{Frame: frame}, &delivery{userID: userID, frame: &Frame{Data: frame}})
+}
+
+// EvictFromRoom userIDのユーザが持つ全クライアントのroomの購読を解除する
+func (h *Hub) EvictFromRoom(room, userID string) {
+	h.publish(roomChannelPrefix+room, &envelope{Evict: userID}, &delivery{room: room, userID: userID, evict: true})
+}
+
+// Disconnect userIDのユーザが持つ全クライアントを、全インスタンスで切断する
+func (h *Hub) Disconnect(userID string) {
+	h.publish(userChannelPrefix+userID, &envelope{Disconnect: true}, &delivery{userID: userID, disconnect: true})
+}
+
+// SendToClient 特定のクライアントにだけframeを送る。brokerは経由しない
+func (h *Hub) SendToClient(c *Client, frame []byte) {
+	h.outbound <- &delivery{client: c, frame: &Frame{Data: frame}}
+}
+
+func (h *Hub) Config() *Config {
+	return h.config
+}
+
+// RoomEvents roomへの出入りを受け取る。受け取る側が詰まっている場合は捨てる
+func (h *Hub) RoomEvents() <-chan *RoomEvent {
+	return h.events
+}
+
+// Status ユーザのオンライン状態。全インスタンスの接続から判定する
+func (h *Hub) Status(userID string) string {
+	return h.presence.Status(userID)
+}
+
+func (h *Hub) touch(c *Client, active bool) {
+	h.presence.touch(c, active)
+}
+
+func (h *Hub) RoomClients(room string) []*Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return collect(h.rooms[room])
+}
+
+func (h *Hub) UserClients(userID string) []*Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return collect(h.users[userID])
+}
+
+// IsSubscribed クライアントがroomを購読しているか
+func (h *Hub) IsSubscribed(c *Client, room string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.rooms[room][c]
+	return ok
+}
+
+// Rooms クライアントが購読しているroomの一覧
+func (h *Hub) Rooms(c *Client) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	rooms := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// publish brokerがなければ、またはpublishに失敗したらこのインスタンスにだけ配送する
+func (h *Hub) publish(channel string, env *envelope, local *delivery) {
+	if h.broker != nil {
+		payload, err := json.Marshal(env)
+		if err == nil {
+			err = h.broker.Publish(channel, payload)
+		}
+		if err == nil {
+			return
+		}
+		llog.Warn(errors.Wrap(err, "failed to publish to broker. deliver only to local clients"))
+	}
+	h.outbound <- local
+}
+
+// relay brokerから受け取ったイベントをこのインスタンスのクライアントに流す
+func (h *Hub) relay() {
+	for msg := range h.broker.Messages() {
+		var env envelope
+		if err := json.Unmarshal(msg.Payload, &env); err != nil {
+			llog.Warn(errors.Wrap(err, "failed to unmarshal broker message"))
+			continue
+		}
+		d := &delivery{frame: &Frame{Key: env.Key, Data: env.Frame}}
+		switch {
+		case strings.HasPrefix(msg.Channel, roomChannelPrefix):
+			d.room = strings.TrimPrefix(msg.Channel, roomChannelPrefix)
+			if env.Evict != "" {
+				d.userID = env.Evict
+				d.evict = true
+			}
+		case strings.HasPrefix(msg.Channel, userChannelPrefix):
+			d.userID = strings.TrimPrefix(msg.Channel, userChannelPrefix)
+			d.disconnect = env.Disconnect
+		default:
+			continue
+		}
+		h.outbound <- d
+	}
+}
+
+func (h *Hub) addClient(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c] = struct{}{}
+	if h.users[c.UserID] == nil {
+		h.users[c.UserID] = make(map[*Client]struct{})
+		h.brokerSubscribe(userChannelPrefix + c.UserID)
+	}
+	h.users[c.UserID][c] = struct{}{}
+	h.io.push(func() { h.presence.touch(c, true) })
+}
+
+func (h *Hub) removeClient(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dropClient(c)
+}
+
+// dropClient h.muをlockした状態で呼ぶこと
+func (h *Hub) dropClient(c *Client) {
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+	for room := range c.rooms {
+		h.leaveRoom(c, room)
+	}
+	delete(h.clients, c)
+	h.io.push(func() { h.presence.remove(c) })
+	delete(h.users[c.UserID], c)
+	if len(h.users[c.UserID]) == 0 {
+		delete(h.users, c.UserID)
+		h.brokerUnsubscribe(userChannelPrefix + c.UserID)
+	}
+	close(c.send)
+}
+
+func (h *Hub) addToRoom(c *Client, room string, held bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+	if h.rooms[room] == nil {
+		h.rooms[room] = make(map[*Client]struct{})
+		h.brokerSubscribe(roomChannelPrefix + room)
+	}
+	if _, ok := h.rooms[room][c]; !ok && !h.inRoom(room, c.UserID) {
+		h.emit(&RoomEvent{Room: room, UserID: c.UserID, Joined: true})
+	}
+	h.rooms[room][c] = struct{}{}
+	c.rooms[room] = struct{}{}
+	if held {
+		c.held[room] = []*Frame{}
+	}
+}
+
+func (h *Hub) releaseRoom(r *release) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c := r.client
+	held, ok := c.held[r.room]
+	if !ok {
+		return
+	}
+	delete(c.held, r.room)
+
+	replayed := make(map[string]struct{}, len(r.replay))
+	frames := make([]*Frame, 0, len(r.replay)+len(held))
+	for _, frame := range r.replay {
+		if frame.Key != "" {
+			replayed[frame.Key] = struct{}{}
+		}
+		frames = append(frames, frame)
+	}
+	for _, frame := range held {
+		if _, ok := replayed[frame.Key]; ok && frame.Key != "" {
+			continue
+		}
+		frames = append(frames, frame)
+	}
+	for _, frame := range frames {
+		if !h.push(c, frame) {
+			return
+		}
+	}
+}
+
+func (h *Hub) removeFromRoom(c *Client, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leaveRoom(c, room)
+}
+
+// leaveRoom h.muをlockした状態で呼ぶこと
+func (h *Hub) leaveRoom(c *Client, room string) {
+	if _, ok := h.rooms[room][c]; !ok {
+		return
+	}
+	delete(c.rooms, room)
+	delete(c.held, room)
+	delete(h.rooms[room], c)
+	if !h.inRoom(room, c.UserID) {
+		h.emit(&RoomEvent{Room: room, UserID: c.UserID, Joined: false})
+	}
+	if len(h.rooms[room]) == 0 {
+		delete(h.rooms, room)
+		h.brokerUnsubscribe(roomChannelPrefix + room)
+	}
+}
+
+// inRoom h.muをlockした状態で呼ぶこと
+func (h *Hub) inRoom(room, userID string) bool {
+	for c := range h.users[userID] {
+		if _, ok := h.rooms[room][c]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// emit イベントループを止めないよう、受け取り手が詰まっていれば捨てる
+func (h *Hub) emit(event *RoomEvent) {
+	select {
+	case h.events <- event:
+	default:
+		llog.Warn("room event dropped. room=" + event.Room)
+	}
+}
+
+// brokerSubscribe brokerへの購読はh.ioで行い、h.muを持ったままredisを待たない
+func (h *Hub) brokerSubscribe(channel string) {
+	if h.broker == nil {
+		return
+	}
+	h.io.push(func() {
+		if err := h.broker.Subscribe(channel); err != nil {
+			llog.Error(errors.Wrap(err, "failed to subscribe "+channel))
+		}
+	})
+}
+
+func (h *Hub) brokerUnsubscribe(channel string) {
+	if h.broker == nil {
+		return
+	}
+	h.io.push(func() {
+		if err := h.broker.Unsubscribe(channel); err != nil {
+			llog.Error(errors.Wrap(err, "failed to unsubscribe "+channel))
+		}
+	})
+}
+
+// push h.muをlockした状態で呼ぶこと。送信バッファが詰まっているクライアントは切断する
+func (h *Hub) push(c *Client, frame *Frame) bool {
+	select {
+	case c.send <- frame.Data:
+		return true
+	default:
+		h.dropClient(c)
+		return false
+	}
+}
+
+func (h *Hub) deliver(d *delivery) {
+	if d.evict {
+		h.mu.Lock()
+		for c := range h.users[d.userID] {
+			h.leaveRoom(c, d.room)
+		}
+		h.mu.Unlock()
+		return
+	}
+	if d.disconnect {
+		h.mu.Lock()
+		for _, c := range collect(h.users[d.userID]) {
+			h.dropClient(c)
+		}
+		h.mu.Unlock()
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var clients []*Client
+	switch {
+	case d.client != nil:
+		if _, ok := h.clients[d.client]; ok {
+			clients = []*Client{d.client}
+		}
+	case d.userID != "":
+		clients = collect(h.users[d.userID])
+	default:
+		clients = collect(h.rooms[d.room])
+	}
+	for _, c := range clients {
+		if held, ok := c.held[d.room]; ok && d.room != "" {
+			c.held[d.room] = append(held, d.frame)
+			continue
+		}
+		h.push(c, d.frame)
+	}
+}
+
+// taskQueue brokerやpresence storeへの書き込みを積んだ順に1つのgoroutineで実行する。
+// pushはブロックしないので、h.muを持ったまま呼べる
+type taskQueue struct {
+	mu    sync.Mutex
+	tasks []func()
+	wake  chan struct{}
+}
+
+func newTaskQueue() *taskQueue {
+	return &taskQueue{
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func (q *taskQueue) push(task func()) {
+	q.mu.Lock()
+	q.tasks = append(q.tasks, task)
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *taskQueue) run() {
+	for range q.wake {
+		for {
+			q.mu.Lock()
+			tasks := q.tasks
+			q.tasks = nil
+			q.mu.Unlock()
+			if len(tasks) == 0 {
+				break
+			}
+			for _, task := range tasks {
+				task()
+			}
+		}
+	}
+}
+
+func collect(set map[*Client]struct{}) []*Client {
+	clients := make([]*Client, 0, len(set))
+	for c := range set {
+		clients = append(clients, c)
+	}
+	return clients
+}
@@ -0,0 +1,298 @@
+package lsocket
+
+import (
+	"app/api/infrastructure/nosql"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// waitTimeout frameが届くまで待つ時間。届かないことを確かめるときはnoFrameWaitだけ待つ
+const (
+	waitTimeout = time.Second
+	noFrameWait = 50 * time.Millisecond
+)
+
+func testConfig() *Config {
+	return &Config{
+		WriteWait:      time.Second,
+		PongWait:       time.Minute,
+		PingPeriod:     time.Minute * 9 / 10,
+		MaxMessageSize: 1024,
+		IdleTimeout:    time.Minute,
+		TypingTimeout:  time.Second,
+	}
+}
+
+// newTestHubs 同じMemoryBusにつながったn個のhubを起動する。インスタンスを分けて動かしている状態を模す
+func newTestHubs(n int) []*Hub {
+	bus := nosql.NewMemoryBus()
+	hubs := make([]*Hub, n)
+	for i := range hubs {
+		hubs[i] = NewHub(bus.NewBroker(), nosql.NewMemoryPresenceStore(), testConfig())
+		go hubs[i].Run()
+	}
+	return hubs
+}
+
+// newTestClient connを持たないクライアント。hubから配送されたframeはsendに溜まる
+func newTestClient(h *Hub, id, userID string) *Client {
+	return &Client{
+		ID:     id,
+		UserID: userID,
+		hub:    h,
+		send:   make(chan []byte, sendBufferSize),
+		rooms:  make(map[string]struct{}),
+		held:   make(map[string][]*Frame),
+	}
+}
+
+// frame brokerを通るframeはJSONでなければならない
+func frame(s string) []byte {
+	return []byte(strconv.Quote(s))
+}
+
+// settle それまでにhubに渡した操作と、brokerの購読などの書き込みが終わるまで待つ
+func settle(h *Hub) {
+	// unsubscribeはRunが受け取るまでブロックするので、前の操作は処理し終わっている
+	h.Unsubscribe(&Client{}, "")
+	done := make(chan struct{})
+	h.io.push(func() { close(done) })
+	<-done
+}
+
+func expectFrames(t *testing.T, name string, c *Client, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got, ok := <-c.send:
+			if !ok {
+				t.Fatalf("%s: disconnected while waiting for %q", name, w)
+			}
+			if string(got) != strconv.Quote(w) {
+				t.Fatalf("%s: got frame %s, want %q", name, got, w)
+			}
+		case <-time.After(waitTimeout):
+			t.Fatalf("%s: timed out waiting for %q", name, w)
+		}
+	}
+	select {
+	case got, ok := <-c.send:
+		if ok {
+			t.Fatalf("%s: got unexpected frame %s", name, got)
+		}
+		t.Fatalf("%s: unexpectedly disconnected", name)
+	case <-time.After(noFrameWait):
+	}
+}
+
+func expectDisconnected(t *testing.T, name string, c *Client) {
+	t.Helper()
+	for {
+		select {
+		case _, ok := <-c.send:
+			if !ok {
+				return
+			}
+		case <-time.After(waitTimeout):
+			t.Fatalf("%s: timed out waiting for disconnect", name)
+		}
+	}
+}
+
+func TestHubFanOut(t *testing.T) {
+	type clientSpec struct {
+		name   string
+		hub    int
+		userID string
+		rooms  []string
+	}
+	tests := []struct {
+		name    string
+		clients []clientSpec
+		action  func(hubs []*Hub, clients map[string]*Client)
+		want    map[string][]string
+		closed  []string
+	}{
+		{
+			name: "room broadcast reaches subscribers on every instance",
+			clients: []clientSpec{
+				{name: "a", hub: 0, userID: "u1", rooms: []string{"t1"}},
+				{name: "b", hub: 1, userID: "u2", rooms: []string{"t1"}},
+				{name: "c", hub: 1, userID: "u3", rooms: []string{"t2"}},
+			},
+			action: func(hubs []*Hub, clients map[string]*Client) {
+				hubs[0].BroadcastToRoom("t1", frame("hello"))
+			},
+			want: map[string][]string{"a": {"hello"}, "b": {"hello"}},
+		},
+		{
+			name: "user frame reaches every client of the user",
+			clients: []clientSpec{
+				{name: "a1", hub: 0, userID: "u1"},
+				{name: "a2", hub: 1, userID: "u1"},
+				{name: "b", hub: 1, userID: "u2"},
+			},
+			action: func(hubs []*Hub, clients map[string]*Client) {
+				hubs[1].SendToUser("u1", frame("mention"))
+			},
+			want: map[string][]string{"a1": {"mention"}, "a2": {"mention"}},
+		},
+		{
+			name: "client frame is not relayed to other instances",
+			clients: []clientSpec{
+				{name: "a1", hub: 0, userID: "u1"},
+				{name: "a2", hub: 1, userID: "u1"},
+			},
+			action: func(hubs []*Hub, clients map[string]*Client) {
+				hubs[0].SendToClient(clients["a1"], frame("setup"))
+			},
+			want: map[string][]string{"a1": {"setup"}},
+		},
+		{
+			name: "evict removes the user from the room on every instance",
+			clients: []clientSpec{
+				{name: "a1", hub: 0, userID: "u1", rooms: []string{"t1"}},
+				{name: "a2", hub: 1, userID: "u1", rooms: []string{"t1"}},
+				{name: "b", hub: 1, userID: "u2", rooms: []string{"t1"}},
+			},
+			action: func(hubs []*Hub, clients map[string]*Client) {
+				hubs[0].EvictFromRoom("t1", "u1")
+				hubs[0].BroadcastToRoom("t1", frame("after"))
+			},
+			want: map[string][]string{"b": {"after"}},
+		},
+		{
+			name: "disconnect closes every client of the user",
+			clients: []clientSpec{
+				{name: "a1", hub: 0, userID: "u1", rooms: []string{"t1"}},
+				{name: "a2", hub: 1, userID: "u1"},
+				{name: "b", hub: 1, userID: "u2", rooms: []string{"t1"}},
+			},
+			action: func(hubs []*Hub, clients map[string]*Client) {
+				hubs[1].Disconnect("u1")
+				hubs[1].BroadcastToRoom("t1", frame("after"))
+			},
+			want:   map[string][]string{"b": {"after"}},
+			closed: []string{"a1", "a2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hubs := newTestHubs(2)
+			clients := make(map[string]*Client, len(tt.clients))
+			for _, spec := range tt.clients {
+				h := hubs[spec.hub]
+				c := newTestClient(h, spec.name, spec.userID)
+				h.Register(c)
+				for _, room := range spec.rooms {
+					h.Subscribe(c, room)
+				}
+				clients[spec.name] = c
+			}
+			for _, h := range hubs {
+				settle(h)
+			}
+
+			tt.action(hubs, clients)
+
+			closed := make(map[string]bool, len(tt.closed))
+			for _, name := range tt.closed {
+				closed[name] = true
+				expectDisconnected(t, name, clients[name])
+			}
+			for _, spec := range tt.clients {
+				if closed[spec.name] {
+					continue
+				}
+				expectFrames(t, spec.name, clients[spec.name], tt.want[spec.name]...)
+			}
+		})
+	}
+}
+
+// waitHeld roomに溜まっているframeがn件になるまで待つ
+func waitHeld(t *testing.T, h *Hub, c *Client, room string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		h.mu.RLock()
+		held := len(c.held[room])
+		h.mu.RUnlock()
+		if held == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("held %d frames, want %d", held, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHubReleaseOrder(t *testing.T) {
+	type keyed struct {
+		key  string
+		data string
+	}
+	tests := []struct {
+		name   string
+		held   []keyed
+		replay []keyed
+		want   []string
+	}{
+		{
+			name:   "replay is sent before held frames",
+			held:   []keyed{{"m3", "m3"}, {"m4", "m4"}},
+			replay: []keyed{{"m1", "m1"}, {"m2", "m2"}},
+			want:   []string{"m1", "m2", "m3", "m4"},
+		},
+		{
+			name:   "held frames already in replay are dropped",
+			held:   []keyed{{"m2", "m2"}, {"m3", "m3"}},
+			replay: []keyed{{"m1", "m1"}, {"m2", "m2"}},
+			want:   []string{"m1", "m2", "m3"},
+		},
+		{
+			name:   "frames without key are never dropped",
+			held:   []keyed{{"", "typing"}, {"m2", "m2"}},
+			replay: []keyed{{"", "typing"}, {"m2", "m2"}},
+			want:   []string{"typing", "m2", "typing"},
+		},
+		{
+			name:   "nothing held",
+			replay: []keyed{{"m1", "m1"}},
+			want:   []string{"m1"},
+		},
+		{
+			name: "nothing to replay",
+			held: []keyed{{"m1", "m1"}},
+			want: []string{"m1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hubs := newTestHubs(2)
+			c := newTestClient(hubs[0], "c", "u1")
+			hubs[0].Register(c)
+			hubs[0].SubscribeHeld(c, "t1")
+			settle(hubs[0])
+
+			// 他のインスタンスから届いたframeもReleaseまで溜めておく
+			for _, f := range tt.held {
+				hubs[1].BroadcastToRoomWithKey("t1", f.key, frame(f.data))
+			}
+			waitHeld(t, hubs[0], c, "t1", len(tt.held))
+			expectFrames(t, "before release", c)
+
+			replay := make([]*Frame, 0, len(tt.replay))
+			for _, f := range tt.replay {
+				replay = append(replay, &Frame{Key: f.key, Data: frame(f.data)})
+			}
+			hubs[0].Release(c, "t1", replay)
+			expectFrames(t, "after release", c, tt.want...)
+
+			hubs[1].BroadcastToRoomWithKey("t1", "m9", frame("m9"))
+			expectFrames(t, "after release", c, "m9")
+		})
+	}
+}
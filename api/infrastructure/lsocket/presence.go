@@ -0,0 +1,87 @@
+package lsocket
+
+import (
+	"app/api/infrastructure/nosql"
+	"app/api/llog"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// presence status
+const (
+	StatusOnline  = "online"
+	StatusIdle    = "idle"
+	StatusOffline = "offline"
+)
+
+// presenceStoreInterval storeへの書き込みを間引く間隔
+const presenceStoreInterval = 10 * time.Second
+
+// Presence 接続中のクライアントからユーザのオンライン状態を判定する
+type Presence struct {
+	store  nosql.PresenceStore
+	config *Config
+}
+
+func NewPresence(store nosql.PresenceStore, config *Config) *Presence {
+	return &Presence{
+		store:  store,
+		config: config,
+	}
+}
+
+// Status 操作があればonline、接続はあるが操作がなければidle、接続がなければoffline
+func (p *Presence) Status(userID string) string {
+	conns, err := p.store.GetConnections(userID)
+	if err != nil {
+		llog.Warn(errors.Wrap(err, "failed to get presence"))
+		return StatusOffline
+	}
+	now := time.Now()
+	status := StatusOffline
+	for _, conn := range conns {
+		if now.Sub(conn.SeenAt) > p.config.PongWait {
+			// heartbeatが途絶えている接続
+			continue
+		}
+		if now.Sub(conn.ActiveAt) < p.config.IdleTimeout {
+			return StatusOnline
+		}
+		status = StatusIdle
+	}
+	return status
+}
+
+// touch activeはクライアントからframeを受け取った場合
+func (p *Presence) touch(c *Client, active bool) {
+	now := time.Now()
+	c.mu.Lock()
+	wasIdle := now.Sub(c.activeAt) >= p.config.IdleTimeout
+	c.seenAt = now
+	if active {
+		c.activeAt = now
+	}
+	store := c.storedAt.IsZero() || now.Sub(c.storedAt) >= presenceStoreInterval || (active && wasIdle)
+	if store {
+		c.storedAt = now
+	}
+	conn := &nosql.Connection{
+		SeenAt:   c.seenAt,
+		ActiveAt: c.activeAt,
+	}
+	c.mu.Unlock()
+
+	if !store {
+		return
+	}
+	if err := p.store.SetConnection(c.UserID, c.ID, conn, 2*p.config.PongWait); err != nil {
+		llog.Warn(errors.Wrap(err, "failed to store presence"))
+	}
+}
+
+func (p *Presence) remove(c *Client) {
+	if err := p.store.RemoveConnection(c.UserID, c.ID); err != nil {
+		llog.Warn(errors.Wrap(err, "failed to remove presence"))
+	}
+}
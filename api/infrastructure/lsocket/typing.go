@@ -0,0 +1,66 @@
+package lsocket
+
+import (
+	"sync"
+	"time"
+)
+
+// Typing room内で入力中のユーザを管理する。timeout以内にStartが来なければ自動でonStopを呼ぶ
+type Typing struct {
+	timeout time.Duration
+	onStop  func(room, userID string)
+
+	mu     sync.Mutex
+	timers map[typingKey]*time.Timer
+}
+
+type typingKey struct {
+	room   string
+	userID string
+}
+
+func NewTyping(timeout time.Duration, onStop func(room, userID string)) *Typing {
+	return &Typing{
+		timeout: timeout,
+		onStop:  onStop,
+		timers:  make(map[typingKey]*time.Timer),
+	}
+}
+
+// Start 入力中でなかった場合はtrue
+func (t *Typing) Start(room, userID string) bool {
+	key := typingKey{room: room, userID: userID}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if timer, ok := t.timers[key]; ok {
+		timer.Reset(t.timeout)
+		return false
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(t.timeout, func() {
+		t.mu.Lock()
+		if t.timers[key] != timer {
+			t.mu.Unlock()
+			return
+		}
+		delete(t.timers, key)
+		t.mu.Unlock()
+		t.onStop(room, userID)
+	})
+	t.timers[key] = timer
+	return true
+}
+
+// Stop 入力中だった場合はtrue
+func (t *Typing) Stop(room, userID string) bool {
+	key := typingKey{room: room, userID: userID}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timer, ok := t.timers[key]
+	if !ok {
+		return false
+	}
+	timer.Stop()
+	delete(t.timers, key)
+	return true
+}
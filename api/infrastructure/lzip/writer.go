@@ -0,0 +1,173 @@
+package lzip
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// WinZip AES(AE-1, AES-256)の形式。7-ZipやWinZipで開ける
+const (
+	methodAES      = 99
+	flagEncrypted  = 0x1
+	aesExtraID     = 0x9901
+	aesKeyLength   = 32
+	aesSaltLength  = 16
+	aesStrength    = 3 // AES-256
+	aesIterations  = 1000
+	aesMACLength   = 10
+	aesVerifyBytes = 2
+)
+
+// Writer passwordが空でなければ、各ファイルをWinZip AESで暗号化して書き込む
+type Writer struct {
+	zw       *zip.Writer
+	password string
+}
+
+func NewWriter(w io.Writer, password string) *Writer {
+	zw := zip.NewWriter(w)
+	if password != "" {
+		zw.RegisterCompressor(methodAES, func(out io.Writer) (io.WriteCloser, error) {
+			return newAESWriter(out, password)
+		})
+	}
+	return &Writer{
+		zw:       zw,
+		password: password,
+	}
+}
+
+// Create nameはzip内のパス。次にCreateかCloseを呼ぶまでに中身を書き込む
+func (w *Writer) Create(name string, modified time.Time) (io.Writer, error) {
+	fh := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	}
+	if w.password != "" {
+		fh.Method = methodAES
+		fh.Flags |= flagEncrypted
+		fh.Extra = aesExtra()
+	}
+	return w.zw.CreateHeader(fh)
+}
+
+func (w *Writer) Close() error {
+	return w.zw.Close()
+}
+
+// aesExtra 暗号化する前の圧縮方式を持つextra field
+func aesExtra() []byte {
+	extra := make([]byte, 11)
+	binary.LittleEndian.PutUint16(extra[0:], aesExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], 1) // AE-1はCRCも検証する
+	copy(extra[6:], "AE")
+	extra[8] = aesStrength
+	binary.LittleEndian.PutUint16(extra[9:], zip.Deflate)
+	return extra
+}
+
+// aesWriter deflateで圧縮してから暗号化する。
+// salt, パスワード検証値, 暗号文, 認証コードの順に書き込む
+type aesWriter struct {
+	out    io.Writer
+	header []byte // saltとパスワード検証値。zip.Writerがlocal headerを書いた後に書く
+	flate  *flate.Writer
+	block  cipher.Block
+	mac    hash.Hash
+	nonce  [aes.BlockSize]byte
+	stream [aes.BlockSize]byte
+	pos    int
+}
+
+func newAESWriter(out io.Writer, password string) (io.WriteCloser, error) {
+	salt := make([]byte, aesSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "failed to generate salt")
+	}
+	key := pbkdf2.Key([]byte(password), salt, aesIterations, aesKeyLength*2+aesVerifyBytes, sha1.New)
+	block, err := aes.NewCipher(key[:aesKeyLength])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher")
+	}
+	aw := &aesWriter{
+		out:    out,
+		header: append(salt, key[aesKeyLength*2:]...),
+		block:  block,
+		mac:    hmac.New(sha1.New, key[aesKeyLength:aesKeyLength*2]),
+		pos:    aes.BlockSize,
+	}
+	fw, err := flate.NewWriter(writerFunc(aw.encrypt), flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	aw.flate = fw
+	return aw, nil
+}
+
+func (aw *aesWriter) Write(p []byte) (int, error) {
+	return aw.flate.Write(p)
+}
+
+func (aw *aesWriter) Close() error {
+	if err := aw.flate.Close(); err != nil {
+		return err
+	}
+	if err := aw.writeHeader(); err != nil {
+		return err
+	}
+	_, err := aw.out.Write(aw.mac.Sum(nil)[:aesMACLength])
+	return err
+}
+
+func (aw *aesWriter) writeHeader() error {
+	if aw.header == nil {
+		return nil
+	}
+	_, err := aw.out.Write(aw.header)
+	aw.header = nil
+	return err
+}
+
+// encrypt カウンタをリトルエンディアンで1から数えるCTRモード
+func (aw *aesWriter) encrypt(p []byte) (int, error) {
+	if err := aw.writeHeader(); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, len(p))
+	for i, b := range p {
+		if aw.pos == aes.BlockSize {
+			for j := range aw.nonce {
+				aw.nonce[j]++
+				if aw.nonce[j] != 0 {
+					break
+				}
+			}
+			aw.block.Encrypt(aw.stream[:], aw.nonce[:])
+			aw.pos = 0
+		}
+		buf[i] = b ^ aw.stream[aw.pos]
+		aw.pos++
+	}
+	aw.mac.Write(buf)
+	return aw.out.Write(buf)
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,168 @@
+package lzip
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// aesReader WinZip AESで暗号化されたデータを復号してdeflateを戻す。テストでの読み出し用
+func aesReader(password string) zip.Decompressor {
+	return func(r io.Reader) io.ReadCloser {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return errReader(err)
+		}
+		if len(data) < aesSaltLength+aesVerifyBytes+aesMACLength {
+			return errReader(errors.New("encrypted data is too short"))
+		}
+		salt := data[:aesSaltLength]
+		verify := data[aesSaltLength : aesSaltLength+aesVerifyBytes]
+		body := data[aesSaltLength+aesVerifyBytes : len(data)-aesMACLength]
+		mac := data[len(data)-aesMACLength:]
+
+		key := pbkdf2.Key([]byte(password), salt, aesIterations, aesKeyLength*2+aesVerifyBytes, sha1.New)
+		if !bytes.Equal(key[aesKeyLength*2:], verify) {
+			return errReader(errors.New("password is wrong"))
+		}
+		h := hmac.New(sha1.New, key[aesKeyLength:aesKeyLength*2])
+		h.Write(body)
+		if !bytes.Equal(h.Sum(nil)[:aesMACLength], mac) {
+			return errReader(errors.New("authentication code mismatch"))
+		}
+
+		block, err := aes.NewCipher(key[:aesKeyLength])
+		if err != nil {
+			return errReader(err)
+		}
+		var nonce, stream [aes.BlockSize]byte
+		plain := make([]byte, len(body))
+		for i := range body {
+			if i%aes.BlockSize == 0 {
+				for j := range nonce {
+					nonce[j]++
+					if nonce[j] != 0 {
+						break
+					}
+				}
+				block.Encrypt(stream[:], nonce[:])
+			}
+			plain[i] = body[i] ^ stream[i%aes.BlockSize]
+		}
+		return flate.NewReader(bytes.NewReader(plain))
+	}
+}
+
+func errReader(err error) io.ReadCloser {
+	return ioutil.NopCloser(&failingReader{err: err})
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func writeZip(t *testing.T, password string, files map[string][]byte, names []string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf, password)
+	for _, name := range names {
+		f, err := w.Create(name, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("Create(%q): %v", name, err)
+		}
+		if _, err = f.Write(files[name]); err != nil {
+			t.Fatalf("Write(%q): %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	files := map[string][]byte{
+		"thread.json":    []byte(`{"id":"thread"}`),
+		"transcript.txt": bytes.Repeat([]byte("[2020-01-02 03:04:05] name (@user): こんにちは\n"), 500),
+		"files/empty":    {},
+	}
+	names := []string{"thread.json", "transcript.txt", "files/empty"}
+
+	tests := []struct {
+		name       string
+		password   string
+		wantMethod uint16
+	}{
+		{name: "without password", password: "", wantMethod: zip.Deflate},
+		{name: "with password", password: "secret", wantMethod: methodAES},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := writeZip(t, tt.password, files, names)
+			r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("NewReader: %v", err)
+			}
+			r.RegisterDecompressor(methodAES, aesReader(tt.password))
+			if len(r.File) != len(names) {
+				t.Fatalf("got %d files, want %d", len(r.File), len(names))
+			}
+			for i, zf := range r.File {
+				if zf.Name != names[i] {
+					t.Errorf("file %d: got name %q, want %q", i, zf.Name, names[i])
+				}
+				if zf.Method != tt.wantMethod {
+					t.Errorf("%s: got method %d, want %d", zf.Name, zf.Method, tt.wantMethod)
+				}
+				if encrypted := zf.Flags&flagEncrypted != 0; encrypted != (tt.password != "") {
+					t.Errorf("%s: got encrypted flag %v", zf.Name, encrypted)
+				}
+				rc, err := zf.Open()
+				if err != nil {
+					t.Fatalf("%s: Open: %v", zf.Name, err)
+				}
+				// archive/zipは読み切ったときにCRCも確かめる
+				got, err := ioutil.ReadAll(rc)
+				rc.Close()
+				if err != nil {
+					t.Fatalf("%s: read: %v", zf.Name, err)
+				}
+				if !bytes.Equal(got, files[zf.Name]) {
+					t.Errorf("%s: content mismatch. got %d bytes, want %d bytes", zf.Name, len(got), len(files[zf.Name]))
+				}
+			}
+		})
+	}
+}
+
+func TestWriterWrongPassword(t *testing.T) {
+	files := map[string][]byte{"thread.json": []byte(`{"id":"thread"}`)}
+	data := writeZip(t, "secret", files, []string{"thread.json"})
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	r.RegisterDecompressor(methodAES, aesReader("wrong"))
+	rc, err := r.File[0].Open()
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer rc.Close()
+	if _, err = ioutil.ReadAll(rc); err == nil {
+		t.Fatal("read with wrong password succeeded")
+	}
+}
@@ -0,0 +1,112 @@
+package nosql
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const presenceKeyPrefix = "lschat:presence:"
+
+// Connection websocket接続ごとの最終受信時刻と最終操作時刻
+type Connection struct {
+	SeenAt   time.Time `json:"seen_at"`
+	ActiveAt time.Time `json:"active_at"`
+}
+
+// PresenceStore ユーザごとの接続状況を全インスタンスで共有する
+type PresenceStore interface {
+	SetConnection(userID, connID string, conn *Connection, ttl time.Duration) error
+	RemoveConnection(userID, connID string) error
+	GetConnections(userID string) ([]*Connection, error)
+}
+
+type redisPresenceStore struct{}
+
+func NewRedisPresenceStore() PresenceStore {
+	return &redisPresenceStore{}
+}
+
+// SetConnection インスタンスが落ちた場合に備えてkeyにttlを付けておく
+func (s *redisPresenceStore) SetConnection(userID, connID string, conn *Connection, ttl time.Duration) error {
+	value, err := json.Marshal(conn)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal connection")
+	}
+	key := presenceKeyPrefix + userID
+	if err = client.HSet(key, connID, value).Err(); err != nil {
+		return errors.Wrap(err, "failed to set connection")
+	}
+	if err = client.Expire(key, ttl).Err(); err != nil {
+		return errors.Wrap(err, "failed to set ttl")
+	}
+	return nil
+}
+
+func (s *redisPresenceStore) RemoveConnection(userID, connID string) error {
+	if err := client.HDel(presenceKeyPrefix+userID, connID).Err(); err != nil {
+		return errors.Wrap(err, "failed to remove connection")
+	}
+	return nil
+}
+
+func (s *redisPresenceStore) GetConnections(userID string) ([]*Connection, error) {
+	values, err := client.HGetAll(presenceKeyPrefix + userID).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get connections")
+	}
+	conns := make([]*Connection, 0, len(values))
+	for _, value := range values {
+		var conn Connection
+		if err = json.Unmarshal([]byte(value), &conn); err != nil {
+			continue
+		}
+		conns = append(conns, &conn)
+	}
+	return conns, nil
+}
+
+type memoryPresenceStore struct {
+	mu    sync.RWMutex
+	conns map[string]map[string]Connection
+}
+
+// NewMemoryPresenceStore 単一プロセス用。ttlは使わない
+func NewMemoryPresenceStore() PresenceStore {
+	return &memoryPresenceStore{
+		conns: make(map[string]map[string]Connection),
+	}
+}
+
+func (s *memoryPresenceStore) SetConnection(userID, connID string, conn *Connection, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns[userID] == nil {
+		s.conns[userID] = make(map[string]Connection)
+	}
+	s.conns[userID][connID] = *conn
+	return nil
+}
+
+func (s *memoryPresenceStore) RemoveConnection(userID, connID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns[userID], connID)
+	if len(s.conns[userID]) == 0 {
+		delete(s.conns, userID)
+	}
+	return nil
+}
+
+func (s *memoryPresenceStore) GetConnections(userID string) ([]*Connection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	conns := make([]*Connection, 0, len(s.conns[userID]))
+	for _, conn := range s.conns[userID] {
+		c := conn
+		conns = append(conns, &c)
+	}
+	return conns, nil
+}
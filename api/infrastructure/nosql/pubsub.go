@@ -0,0 +1,193 @@
+package nosql
+
+import (
+	"sync"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/pkg/errors"
+)
+
+const brokerBufferSize = 256
+
+// Broker APIサーバのインスタンス間でイベントを配送するpub/sub
+type Broker interface {
+	Publish(channel string, payload []byte) error
+	Subscribe(channels ...string) error
+	Unsubscribe(channels ...string) error
+	Messages() <-chan *BrokerMessage
+	Close() error
+}
+
+type BrokerMessage struct {
+	Channel string
+	Payload []byte
+}
+
+type redisBroker struct {
+	pubsub   *redis.PubSub
+	messages chan *BrokerMessage
+}
+
+// NewRedisBroker New()で接続したredisを使うBroker
+func NewRedisBroker() Broker {
+	b := &redisBroker{
+		pubsub:   client.Subscribe(),
+		messages: make(chan *BrokerMessage, brokerBufferSize),
+	}
+	go b.receive()
+	return b
+}
+
+func (b *redisBroker) receive() {
+	for msg := range b.pubsub.Channel() {
+		b.messages <- &BrokerMessage{
+			Channel: msg.Channel,
+			Payload: []byte(msg.Payload),
+		}
+	}
+	close(b.messages)
+}
+
+func (b *redisBroker) Publish(channel string, payload []byte) error {
+	if err := client.Publish(channel, payload).Err(); err != nil {
+		return errors.Wrap(err, "failed to publish")
+	}
+	return nil
+}
+
+func (b *redisBroker) Subscribe(channels ...string) error {
+	if err := b.pubsub.Subscribe(channels...); err != nil {
+		return errors.Wrap(err, "failed to subscribe")
+	}
+	return nil
+}
+
+func (b *redisBroker) Unsubscribe(channels ...string) error {
+	if err := b.pubsub.Unsubscribe(channels...); err != nil {
+		return errors.Wrap(err, "failed to unsubscribe")
+	}
+	return nil
+}
+
+func (b *redisBroker) Messages() <-chan *BrokerMessage {
+	return b.messages
+}
+
+func (b *redisBroker) Close() error {
+	return b.pubsub.Close()
+}
+
+// MemoryBus redisの代わりにプロセス内でBroker同士をつなぐ。ローカル開発や動作確認用
+type MemoryBus struct {
+	mu      sync.RWMutex
+	brokers map[*memoryBroker]struct{}
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		brokers: make(map[*memoryBroker]struct{}),
+	}
+}
+
+// NewMemoryBroker 単一プロセスで完結するBroker
+func NewMemoryBroker() Broker {
+	return NewMemoryBus().NewBroker()
+}
+
+// NewBroker 同じbusにつながったBrokerはインスタンスを模してメッセージを受け取り合う
+func (bus *MemoryBus) NewBroker() Broker {
+	b := &memoryBroker{
+		bus:      bus,
+		channels: make(map[string]struct{}),
+		messages: make(chan *BrokerMessage, brokerBufferSize),
+	}
+	bus.mu.Lock()
+	bus.brokers[b] = struct{}{}
+	bus.mu.Unlock()
+	return b
+}
+
+type memoryBroker struct {
+	bus      *MemoryBus
+	mu       sync.RWMutex
+	channels map[string]struct{}
+	messages chan *BrokerMessage
+	closed   bool
+}
+
+func (b *memoryBroker) Publish(channel string, payload []byte) error {
+	b.bus.mu.RLock()
+	receivers := make([]*memoryBroker, 0, len(b.bus.brokers))
+	for broker := range b.bus.brokers {
+		if broker.isSubscribed(channel) {
+			receivers = append(receivers, broker)
+		}
+	}
+	b.bus.mu.RUnlock()
+
+	var err error
+	for _, broker := range receivers {
+		if !broker.deliver(&BrokerMessage{Channel: channel, Payload: payload}) {
+			err = errors.New("receiver buffer is full. message dropped")
+		}
+	}
+	return err
+}
+
+func (b *memoryBroker) Subscribe(channels ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, channel := range channels {
+		b.channels[channel] = struct{}{}
+	}
+	return nil
+}
+
+func (b *memoryBroker) Unsubscribe(channels ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, channel := range channels {
+		delete(b.channels, channel)
+	}
+	return nil
+}
+
+func (b *memoryBroker) Messages() <-chan *BrokerMessage {
+	return b.messages
+}
+
+func (b *memoryBroker) Close() error {
+	b.bus.mu.Lock()
+	delete(b.bus.brokers, b)
+	b.bus.mu.Unlock()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.messages)
+	}
+	return nil
+}
+
+// deliver redisと同様、受信側が詰まっている場合は捨てる
+func (b *memoryBroker) deliver(msg *BrokerMessage) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return true
+	}
+	select {
+	case b.messages <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *memoryBroker) isSubscribed(channel string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	_, ok := b.channels[channel]
+	return ok
+}
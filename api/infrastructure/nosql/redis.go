@@ -38,6 +38,13 @@ func CreateAuth(userid string, rTime time.Time, token string) error {
 		return errAccess
 	}
 
+	// ユーザのトークンをまとめて失効できるように、ユーザごとにトークンを覚えておく
+	pipe := client.TxPipeline()
+	pipe.SAdd(sessionsKey(userid), token)
+	pipe.ExpireAt(sessionsKey(userid), rTime)
+	if _, err := pipe.Exec(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -49,6 +56,24 @@ func CheckValidToken(token string) error {
 	return nil
 }
 
+// DeleteAuthsByUserID useridのユーザに発行した全てのトークンを失効させる
+func DeleteAuthsByUserID(userid string) (int64, error) {
+	tokens, err := client.SMembers(sessionsKey(userid)).Result()
+	if err != nil {
+		return 0, err
+	}
+	keys := append(tokens, sessionsKey(userid))
+	deleted, err := client.Del(keys...).Result()
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+func sessionsKey(userid string) string {
+	return "sessions:" + userid
+}
+
 func DeleteAuth(givenUuid string) (int64, error) {
 	deleted, err := client.Del(givenUuid).Result()
 	if err != nil {
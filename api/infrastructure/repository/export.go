@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"app/api/domain/entity"
+	"app/api/domain/repository"
+	"app/api/infrastructure/database"
+
+	"github.com/pkg/errors"
+)
+
+type exportRepository struct {
+	sqlHandler database.SQLHandler
+}
+
+func NewExportRepository(sh database.SQLHandler) repository.ExportRepository {
+	return &exportRepository{
+		sqlHandler: sh,
+	}
+}
+
+const exportColumns = `id, thread_id, path, is_public, password, status, created_by, created_at`
+
+func scanExport(scanner rowScanner) (*entity.Export, error) {
+	var export entity.Export
+	var thread entity.Thread
+	var createdBy entity.User
+	if err := scanner.Scan(&export.ID, &thread.ID, &export.Path, &export.IsPublic, &export.Password, &export.Status, &createdBy.ID, &export.CreatedAt); err != nil {
+		return nil, err
+	}
+	export.Thread = &thread
+	export.CreatedBy = &createdBy
+	return &export, nil
+}
+
+func (er *exportRepository) Create(export *entity.Export) error {
+	_, err := er.sqlHandler.Exec(`
+		INSERT INTO archives(id, thread_id, path, is_public, password, status, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		export.ID,
+		export.Thread.ID,
+		export.Path,
+		export.IsPublic,
+		export.Password,
+		export.Status,
+		export.CreatedBy.ID,
+		export.CreatedAt,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to insert export")
+	}
+	return nil
+}
+
+func (er *exportRepository) FindByID(id string) (*entity.Export, error) {
+	row := er.sqlHandler.QueryRow(`
+		SELECT `+exportColumns+`
+		FROM archives
+		WHERE id=?
+	`, id)
+	export, err := scanExport(row)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+	return export, nil
+}
+
+// FindByThreadID 新しい順。書き出し中や失敗したものも返す
+func (er *exportRepository) FindByThreadID(threadID string) ([]*entity.Export, error) {
+	rows, err := er.sqlHandler.Query(`
+		SELECT `+exportColumns+`
+		FROM archives
+		WHERE thread_id=?
+		ORDER BY created_at DESC
+	`, threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var exports []*entity.Export
+	for rows.Next() {
+		export, err := scanExport(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		exports = append(exports, export)
+	}
+	return exports, nil
+}
+
+func (er *exportRepository) UpdateStatus(id string, status entity.ExportStatus, path string) error {
+	_, err := er.sqlHandler.Exec(`
+		UPDATE archives
+		SET status=?, path=?
+		WHERE id=?
+	`, status, path, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to update export")
+	}
+	return nil
+}
@@ -2,10 +2,15 @@ package repository
 
 import (
 	"app/api/constants"
+	"app/api/domain/entity"
 	"app/api/domain/repository"
+	"app/api/infrastructure/lzip"
 	"app/api/llog"
 	"io/ioutil"
 	"os"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 type fileRepository struct {
@@ -75,6 +80,48 @@ func (fr *fileRepository) CreateUserDir(userID string) error {
 	return createDirectory(fr.filePath + "/users/" + userID)
 }
 
+func (fr *fileRepository) DeleteUserDir(userID string) error {
+	return os.RemoveAll(fr.filePath + "/users/" + userID)
+}
+
+// CreateExport passwordが空でなければ暗号化したzipを作る。FILE_PATHからの相対パスを返す
+func (fr *fileRepository) CreateExport(threadID string, exportID string, password string, files []*entity.ExportFile) (string, error) {
+	dir := "threads/" + threadID + "/exports"
+	if err := createDirectory(fr.filePath + "/" + dir); err != nil {
+		return "", errors.Wrap(err, "failed to create directory")
+	}
+	path := dir + "/" + exportID + ".zip"
+	f, err := os.Create(fr.filePath + "/" + path)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create file")
+	}
+	defer f.Close()
+
+	zw := lzip.NewWriter(f, password)
+	now := time.Now()
+	for _, file := range files {
+		w, err := zw.Create(file.Name, now)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to create "+file.Name)
+		}
+		if _, err = w.Write(file.Body); err != nil {
+			return "", errors.Wrap(err, "failed to write "+file.Name)
+		}
+	}
+	if err = zw.Close(); err != nil {
+		return "", errors.Wrap(err, "failed to close zip")
+	}
+	return path, nil
+}
+
+func (fr *fileRepository) GetExport(path string) ([]byte, error) {
+	return readFile(fr.filePath + "/" + path)
+}
+
+func (fr *fileRepository) DeleteExports(threadID string) error {
+	return os.RemoveAll(fr.filePath + "/threads/" + threadID + "/exports")
+}
+
 func createDirectory(path string) error {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		err = os.Mkdir(path, os.ModeDir)
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"app/api/domain/entity"
+	"app/api/domain/repository"
+	"app/api/infrastructure/database"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type inviteRepository struct {
+	sqlHandler database.SQLHandler
+}
+
+func NewInviteRepository(sh database.SQLHandler) repository.InviteRepository {
+	return &inviteRepository{
+		sqlHandler: sh,
+	}
+}
+
+const inviteColumns = `id, thread_id, code, created_by, COALESCE(invitee_id, ''), max_uses, uses, expires_at, revoked_at, created_at`
+
+func scanInvite(scanner rowScanner) (*entity.ThreadInvite, error) {
+	var invite entity.ThreadInvite
+	var thread entity.Thread
+	var createdBy entity.User
+	var invitee entity.User
+	if err := scanner.Scan(&invite.ID, &thread.ID, &invite.Code, &createdBy.ID, &invitee.ID, &invite.MaxUses, &invite.Uses, &invite.ExpiresAt, &invite.RevokedAt, &invite.CreatedAt); err != nil {
+		return nil, err
+	}
+	invite.Thread = &thread
+	invite.CreatedBy = &createdBy
+	if invitee.ID != "" {
+		invite.Invitee = &invitee
+	}
+	return &invite, nil
+}
+
+func (ir *inviteRepository) Create(invite *entity.ThreadInvite) error {
+	var inviteeID interface{}
+	if invite.Invitee != nil {
+		inviteeID = invite.Invitee.ID
+	}
+	_, err := ir.sqlHandler.Exec(`
+		INSERT INTO thread_invites(id, thread_id, code, created_by, invitee_id, max_uses, uses, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, 0, ?, ?)
+	`,
+		invite.ID,
+		invite.Thread.ID,
+		invite.Code,
+		invite.CreatedBy.ID,
+		inviteeID,
+		invite.MaxUses,
+		invite.ExpiresAt,
+		invite.CreatedAt,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to insert invite")
+	}
+	return nil
+}
+
+func (ir *inviteRepository) FindByID(id string) (*entity.ThreadInvite, error) {
+	row := ir.sqlHandler.QueryRow(`
+		SELECT `+inviteColumns+`
+		FROM thread_invites
+		WHERE id=?
+	`, id)
+	invite, err := scanInvite(row)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+	return invite, nil
+}
+
+func (ir *inviteRepository) FindByCode(code string) (*entity.ThreadInvite, error) {
+	row := ir.sqlHandler.QueryRow(`
+		SELECT `+inviteColumns+`
+		FROM thread_invites
+		WHERE code=?
+	`, code)
+	invite, err := scanInvite(row)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+	return invite, nil
+}
+
+// FindByThreadID 取り消し済みや期限切れのものも返す
+func (ir *inviteRepository) FindByThreadID(threadID string) ([]*entity.ThreadInvite, error) {
+	rows, err := ir.sqlHandler.Query(`
+		SELECT `+inviteColumns+`
+		FROM thread_invites
+		WHERE thread_id=?
+		ORDER BY created_at DESC
+	`, threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var invites []*entity.ThreadInvite
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		invites = append(invites, invite)
+	}
+	return invites, nil
+}
+
+// FindByInviteeID at時点で使えるユーザ宛ての招待
+func (ir *inviteRepository) FindByInviteeID(userID string, at time.Time) ([]*entity.ThreadInvite, error) {
+	rows, err := ir.sqlHandler.Query(`
+		SELECT `+inviteColumns+`
+		FROM thread_invites
+		WHERE invitee_id=?
+			AND revoked_at IS NULL
+			AND (expires_at IS NULL OR expires_at > ?)
+			AND (max_uses = 0 OR uses < max_uses)
+		ORDER BY created_at DESC
+	`, userID, at)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var invites []*entity.ThreadInvite
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		invites = append(invites, invite)
+	}
+	return invites, nil
+}
+
+func (ir *inviteRepository) FindUsesByThreadID(threadID string) ([]*entity.ThreadInviteUse, error) {
+	rows, err := ir.sqlHandler.Query(`
+		SELECT id, invite_id, user_id, created_at
+		FROM thread_invite_uses
+		WHERE thread_id=?
+		ORDER BY created_at
+	`, threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var uses []*entity.ThreadInviteUse
+	for rows.Next() {
+		var use entity.ThreadInviteUse
+		var invite entity.ThreadInvite
+		var user entity.User
+		if err = rows.Scan(&use.ID, &invite.ID, &user.ID, &use.CreatedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		use.Invite = &invite
+		use.User = &user
+		uses = append(uses, &use)
+	}
+	return uses, nil
+}
+
+// Revoke 既に取り消されていればfalse
+func (ir *inviteRepository) Revoke(id string, revokedAt time.Time) (bool, error) {
+	res, err := ir.sqlHandler.Exec(`
+		UPDATE thread_invites
+		SET revoked_at=?
+		WHERE id=? AND revoked_at IS NULL
+	`, revokedAt, id)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to revoke invite")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get affected rows")
+	}
+	return affected > 0, nil
+}
+
+// Use 使用回数を増やしてメンバーに追加する。満員ならentryで参加待ちに入れてfalseを返す。
+// 同時に使われても上限を超えないよう、使える状態のときだけ回数を増やす
+func (ir *inviteRepository) Use(use *entity.ThreadInviteUse, entry *entity.WaitlistEntry) (bool, error) {
+	added := false
+	err := ir.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		res, err := tx.Exec(`
+			UPDATE thread_invites
+			SET uses=uses+1
+			WHERE id=?
+				AND revoked_at IS NULL
+				AND (expires_at IS NULL OR expires_at > ?)
+				AND (max_uses = 0 OR uses < max_uses)
+		`, use.Invite.ID, use.CreatedAt)
+		if err != nil {
+			return errors.Wrap(err, "failed to update invite")
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return errors.Wrap(err, "failed to get affected rows")
+		}
+		if affected == 0 {
+			return errors.New("invite is no longer valid")
+		}
+		_, err = tx.Exec(`
+			INSERT INTO thread_invite_uses(id, invite_id, thread_id, user_id, created_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, use.ID, use.Invite.ID, use.Invite.Thread.ID, use.User.ID, use.CreatedAt)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert invite use")
+		}
+		added, err = addMemberOrWait(tx, entry)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return added, nil
+}
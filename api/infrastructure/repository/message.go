@@ -4,6 +4,8 @@ import (
 	"app/api/domain/entity"
 	"app/api/domain/repository"
 	"app/api/infrastructure/database"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -18,77 +20,644 @@ func NewMessageRepository(sh database.SQLHandler) repository.MessageRepository {
 	}
 }
 
+// messageColumns messagesをm、返信元をpとして読むカラム。scanMessageで読む
+const messageColumns = `
+	m.id, m.message, m.grade, m.created_at, m.edited_at, m.deleted_at, m.thread_id, m.user_id,
+	COALESCE(p.id, ''), COALESCE(p.message, ''), COALESCE(p.user_id, ''), p.deleted_at,
+	(SELECT COUNT(*) FROM messages AS r WHERE r.parent_id = m.id)
+`
+
+const messageTables = `
+	messages AS m
+	LEFT JOIN messages AS p
+	ON p.id = m.parent_id
+`
+
+// Create message.Mentionsがあればmessage_mentionsにも記録する
 func (mr *messageRepository) Create(message *entity.Message) error {
-	_, err := mr.sqlHandler.Exec(`
-		INSERT INTO messages(id, message, grade, created_at, thread_id, user_id)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`,
-		message.ID,
-		message.Message,
-		message.Grade,
-		message.CreatedAt,
-		message.Thread.ID,
-		message.Author.ID,
-	)
-	if err != nil {
-		return errors.Wrap(err, "failed to insert db")
+	var parentID interface{}
+	if message.Parent != nil {
+		parentID = message.Parent.ID
 	}
-	return nil
+	return mr.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		_, err := tx.Exec(`
+			INSERT INTO messages(id, message, grade, created_at, thread_id, user_id, parent_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`,
+			message.ID,
+			message.Message,
+			message.Grade,
+			message.CreatedAt,
+			message.Thread.ID,
+			message.Author.ID,
+			parentID,
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert db")
+		}
+		for _, mention := range message.Mentions {
+			_, err = tx.Exec(`
+				INSERT INTO message_mentions(id, message_id, user_id, created_at)
+				VALUES (?, ?, ?, ?)
+			`, mention.ID, message.ID, mention.User.ID, message.CreatedAt)
+			if err != nil {
+				return errors.Wrap(err, "failed to insert mention")
+			}
+		}
+		return nil
+	})
 }
 
 func (mr *messageRepository) GetByID(id string) (*entity.Message, error) {
 	row := mr.sqlHandler.QueryRow(`
-		SELECT id, message, grade, created_at, thread_id, user_id
-		FROM messages
-		WHERE id=?
+		SELECT `+messageColumns+`
+		FROM `+messageTables+`
+		WHERE m.id=?
 	`, id)
+	message, err := scanMessage(row)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+	return message, nil
+
+}
+
+// Update revisionに編集前の本文を残してから本文を書き換える
+func (mr *messageRepository) Update(message *entity.Message, revision *entity.MessageRevision) error {
+	return mr.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		_, err := tx.Exec(`
+			INSERT INTO message_revisions(id, message_id, message, edited_at)
+			VALUES (?, ?, ?, ?)
+		`,
+			revision.ID,
+			message.ID,
+			revision.Body,
+			revision.EditedAt,
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert revision")
+		}
+		_, err = tx.Exec(`
+			UPDATE messages
+			SET message=?, edited_at=?
+			WHERE id=?
+		`,
+			message.Message,
+			message.EditedAt,
+			message.ID,
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to update db")
+		}
+		return nil
+	})
+}
+
+// Delete 本文を空にしたtombstoneを残し、編集履歴とリアクションを消して監査ログを記録する
+func (mr *messageRepository) Delete(message *entity.Message, deletion *entity.MessageDeletion) error {
+	return mr.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		_, err := tx.Exec(`
+			UPDATE messages
+			SET message='', deleted_at=?
+			WHERE id=?
+		`, message.DeletedAt, message.ID)
+		if err != nil {
+			return errors.Wrap(err, "failed to update db")
+		}
+		if _, err = tx.Exec(`DELETE FROM message_revisions WHERE message_id=?`, message.ID); err != nil {
+			return errors.Wrap(err, "failed to delete revisions")
+		}
+		if _, err = tx.Exec(`DELETE FROM message_reactions WHERE message_id=?`, message.ID); err != nil {
+			return errors.Wrap(err, "failed to delete reactions")
+		}
+		if _, err = tx.Exec(`DELETE FROM pinned_messages WHERE message_id=?`, message.ID); err != nil {
+			return errors.Wrap(err, "failed to delete pin")
+		}
+		_, err = tx.Exec(`
+			INSERT INTO message_deletions(id, message_id, thread_id, author_id, deleted_by, reason, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`,
+			deletion.ID,
+			message.ID,
+			message.Thread.ID,
+			message.Author.ID,
+			deletion.DeletedBy.ID,
+			deletion.Reason,
+			deletion.CreatedAt,
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert deletion log")
+		}
+		return nil
+	})
+}
+
+// FindDeletionsByThreadID 新しい順
+func (mr *messageRepository) FindDeletionsByThreadID(threadID string) ([]*entity.MessageDeletion, error) {
+	rows, err := mr.sqlHandler.Query(`
+		SELECT id, message_id, author_id, deleted_by, reason, created_at
+		FROM message_deletions
+		WHERE thread_id=?
+		ORDER BY created_at DESC, id DESC
+	`, threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var deletions []*entity.MessageDeletion
+	for rows.Next() {
+		var deletion entity.MessageDeletion
+		var message entity.Message
+		var author, deletedBy entity.User
+		if err = rows.Scan(&deletion.ID, &message.ID, &author.ID, &deletedBy.ID, &deletion.Reason, &deletion.CreatedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		deletion.Thread = &entity.Thread{ID: threadID}
+		message.Thread = deletion.Thread
+		message.Author = &author
+		deletion.Message = &message
+		deletion.Author = &author
+		deletion.DeletedBy = &deletedBy
+		deletions = append(deletions, &deletion)
+	}
+	return deletions, nil
+}
+
+// FindRevisionsByMessageID 新しい順
+func (mr *messageRepository) FindRevisionsByMessageID(messageID string) ([]*entity.MessageRevision, error) {
+	rows, err := mr.sqlHandler.Query(`
+		SELECT id, message, edited_at
+		FROM message_revisions
+		WHERE message_id=?
+		ORDER BY edited_at DESC, id DESC
+	`, messageID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var revisions []*entity.MessageRevision
+	for rows.Next() {
+		var revision entity.MessageRevision
+		if err = rows.Scan(&revision.ID, &revision.Body, &revision.EditedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		revision.Message = &entity.Message{ID: messageID}
+		revisions = append(revisions, &revision)
+	}
+	return revisions, nil
+}
+
+// FindByIDs 順序は保証しない。見つからないIDは無視する
+func (mr *messageRepository) FindByIDs(ids []string) ([]*entity.Message, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := mr.sqlHandler.Query(`
+		SELECT `+messageColumns+`
+		FROM `+messageTables+`
+		WHERE m.id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+	`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	return scanMessages(rows)
+}
+
+// GetByThreadIDBefore (created_at, id)がcursorより前のメッセージを新しい順にlimit件取得する。cursorがnilなら最新から
+func (mr *messageRepository) GetByThreadIDBefore(threadID string, cursor *entity.MessageCursor, limit int) ([]*entity.Message, error) {
+	var rows database.SQLRows
+	var err error
+	if cursor == nil {
+		rows, err = mr.sqlHandler.Query(`
+			SELECT `+messageColumns+`
+			FROM `+messageTables+`
+			WHERE m.thread_id=?
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT ?
+		`, threadID, limit)
+	} else {
+		rows, err = mr.sqlHandler.Query(`
+			SELECT `+messageColumns+`
+			FROM `+messageTables+`
+			WHERE m.thread_id=? AND (m.created_at < ? OR (m.created_at = ? AND m.id < ?))
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT ?
+		`, threadID, cursor.CreatedAt, cursor.CreatedAt, cursor.ID, limit)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	return scanMessages(rows)
+}
+
+// GetByThreadIDAfter (created_at, id)がcursorより後のメッセージを古い順にlimit件取得する
+func (mr *messageRepository) GetByThreadIDAfter(threadID string, cursor *entity.MessageCursor, limit int) ([]*entity.Message, error) {
+	rows, err := mr.sqlHandler.Query(`
+		SELECT `+messageColumns+`
+		FROM `+messageTables+`
+		WHERE m.thread_id=? AND (m.created_at > ? OR (m.created_at = ? AND m.id > ?))
+		ORDER BY m.created_at ASC, m.id ASC
+		LIMIT ?
+	`, threadID, cursor.CreatedAt, cursor.CreatedAt, cursor.ID, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	return scanMessages(rows)
+}
+
+// GetReplies 返信を古い順にlimit件取得する
+func (mr *messageRepository) GetReplies(parentID string, limit int) ([]*entity.Message, error) {
+	rows, err := mr.sqlHandler.Query(`
+		SELECT `+messageColumns+`
+		FROM `+messageTables+`
+		WHERE m.parent_id=?
+		ORDER BY m.created_at ASC, m.id ASC
+		LIMIT ?
+	`, parentID, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	return scanMessages(rows)
+}
+
+// GetLatestByThreadIDAfter (created_at, id)がcursorより後のメッセージを新しい順にlimit件取得する
+func (mr *messageRepository) GetLatestByThreadIDAfter(threadID string, createdAt *time.Time, id string, limit int) ([]*entity.Message, error) {
+	rows, err := mr.sqlHandler.Query(`
+		SELECT `+messageColumns+`
+		FROM `+messageTables+`
+		WHERE m.thread_id=? AND (m.created_at > ? OR (m.created_at = ? AND m.id > ?))
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT ?
+	`, threadID, createdAt, createdAt, id, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	return scanMessages(rows)
+}
+
+func scanMessages(rows database.SQLRows) ([]*entity.Message, error) {
+	var messages []*entity.Message
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// prefixScanner messageColumnsの前に別のカラムを読むときに使う
+type prefixScanner struct {
+	row    rowScanner
+	prefix []interface{}
+}
+
+func (ps *prefixScanner) Scan(dest ...interface{}) error {
+	return ps.row.Scan(append(ps.prefix, dest...)...)
+}
+
+// scanMessage messageColumnsの順に読む
+func scanMessage(row rowScanner) (*entity.Message, error) {
 	var message entity.Message
 	var user entity.User
 	var thread entity.Thread
-	if err := row.Scan(&message.ID, &message.Message, &message.Grade, &message.CreatedAt, &thread.ID, &user.ID); err != nil {
-		return nil, errors.Wrap(err, "failed to scan")
+	var parent entity.Message
+	var parentAuthor entity.User
+	err := row.Scan(
+		&message.ID, &message.Message, &message.Grade, &message.CreatedAt, &message.EditedAt, &message.DeletedAt, &thread.ID, &user.ID,
+		&parent.ID, &parent.Message, &parentAuthor.ID, &parent.DeletedAt,
+		&message.ReplyCount,
+	)
+	if err != nil {
+		return nil, err
 	}
 	message.Author = &user
 	message.Thread = &thread
+	if parent.ID != "" {
+		parent.Author = &parentAuthor
+		parent.Thread = &thread
+		message.Parent = &parent
+	}
 	return &message, nil
+}
+
+// AddReaction 既に同じリアクションをしていればfalse
+func (mr *messageRepository) AddReaction(id, messageID, userUUID, reaction string, createdAt *time.Time) (bool, error) {
+	res, err := mr.sqlHandler.Exec(`
+		INSERT IGNORE INTO message_reactions(id, user_id, message_id, reaction, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, id, userUUID, messageID, reaction, createdAt)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to insert db")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get affected rows")
+	}
+	return affected > 0, nil
+}
+
+// AddPin 既にピン留めされていればfalse。スレッドの行をロックしてから数えるので、
+// 同時にピン留めされてもlimit件を超えない
+func (mr *messageRepository) AddPin(pin *entity.Pin, limit int) (bool, error) {
+	added := false
+	err := mr.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		row := tx.QueryRow(`
+			SELECT id
+			FROM threads
+			WHERE id=?
+			FOR UPDATE
+		`, pin.Thread.ID)
+		var id string
+		if err := row.Scan(&id); err != nil {
+			return errors.Wrap(err, "failed to lock thread")
+		}
+		row = tx.QueryRow(`
+			SELECT COUNT(*)
+			FROM pinned_messages
+			WHERE thread_id=?
+		`, pin.Thread.ID)
+		var count int
+		if err := row.Scan(&count); err != nil {
+			return errors.Wrap(err, "failed to count pins")
+		}
+		if count >= limit {
+			return errors.Errorf("thread can have at most %d pinned messages", limit)
+		}
+		res, err := tx.Exec(`
+			INSERT IGNORE INTO pinned_messages(id, thread_id, message_id, user_id, created_at)
+			VALUES (?, ?, ?, ?, ?)
+		`,
+			pin.ID,
+			pin.Thread.ID,
+			pin.Message.ID,
+			pin.PinnedBy.ID,
+			pin.CreatedAt,
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert db")
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return errors.Wrap(err, "failed to get affected rows")
+		}
+		added = affected > 0
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return added, nil
+}
 
+// RemovePin ピン留めされていなければfalse
+func (mr *messageRepository) RemovePin(threadID, messageID string) (bool, error) {
+	res, err := mr.sqlHandler.Exec(`
+		DELETE FROM pinned_messages
+		WHERE thread_id=? AND message_id=?
+	`, threadID, messageID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get affected rows")
+	}
+	return affected > 0, nil
 }
 
-func (mr *messageRepository) GetByThreadID(threadID string) ([]*entity.Message, error) {
+// FindPinsByThreadID 新しくピン留めされた順に取得する
+func (mr *messageRepository) FindPinsByThreadID(threadID string) ([]*entity.Pin, error) {
 	rows, err := mr.sqlHandler.Query(`
-		SELECT id, message, grade, created_at, thread_id, user_id
-		FROM messages
-		WHERE thread_id=?
+		SELECT pm.id, pm.user_id, pm.created_at, `+messageColumns+`
+		FROM `+messageTables+`
+		JOIN pinned_messages AS pm
+		ON pm.message_id = m.id
+		WHERE pm.thread_id=?
+		ORDER BY pm.created_at DESC, pm.id DESC
 	`, threadID)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to select")
 	}
-	var messages []*entity.Message
+	defer rows.Close()
+	var pins []*entity.Pin
 	for rows.Next() {
-		var message entity.Message
-		var user entity.User
-		var thread entity.Thread
-		if err = rows.Scan(&message.ID, &message.Message, &message.Grade, &message.CreatedAt, &thread.ID, &user.ID); err != nil {
-			if rows.CheckNoRows(err) {
-				return nil, nil
-			}
+		var pin entity.Pin
+		var pinnedBy entity.User
+		message, err := scanMessage(&prefixScanner{row: rows, prefix: []interface{}{&pin.ID, &pinnedBy.ID, &pin.CreatedAt}})
+		if err != nil {
 			return nil, errors.Wrap(err, "failed to scan")
 		}
-		message.Author = &user
-		message.Thread = &thread
-		messages = append(messages, &message)
+		pin.Message = message
+		pin.Thread = message.Thread
+		pin.PinnedBy = &pinnedBy
+		pins = append(pins, &pin)
 	}
-	return messages, nil
+	return pins, nil
+}
 
+// FindMentionedByUserID userUUIDのユーザがメンションされたメッセージを新しい順にlimit件取得する。
+// 削除されたものと、もう参加していないスレッドのものは除く
+func (mr *messageRepository) FindMentionedByUserID(userUUID string, cursor *entity.MessageCursor, limit int) ([]*entity.Message, error) {
+	var rows database.SQLRows
+	var err error
+	if cursor == nil {
+		rows, err = mr.sqlHandler.Query(`
+			SELECT `+messageColumns+`
+			FROM `+messageTables+`
+			JOIN message_mentions AS mm
+			ON mm.message_id = m.id
+			JOIN users_threads AS ut
+			ON ut.thread_id = m.thread_id AND ut.user_id = mm.user_id
+			WHERE mm.user_id=? AND m.deleted_at IS NULL
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT ?
+		`, userUUID, limit)
+	} else {
+		rows, err = mr.sqlHandler.Query(`
+			SELECT `+messageColumns+`
+			FROM `+messageTables+`
+			JOIN message_mentions AS mm
+			ON mm.message_id = m.id
+			JOIN users_threads AS ut
+			ON ut.thread_id = m.thread_id AND ut.user_id = mm.user_id
+			WHERE mm.user_id=? AND m.deleted_at IS NULL AND (m.created_at < ? OR (m.created_at = ? AND m.id < ?))
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT ?
+		`, userUUID, cursor.CreatedAt, cursor.CreatedAt, cursor.ID, limit)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	return scanMessages(rows)
 }
 
-func (mr *messageRepository) AddFavorite(id, messageID, userUUID string) error {
+// RemoveReaction リアクションしていなければfalse
+func (mr *messageRepository) RemoveReaction(messageID, userUUID, reaction string) (bool, error) {
+	res, err := mr.sqlHandler.Exec(`
+		DELETE FROM message_reactions
+		WHERE message_id=? AND user_id=? AND reaction=?
+	`, messageID, userUUID, reaction)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get affected rows")
+	}
+	return affected > 0, nil
+}
+
+// CountReactions メッセージIDをkeyに、最初にリアクションされた順に集計する。userUUIDはReactedの判定に使う
+func (mr *messageRepository) CountReactions(messageIDs []string, userUUID string) (map[string][]*entity.Reaction, error) {
+	result := make(map[string][]*entity.Reaction)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+	args := make([]interface{}, 0, len(messageIDs)+1)
+	args = append(args, userUUID)
+	for _, id := range messageIDs {
+		args = append(args, id)
+	}
+	rows, err := mr.sqlHandler.Query(`
+		SELECT message_id, reaction, COUNT(*), SUM(user_id = ?)
+		FROM message_reactions
+		WHERE message_id IN (?`+strings.Repeat(", ?", len(messageIDs)-1)+`)
+		GROUP BY message_id, reaction
+		ORDER BY MIN(created_at), reaction
+	`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var messageID string
+		var reaction entity.Reaction
+		var reacted int
+		if err = rows.Scan(&messageID, &reaction.Reaction, &reaction.Count, &reacted); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		reaction.Reacted = reacted > 0
+		result[messageID] = append(result[messageID], &reaction)
+	}
+	return result, nil
+}
+
+// FindReadMarker 既読位置がなければnilを返す
+func (mr *messageRepository) FindReadMarker(threadID, userUUID string) (*entity.ReadMarker, error) {
+	row := mr.sqlHandler.QueryRow(`
+		SELECT rm.id, rm.read_at, m.id, m.created_at
+		FROM read_markers AS rm
+		JOIN messages AS m
+		ON rm.message_id = m.id
+		WHERE rm.thread_id=? AND rm.user_id=?
+	`, threadID, userUUID)
+	var marker entity.ReadMarker
+	var message entity.Message
+	if err := row.Scan(&marker.ID, &marker.ReadAt, &message.ID, &message.CreatedAt); err != nil {
+		if row.CheckNoRows(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+	marker.User = &entity.User{ID: userUUID}
+	marker.Thread = &entity.Thread{ID: threadID}
+	message.Thread = marker.Thread
+	marker.Message = &message
+	return &marker, nil
+}
+
+func (mr *messageRepository) FindReadMarkersByThreadID(threadID string) ([]*entity.ReadMarker, error) {
+	rows, err := mr.sqlHandler.Query(`
+		SELECT rm.id, rm.user_id, rm.read_at, m.id, m.created_at
+		FROM read_markers AS rm
+		JOIN messages AS m
+		ON rm.message_id = m.id
+		WHERE rm.thread_id=?
+		ORDER BY m.created_at DESC, m.id DESC
+	`, threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var markers []*entity.ReadMarker
+	for rows.Next() {
+		var marker entity.ReadMarker
+		var user entity.User
+		var message entity.Message
+		if err = rows.Scan(&marker.ID, &user.ID, &marker.ReadAt, &message.ID, &message.CreatedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		marker.User = &user
+		marker.Thread = &entity.Thread{ID: threadID}
+		message.Thread = marker.Thread
+		marker.Message = &message
+		markers = append(markers, &marker)
+	}
+	return markers, nil
+}
+
+func (mr *messageRepository) SaveReadMarker(marker *entity.ReadMarker) error {
 	_, err := mr.sqlHandler.Exec(`
-		INSERT INTO users_favorites(id, user_id, message_id)
-		VALUES (?, ?, ?)
-	`, id, userUUID, messageID)
+		INSERT INTO read_markers(id, user_id, thread_id, message_id, read_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE message_id=VALUES(message_id), read_at=VALUES(read_at)
+	`,
+		marker.ID,
+		marker.User.ID,
+		marker.Thread.ID,
+		marker.Message.ID,
+		marker.ReadAt,
+	)
 	if err != nil {
 		return errors.Wrap(err, "failed to insert db")
 	}
 	return nil
 }
+
+// CountUnreadByUserID 参加しているスレッドごとに既読位置より後の、自分以外の削除されていないメッセージを数える
+func (mr *messageRepository) CountUnreadByUserID(userUUID string) ([]*entity.UnreadCount, error) {
+	rows, err := mr.sqlHandler.Query(`
+		SELECT ut.thread_id, COALESCE(rm.message_id, ''), COUNT(m.id)
+		FROM users_threads AS ut
+		LEFT JOIN read_markers AS rm
+		ON rm.user_id = ut.user_id AND rm.thread_id = ut.thread_id
+		LEFT JOIN messages AS lm
+		ON lm.id = rm.message_id
+		LEFT JOIN messages AS m
+		ON m.thread_id = ut.thread_id
+			AND m.user_id <> ut.user_id
+			AND m.deleted_at IS NULL
+			AND (lm.id IS NULL OR m.created_at > lm.created_at OR (m.created_at = lm.created_at AND m.id > lm.id))
+		WHERE ut.user_id=?
+		GROUP BY ut.thread_id, rm.message_id
+	`, userUUID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var counts []*entity.UnreadCount
+	for rows.Next() {
+		var count entity.UnreadCount
+		if err = rows.Scan(&count.ThreadID, &count.LastReadMessageID, &count.Count); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		counts = append(counts, &count)
+	}
+	return counts, nil
+}
@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"app/api/constants"
+	"app/api/domain/entity"
+	"app/api/domain/repository"
+	"app/api/infrastructure/database"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// NewMessageSearchIndex SEARCH_INDEX=memoryならプロセス内の索引、それ以外はMySQLを使う
+func NewMessageSearchIndex(sh database.SQLHandler) repository.MessageSearchIndex {
+	if os.Getenv("SEARCH_INDEX") == "memory" {
+		return NewMemoryMessageSearchIndex()
+	}
+	return NewMySQLMessageSearchIndex(sh)
+}
+
+type mysqlMessageSearchIndex struct {
+	sqlHandler database.SQLHandler
+}
+
+// NewMySQLMessageSearchIndex messages.messageのFULLTEXT(ngram)索引で検索する
+func NewMySQLMessageSearchIndex(sh database.SQLHandler) repository.MessageSearchIndex {
+	return &mysqlMessageSearchIndex{
+		sqlHandler: sh,
+	}
+}
+
+// Index FULLTEXT索引はMySQLが更新するので何もしない
+func (si *mysqlMessageSearchIndex) Index(message *entity.Message) error {
+	return nil
+}
+
+// Remove 削除されたメッセージは本文が空になるので何もしない
+func (si *mysqlMessageSearchIndex) Remove(messageID string) error {
+	return nil
+}
+
+func (si *mysqlMessageSearchIndex) Persistent() bool {
+	return true
+}
+
+func (si *mysqlMessageSearchIndex) Search(query *entity.MessageSearchQuery) ([]string, error) {
+	if len(query.ThreadIDs) == 0 {
+		return nil, nil
+	}
+	var against []string
+	for _, term := range query.Terms {
+		// BOOLEAN MODEの演算子として解釈されないように語ごとにフレーズにする
+		against = append(against, `+"`+strings.Replace(term, `"`, "", -1)+`"`)
+	}
+	where := []string{
+		"MATCH(message) AGAINST(? IN BOOLEAN MODE)",
+		"deleted_at IS NULL",
+		"thread_id IN (?" + strings.Repeat(", ?", len(query.ThreadIDs)-1) + ")",
+	}
+	args := []interface{}{strings.Join(against, " ")}
+	for _, threadID := range query.ThreadIDs {
+		args = append(args, threadID)
+	}
+	if query.AuthorID != "" {
+		where = append(where, "user_id=?")
+		args = append(args, query.AuthorID)
+	}
+	if query.Since != nil {
+		where = append(where, "created_at >= ?")
+		args = append(args, query.Since)
+	}
+	if query.Until != nil {
+		where = append(where, "created_at < ?")
+		args = append(args, query.Until)
+	}
+	if query.HasFile != nil {
+		if *query.HasFile {
+			where = append(where, "grade = ?")
+		} else {
+			where = append(where, "grade <> ?")
+		}
+		args = append(args, constants.FileMessageGrade)
+	}
+	if query.Before != nil {
+		where = append(where, "(created_at < ? OR (created_at = ? AND id < ?))")
+		args = append(args, query.Before.CreatedAt, query.Before.CreatedAt, query.Before.ID)
+	}
+	args = append(args, query.Limit)
+	rows, err := si.sqlHandler.Query(`
+		SELECT id
+		FROM messages
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+type memoryMessageSearchIndex struct {
+	mu      sync.RWMutex
+	entries map[string]*searchEntry
+}
+
+// searchEntry 検索に使う値だけ持つ。textは小文字にした本文
+type searchEntry struct {
+	id        string
+	threadID  string
+	authorID  string
+	grade     int
+	createdAt time.Time
+	text      string
+}
+
+// NewMemoryMessageSearchIndex プロセス内に本文を持って検索する。単一プロセスでの開発・検証用
+func NewMemoryMessageSearchIndex() repository.MessageSearchIndex {
+	return &memoryMessageSearchIndex{
+		entries: make(map[string]*searchEntry),
+	}
+}
+
+// Index 既にあれば置き換える。削除されたメッセージは索引から外す
+func (si *memoryMessageSearchIndex) Index(message *entity.Message) error {
+	if message.DeletedAt != nil {
+		return si.Remove(message.ID)
+	}
+	entry := &searchEntry{
+		id:        message.ID,
+		threadID:  message.Thread.ID,
+		authorID:  message.Author.ID,
+		grade:     message.Grade,
+		createdAt: *message.CreatedAt,
+		text:      strings.ToLower(message.Message),
+	}
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	si.entries[message.ID] = entry
+	return nil
+}
+
+func (si *memoryMessageSearchIndex) Remove(messageID string) error {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	delete(si.entries, messageID)
+	return nil
+}
+
+func (si *memoryMessageSearchIndex) Persistent() bool {
+	return false
+}
+
+// Search 索引の全件を走査する
+func (si *memoryMessageSearchIndex) Search(query *entity.MessageSearchQuery) ([]string, error) {
+	threads := make(map[string]bool, len(query.ThreadIDs))
+	for _, threadID := range query.ThreadIDs {
+		threads[threadID] = true
+	}
+	terms := make([]string, 0, len(query.Terms))
+	for _, term := range query.Terms {
+		terms = append(terms, strings.ToLower(term))
+	}
+
+	si.mu.RLock()
+	var hits []*searchEntry
+	for _, entry := range si.entries {
+		if entry.match(query, threads, terms) {
+			hits = append(hits, entry)
+		}
+	}
+	si.mu.RUnlock()
+
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].newerThan(hits[j].createdAt, hits[j].id)
+	})
+	if len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+	ids := make([]string, 0, len(hits))
+	for _, entry := range hits {
+		ids = append(ids, entry.id)
+	}
+	return ids, nil
+}
+
+func (e *searchEntry) match(query *entity.MessageSearchQuery, threads map[string]bool, terms []string) bool {
+	if !threads[e.threadID] {
+		return false
+	}
+	if query.AuthorID != "" && e.authorID != query.AuthorID {
+		return false
+	}
+	if query.Since != nil && e.createdAt.Before(*query.Since) {
+		return false
+	}
+	if query.Until != nil && !e.createdAt.Before(*query.Until) {
+		return false
+	}
+	if query.HasFile != nil && *query.HasFile != (e.grade == constants.FileMessageGrade) {
+		return false
+	}
+	if query.Before != nil && !e.olderThan(*query.Before.CreatedAt, query.Before.ID) {
+		return false
+	}
+	for _, term := range terms {
+		if !strings.Contains(e.text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// newerThan (created_at, id)の順でcreatedAt, idより後か
+func (e *searchEntry) newerThan(createdAt time.Time, id string) bool {
+	if e.createdAt.Equal(createdAt) {
+		return e.id > id
+	}
+	return e.createdAt.After(createdAt)
+}
+
+func (e *searchEntry) olderThan(createdAt time.Time, id string) bool {
+	if e.createdAt.Equal(createdAt) {
+		return e.id < id
+	}
+	return e.createdAt.Before(createdAt)
+}
@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"app/api/constants"
 	"app/api/domain/entity"
 	"app/api/domain/repository"
 	"app/api/infrastructure/database"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -12,6 +15,10 @@ type threadRepository struct {
 	sqlHandler database.SQLHandler
 }
 
+// threadCountColumns threadsをtとして参照するクエリでメンバー数と参加待ちの人数を取る
+const threadCountColumns = `(SELECT COUNT(*) FROM users_threads AS mc WHERE mc.thread_id = t.id) AS member_count,
+			(SELECT COUNT(*) FROM thread_waitlists AS wc WHERE wc.thread_id = t.id) AS waitlist_count`
+
 func NewThreadRepository(sh database.SQLHandler) repository.ThreadRepository {
 	return &threadRepository{
 		sqlHandler: sh,
@@ -20,8 +27,8 @@ func NewThreadRepository(sh database.SQLHandler) repository.ThreadRepository {
 
 func (tr *threadRepository) Create(thread *entity.Thread) error {
 	_, err := tr.sqlHandler.Exec(`
-		INSERT INTO threads(id, name, description, limit_users, user_id, is_public, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO threads(id, name, description, limit_users, user_id, is_public, join_policy, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		thread.ID,
 		thread.Name,
@@ -29,6 +36,7 @@ func (tr *threadRepository) Create(thread *entity.Thread) error {
 		thread.LimitUsers,
 		thread.Author.ID,
 		thread.IsPublic,
+		string(thread.JoinPolicy),
 		thread.CreatedAt,
 		thread.UpdatedAt,
 	)
@@ -38,10 +46,12 @@ func (tr *threadRepository) Create(thread *entity.Thread) error {
 	return nil
 }
 
+// FindAll アーカイブしたスレッドは含めない
 func (tr *threadRepository) FindAll() ([]*entity.Thread, error) {
 	rows, err := tr.sqlHandler.Query(`
-		SELECT id, name, description, limit_users, user_id, is_public, created_at, updated_at
-		FROM threads
+		SELECT t.id, t.name, t.description, t.limit_users, t.user_id, t.is_public, t.join_policy, t.archived_at, ` + threadCountColumns + `, t.created_at, t.updated_at
+		FROM threads AS t
+		WHERE t.archived_at IS NULL
 	`)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to select")
@@ -50,7 +60,7 @@ func (tr *threadRepository) FindAll() ([]*entity.Thread, error) {
 	for rows.Next() {
 		var thread entity.Thread
 		var author entity.User
-		if err = rows.Scan(&thread.ID, &thread.Name, &thread.Description, &thread.LimitUsers, &author.ID, &thread.IsPublic, &thread.CreatedAt, &thread.UpdatedAt); err != nil {
+		if err = rows.Scan(&thread.ID, &thread.Name, &thread.Description, &thread.LimitUsers, &author.ID, &thread.IsPublic, &thread.JoinPolicy, &thread.ArchivedAt, &thread.MemberCount, &thread.WaitlistCount, &thread.CreatedAt, &thread.UpdatedAt); err != nil {
 			if rows.CheckNoRows(err) {
 				return nil, nil
 			}
@@ -62,15 +72,36 @@ func (tr *threadRepository) FindAll() ([]*entity.Thread, error) {
 	return threads, nil
 }
 
+// FindAllIDs アーカイブしたスレッドも含める
+func (tr *threadRepository) FindAllIDs() ([]string, error) {
+	rows, err := tr.sqlHandler.Query(`
+		SELECT id
+		FROM threads
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 func (tr *threadRepository) FindByID(id string) (*entity.Thread, error) {
 	row := tr.sqlHandler.QueryRow(`
-		SELECT id, name, description, limit_users, user_id, is_public, created_at, updated_at
-		FROM threads
-		WHERE id=?
+		SELECT t.id, t.name, t.description, t.limit_users, t.user_id, t.is_public, t.join_policy, t.archived_at, `+threadCountColumns+`, t.created_at, t.updated_at
+		FROM threads AS t
+		WHERE t.id=?
 	`, id)
 	var thread entity.Thread
 	var author entity.User
-	if err := row.Scan(&thread.ID, &thread.Name, &thread.Description, &thread.LimitUsers, &author.ID, &thread.IsPublic, &thread.CreatedAt, &thread.UpdatedAt); err != nil {
+	if err := row.Scan(&thread.ID, &thread.Name, &thread.Description, &thread.LimitUsers, &author.ID, &thread.IsPublic, &thread.JoinPolicy, &thread.ArchivedAt, &thread.MemberCount, &thread.WaitlistCount, &thread.CreatedAt, &thread.UpdatedAt); err != nil {
 		return nil, errors.Wrap(err, "failed to scan")
 	}
 	thread.Author = &author
@@ -79,7 +110,7 @@ func (tr *threadRepository) FindByID(id string) (*entity.Thread, error) {
 
 func (tr *threadRepository) FindByUserID(userID string) ([]*entity.Thread, error) {
 	rows, err := tr.sqlHandler.Query(`
-		SELECT t.id, t.name, t.description, t.limit_users, t.user_id, t.is_public, t.created_at, t.updated_at
+		SELECT t.id, t.name, t.description, t.limit_users, t.user_id, t.is_public, t.join_policy, t.archived_at, `+threadCountColumns+`, t.created_at, t.updated_at
 		FROM threads AS t
 		JOIN users_threads AS ut
 		ON t.id = ut.thread_id
@@ -93,7 +124,7 @@ func (tr *threadRepository) FindByUserID(userID string) ([]*entity.Thread, error
 	for rows.Next() {
 		var thread entity.Thread
 		var author entity.User
-		if err = rows.Scan(&thread.ID, &thread.Name, &thread.Description, &thread.LimitUsers, &author.ID, &thread.IsPublic, &thread.CreatedAt, &thread.UpdatedAt); err != nil {
+		if err = rows.Scan(&thread.ID, &thread.Name, &thread.Description, &thread.LimitUsers, &author.ID, &thread.IsPublic, &thread.JoinPolicy, &thread.ArchivedAt, &thread.MemberCount, &thread.WaitlistCount, &thread.CreatedAt, &thread.UpdatedAt); err != nil {
 			if rows.CheckNoRows(err) {
 				return nil, nil
 			}
@@ -105,11 +136,12 @@ func (tr *threadRepository) FindByUserID(userID string) ([]*entity.Thread, error
 	return threads, nil
 }
 
+// FindOnlyPublic アーカイブしたスレッドは含めない
 func (tr *threadRepository) FindOnlyPublic() ([]*entity.Thread, error) {
 	rows, err := tr.sqlHandler.Query(`
-		SELECT id, name, description, limit_users, user_id, is_public, created_at, updated_at
-		FROM threads
-		WHERE is_public=1
+		SELECT t.id, t.name, t.description, t.limit_users, t.user_id, t.is_public, t.join_policy, t.archived_at, ` + threadCountColumns + `, t.created_at, t.updated_at
+		FROM threads AS t
+		WHERE t.is_public=1 AND t.archived_at IS NULL
 	`)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to select")
@@ -118,7 +150,7 @@ func (tr *threadRepository) FindOnlyPublic() ([]*entity.Thread, error) {
 	for rows.Next() {
 		var thread entity.Thread
 		var author entity.User
-		if err = rows.Scan(&thread.ID, &thread.Name, &thread.Description, &thread.LimitUsers, &author.ID, &thread.IsPublic, &thread.CreatedAt, &thread.UpdatedAt); err != nil {
+		if err = rows.Scan(&thread.ID, &thread.Name, &thread.Description, &thread.LimitUsers, &author.ID, &thread.IsPublic, &thread.JoinPolicy, &thread.ArchivedAt, &thread.MemberCount, &thread.WaitlistCount, &thread.CreatedAt, &thread.UpdatedAt); err != nil {
 			if rows.CheckNoRows(err) {
 				return nil, nil
 			}
@@ -130,6 +162,169 @@ func (tr *threadRepository) FindOnlyPublic() ([]*entity.Thread, error) {
 	return threads, nil
 }
 
+// Search threads_tagsとtagsを結合して1つのクエリで絞り込む
+func (tr *threadRepository) Search(query *entity.ThreadSearchQuery) ([]*entity.ThreadSearchHit, error) {
+	where := []string{"t.archived_at IS NULL", "(t.is_public = 1 OR EXISTS (SELECT 1 FROM users_threads AS ut WHERE ut.thread_id = t.id AND ut.user_id = ?))"}
+	args := []interface{}{query.UserID}
+	for _, keyword := range query.Keywords {
+		pattern := "%" + escapeLike(keyword) + "%"
+		where = append(where, "(t.name LIKE ? OR t.description LIKE ?)")
+		args = append(args, pattern, pattern)
+	}
+	if len(query.TagIDs) > 0 {
+		where = append(where, "tt.tag_id IN (?"+strings.Repeat(", ?", len(query.TagIDs)-1)+")")
+		for _, tagID := range query.TagIDs {
+			args = append(args, tagID)
+		}
+	}
+	if query.CategoryID != "" {
+		where = append(where, "tg.category_id = ?")
+		args = append(args, query.CategoryID)
+	}
+	if query.IsPublic != nil {
+		where = append(where, "t.is_public = ?")
+		args = append(args, *query.IsPublic)
+	}
+
+	var having []string
+	if query.HasVacancy {
+		having = append(having, "(t.limit_users IS NULL OR t.limit_users = 0 OR member_count < t.limit_users)")
+	}
+	if query.ActiveSince != nil {
+		having = append(having, "last_active_at >= ?")
+		args = append(args, query.ActiveSince)
+	}
+	havingClause := ""
+	if len(having) > 0 {
+		havingClause = "HAVING " + strings.Join(having, " AND ")
+	}
+
+	var orderBy string
+	switch query.Sort {
+	case entity.ThreadSortActive:
+		orderBy = "last_active_at IS NULL, last_active_at DESC, t.id"
+	case entity.ThreadSortMembers:
+		orderBy = "member_count DESC, t.created_at DESC, t.id"
+	case entity.ThreadSortName:
+		orderBy = "t.name, t.id"
+	default:
+		orderBy = "t.created_at DESC, t.id"
+	}
+	args = append(args, query.Limit, query.Offset)
+
+	rows, err := tr.sqlHandler.Query(`
+		SELECT t.id, t.name, t.description, t.limit_users, t.user_id, t.is_public, t.join_policy, t.archived_at, t.created_at, t.updated_at,
+			`+threadCountColumns+`,
+			(SELECT MAX(m.created_at) FROM messages AS m WHERE m.thread_id = t.id) AS last_active_at
+		FROM threads AS t
+		LEFT JOIN threads_tags AS tt
+		ON tt.thread_id = t.id
+		LEFT JOIN tags AS tg
+		ON tg.id = tt.tag_id
+		WHERE `+strings.Join(where, " AND ")+`
+		GROUP BY t.id
+		`+havingClause+`
+		ORDER BY `+orderBy+`
+		LIMIT ? OFFSET ?
+	`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var hits []*entity.ThreadSearchHit
+	for rows.Next() {
+		var thread entity.Thread
+		var author entity.User
+		var hit entity.ThreadSearchHit
+		if err = rows.Scan(&thread.ID, &thread.Name, &thread.Description, &thread.LimitUsers, &author.ID, &thread.IsPublic, &thread.JoinPolicy, &thread.ArchivedAt, &thread.CreatedAt, &thread.UpdatedAt, &thread.MemberCount, &thread.WaitlistCount, &hit.LastActiveAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		thread.Author = &author
+		hit.MemberCount = thread.MemberCount
+		hit.Thread = &thread
+		hits = append(hits, &hit)
+	}
+	return hits, nil
+}
+
+// FindRecommendations userUUIDのユーザが参加できる、BANされていない公開スレッドを、タグ・カテゴリの一致と
+// フォロー中のユーザの最近の発言からスコアを付けて高い順に取得する。スコアが0のものは除く
+func (tr *threadRepository) FindRecommendations(userUUID string, activeSince *time.Time, limit int) ([]*entity.ThreadRecommendation, error) {
+	rows, err := tr.sqlHandler.Query(`
+		SELECT id, name, description, limit_users, user_id, is_public, join_policy, archived_at, member_count, waitlist_count, created_at, updated_at,
+			tag_matches, category_matches, followed_active,
+			tag_matches * ? + category_matches * ? + followed_active * ? AS score
+		FROM (
+			SELECT t.*,
+				(SELECT COUNT(*)
+					FROM threads_tags AS tt
+					JOIN users_tags AS ut
+					ON ut.tag_id = tt.tag_id
+					WHERE tt.thread_id = t.id AND ut.user_id = ?) AS tag_matches,
+				(SELECT COUNT(DISTINCT tg.category_id)
+					FROM threads_tags AS tt
+					JOIN tags AS tg
+					ON tg.id = tt.tag_id
+					WHERE tt.thread_id = t.id AND tg.category_id IN (
+						SELECT utg.category_id
+						FROM users_tags AS ut
+						JOIN tags AS utg
+						ON utg.id = ut.tag_id
+						WHERE ut.user_id = ?
+					)) AS category_matches,
+				(SELECT COUNT(DISTINCT m.user_id)
+					FROM messages AS m
+					JOIN users_followers AS f
+					ON f.followed_user_id = m.user_id
+					WHERE m.thread_id = t.id AND f.user_id = ? AND m.created_at >= ?) AS followed_active,
+				`+threadCountColumns+`
+			FROM threads AS t
+			WHERE t.is_public = 1 AND t.join_policy <> 'invite_only' AND t.archived_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM users_threads AS ut WHERE ut.thread_id = t.id AND ut.user_id = ?)
+			AND NOT EXISTS (SELECT 1 FROM thread_bans AS b WHERE b.thread_id = t.id AND b.user_id = ? AND (b.expires_at IS NULL OR b.expires_at > ?))
+		) AS candidates
+		WHERE (limit_users IS NULL OR limit_users = 0 OR member_count < limit_users)
+		AND (tag_matches > 0 OR category_matches > 0 OR followed_active > 0)
+		ORDER BY score DESC, created_at DESC, id
+		LIMIT ?
+	`,
+		constants.RecommendTagWeight,
+		constants.RecommendCategoryWeight,
+		constants.RecommendFollowWeight,
+		userUUID,
+		userUUID,
+		userUUID,
+		activeSince,
+		userUUID,
+		userUUID,
+		time.Now(),
+		limit,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var recommendations []*entity.ThreadRecommendation
+	for rows.Next() {
+		var thread entity.Thread
+		var author entity.User
+		var rec entity.ThreadRecommendation
+		if err = rows.Scan(&thread.ID, &thread.Name, &thread.Description, &thread.LimitUsers, &author.ID, &thread.IsPublic, &thread.JoinPolicy, &thread.ArchivedAt, &thread.MemberCount, &thread.WaitlistCount, &thread.CreatedAt, &thread.UpdatedAt,
+			&rec.TagMatches, &rec.CategoryMatches, &rec.FollowedActive, &rec.Score); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		thread.Author = &author
+		rec.Thread = &thread
+		recommendations = append(recommendations, &rec)
+	}
+	return recommendations, nil
+}
+
+// escapeLike LIKEのワイルドカードを文字として扱う
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 func (tr *threadRepository) FindMembersByThreadID(id string) ([]*entity.User, error) {
 	rows, err := tr.sqlHandler.Query(`
 		SELECT u.id, u.user_id, u.name, u.image, u.profile, u.mail, u.login_at, u.created_at, u.updated_at
@@ -155,13 +350,14 @@ func (tr *threadRepository) FindMembersByThreadID(id string) ([]*entity.User, er
 func (tr *threadRepository) Update(thread *entity.Thread) error {
 	_, err := tr.sqlHandler.Exec(`
 		UPDATE threads
-		SET name=?, description=?, limit_users=?, is_public=?, updated_at=?
+		SET name=?, description=?, limit_users=?, is_public=?, join_policy=?, updated_at=?
 		WHERE id=?
 	`,
 		thread.Name,
 		thread.Description,
 		thread.LimitUsers,
 		thread.IsPublic,
+		string(thread.JoinPolicy),
 		thread.UpdatedAt,
 		thread.ID,
 	)
@@ -171,15 +367,113 @@ func (tr *threadRepository) Update(thread *entity.Thread) error {
 	return nil
 }
 
-func (tr *threadRepository) AddMember(id, threadID, userID string, isAdmin int) error {
-	_, err := tr.sqlHandler.Exec(`
-		INSERT INTO users_threads(id, user_id, thread_id, is_admin)
+// AddMember 満員ならfalseを返して追加しない
+func (tr *threadRepository) AddMember(id, threadID, userID string, role entity.Role) (bool, error) {
+	added := false
+	err := tr.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		vacant, err := lockVacancy(tx, threadID)
+		if err != nil || !vacant {
+			return err
+		}
+		if err = insertMember(tx, id, threadID, userID, role); err != nil {
+			return err
+		}
+		added = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return added, nil
+}
+
+// AddMemberOrWait 満員なら参加待ちに入れてfalseを返す。entry.IDはメンバーの行のidにも使う
+func (tr *threadRepository) AddMemberOrWait(entry *entity.WaitlistEntry) (bool, error) {
+	added := false
+	err := tr.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		var err error
+		added, err = addMemberOrWait(tx, entry)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return added, nil
+}
+
+func addMemberOrWait(tx database.SQLHandler, entry *entity.WaitlistEntry) (bool, error) {
+	vacant, err := lockVacancy(tx, entry.Thread.ID)
+	if err != nil {
+		return false, err
+	}
+	if vacant {
+		if err = insertMember(tx, entry.ID, entry.Thread.ID, entry.User.ID, entity.RoleMember); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	_, err = tx.Exec(`
+		INSERT INTO thread_waitlists(id, thread_id, user_id, created_at)
+		VALUES (?, ?, ?, ?)
+	`, entry.ID, entry.Thread.ID, entry.User.ID, entry.CreatedAt)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to insert waitlist")
+	}
+	return false, nil
+}
+
+// errThreadArchived アーカイブ中のスレッドにはメンバーを追加しない
+var errThreadArchived = errors.New("thread is archived")
+
+// lockVacancy スレッドの行をロックしてから数えるので、同時に参加されても人数制限を超えない。
+// メンバーを追加するトランザクションでは必ず先に呼ぶ。アーカイブ中ならerrThreadArchivedを返す
+func lockVacancy(tx database.SQLHandler, threadID string) (bool, error) {
+	row := tx.QueryRow(`
+		SELECT COALESCE(limit_users, 0), archived_at IS NOT NULL
+		FROM threads
+		WHERE id=?
+		FOR UPDATE
+	`, threadID)
+	var limitUsers int
+	var archived bool
+	if err := row.Scan(&limitUsers, &archived); err != nil {
+		return false, errors.Wrap(err, "failed to lock thread")
+	}
+	if archived {
+		return false, errThreadArchived
+	}
+	if limitUsers == 0 {
+		return true, nil
+	}
+	row = tx.QueryRow(`
+		SELECT COUNT(*)
+		FROM users_threads
+		WHERE thread_id=?
+	`, threadID)
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return false, errors.Wrap(err, "failed to count members")
+	}
+	return count < limitUsers, nil
+}
+
+// insertMember 参加待ちに入っていれば外す
+func insertMember(tx database.SQLHandler, id, threadID, userID string, role entity.Role) error {
+	_, err := tx.Exec(`
+		DELETE FROM thread_waitlists
+		WHERE user_id=? and thread_id=?
+	`, userID, threadID)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete waitlist")
+	}
+	_, err = tx.Exec(`
+		INSERT INTO users_threads(id, user_id, thread_id, role)
 		VALUES (?, ?, ?, ?)
 	`,
 		id,
 		userID,
 		threadID,
-		isAdmin,
+		string(role),
 	)
 	if err != nil {
 		return errors.Wrap(err, "failed to insert relation")
@@ -198,21 +492,630 @@ func (tr *threadRepository) RemoveMember(threadID, userID string) error {
 	return nil
 }
 
-func (tr *threadRepository) Delete(id string) error {
-	// NOTE: users_threadsのrelationの全切りしてる。nullとかのがいくね...?
-	_, err := tr.sqlHandler.Exec(`
-		DELETE FROM users_threads
+// FindRole メンバーでなければ空文字を返す
+func (tr *threadRepository) FindRole(threadID, userID string) (entity.Role, error) {
+	row := tr.sqlHandler.QueryRow(`
+		SELECT role
+		FROM users_threads
+		WHERE user_id=? and thread_id=?
+	`, userID, threadID)
+	var role string
+	if err := row.Scan(&role); err != nil {
+		if row.CheckNoRows(err) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "failed to scan")
+	}
+	return entity.Role(role), nil
+}
+
+// FindRolesByThreadID ユーザのUUIDをキーにする
+func (tr *threadRepository) FindRolesByThreadID(threadID string) (map[string]entity.Role, error) {
+	rows, err := tr.sqlHandler.Query(`
+		SELECT user_id, role
+		FROM users_threads
 		WHERE thread_id=?
-	`, id)
+	`, threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	roles := map[string]entity.Role{}
+	for rows.Next() {
+		var userID, role string
+		if err = rows.Scan(&userID, &role); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		roles[userID] = entity.Role(role)
+	}
+	return roles, nil
+}
+
+// UpdateRole メンバーでなければfalse
+func (tr *threadRepository) UpdateRole(threadID, userID string, role entity.Role) (bool, error) {
+	res, err := tr.sqlHandler.Exec(`
+		UPDATE users_threads
+		SET role=?
+		WHERE user_id=? and thread_id=?
+	`, string(role), userID, threadID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update role")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get affected rows")
+	}
+	return affected > 0, nil
+}
+
+// TransferOwner userIDのメンバーをownerにし、ownerIDのメンバーはmoderatorにする。
+// userIDがメンバーでなければfalse
+func (tr *threadRepository) TransferOwner(threadID, ownerID, userID string) (bool, error) {
+	transferred := false
+	err := tr.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		res, err := tx.Exec(`
+			UPDATE users_threads
+			SET role=?
+			WHERE user_id=? and thread_id=?
+		`, string(entity.RoleOwner), userID, threadID)
+		if err != nil {
+			return errors.Wrap(err, "failed to update role")
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return errors.Wrap(err, "failed to get affected rows")
+		}
+		if affected == 0 {
+			return nil
+		}
+		_, err = tx.Exec(`
+			UPDATE users_threads
+			SET role=?
+			WHERE user_id=? and thread_id=?
+		`, string(entity.RoleModerator), ownerID, threadID)
+		if err != nil {
+			return errors.Wrap(err, "failed to update role")
+		}
+		if err = updateAuthor(tx, threadID, userID); err != nil {
+			return err
+		}
+		transferred = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return transferred, nil
+}
+
+// RemoveOwner ownerをメンバーから外し、moderator, member, read_onlyの順に最も古くから参加しているメンバーをownerにする。
+// 後任のUUIDを返し、誰も残っていなければ空文字を返す
+func (tr *threadRepository) RemoveOwner(threadID, ownerID string) (string, error) {
+	var successorID string
+	err := tr.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		// 同時に抜けても後任が二重に決まらないようにスレッドの行をロックする
+		row := tx.QueryRow(`
+			SELECT id
+			FROM threads
+			WHERE id=?
+			FOR UPDATE
+		`, threadID)
+		var id string
+		if err := row.Scan(&id); err != nil {
+			return errors.Wrap(err, "failed to lock thread")
+		}
+		_, err := tx.Exec(`
+			DELETE FROM users_threads
+			WHERE user_id=? and thread_id=?
+		`, ownerID, threadID)
+		if err != nil {
+			return errors.Wrap(err, "failed to delete relation")
+		}
+		successorID, err = promoteSuccessor(tx, threadID)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return successorID, nil
+}
+
+// promoteSuccessor 残っているメンバーから後任のownerを決める。誰もいなければ空文字を返す
+func promoteSuccessor(tx database.SQLHandler, threadID string) (string, error) {
+	row := tx.QueryRow(`
+		SELECT user_id
+		FROM users_threads
+		WHERE thread_id=?
+		ORDER BY FIELD(role, ?, ?, ?), joined_at ASC, id ASC
+		LIMIT 1
+	`, threadID, string(entity.RoleModerator), string(entity.RoleMember), string(entity.RoleReadOnly))
+	var successorID string
+	if err := row.Scan(&successorID); err != nil {
+		if row.CheckNoRows(err) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "failed to scan")
+	}
+	_, err := tx.Exec(`
+		UPDATE users_threads
+		SET role=?
+		WHERE user_id=? and thread_id=?
+	`, string(entity.RoleOwner), successorID, threadID)
 	if err != nil {
-		return errors.Wrap(err, "failed to delete relations")
+		return "", errors.Wrap(err, "failed to update role")
 	}
-	_, err = tr.sqlHandler.Exec(`
-		DELETE FROM threads
+	if err = updateAuthor(tx, threadID, successorID); err != nil {
+		return "", err
+	}
+	return successorID, nil
+}
+
+// updateAuthor threads.user_idはownerと揃えておく
+func updateAuthor(tx database.SQLHandler, threadID, userID string) error {
+	_, err := tx.Exec(`
+		UPDATE threads
+		SET user_id=?
 		WHERE id=?
-	`, id)
+	`, userID, threadID)
+	if err != nil {
+		return errors.Wrap(err, "failed to update author")
+	}
+	return nil
+}
+
+// SaveBan メンバーから外してBANリストに入れる。既にBANされていれば理由と期限を上書きする
+func (tr *threadRepository) SaveBan(ban *entity.ThreadBan) error {
+	return tr.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		_, err := tx.Exec(`
+			DELETE FROM users_threads
+			WHERE user_id=? and thread_id=?
+		`, ban.User.ID, ban.Thread.ID)
+		if err != nil {
+			return errors.Wrap(err, "failed to delete relation")
+		}
+		_, err = tx.Exec(`
+			DELETE FROM thread_waitlists
+			WHERE user_id=? and thread_id=?
+		`, ban.User.ID, ban.Thread.ID)
+		if err != nil {
+			return errors.Wrap(err, "failed to delete waitlist")
+		}
+		_, err = tx.Exec(`
+			INSERT INTO thread_bans(id, thread_id, user_id, banned_by, reason, expires_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE banned_by=VALUES(banned_by), reason=VALUES(reason), expires_at=VALUES(expires_at), created_at=VALUES(created_at)
+		`,
+			ban.ID,
+			ban.Thread.ID,
+			ban.User.ID,
+			ban.BannedBy.ID,
+			ban.Reason,
+			ban.ExpiresAt,
+			ban.CreatedAt,
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert ban")
+		}
+		return nil
+	})
+}
+
+// RemoveBan BANされていなければfalse
+func (tr *threadRepository) RemoveBan(threadID, userID string) (bool, error) {
+	res, err := tr.sqlHandler.Exec(`
+		DELETE FROM thread_bans
+		WHERE thread_id=? AND user_id=?
+	`, threadID, userID)
 	if err != nil {
-		return errors.Wrap(err, "failed to delete")
+		return false, errors.Wrap(err, "failed to delete ban")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get affected rows")
+	}
+	return affected > 0, nil
+}
+
+// FindBan BANされていなければnilを返す。期限切れのものも返す
+func (tr *threadRepository) FindBan(threadID, userID string) (*entity.ThreadBan, error) {
+	row := tr.sqlHandler.QueryRow(`
+		SELECT id, banned_by, reason, expires_at, created_at
+		FROM thread_bans
+		WHERE thread_id=? AND user_id=?
+	`, threadID, userID)
+	var ban entity.ThreadBan
+	var bannedBy entity.User
+	if err := row.Scan(&ban.ID, &bannedBy.ID, &ban.Reason, &ban.ExpiresAt, &ban.CreatedAt); err != nil {
+		if row.CheckNoRows(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+	ban.Thread = &entity.Thread{ID: threadID}
+	ban.User = &entity.User{ID: userID}
+	ban.BannedBy = &bannedBy
+	return &ban, nil
+}
+
+// FindBansByThreadID 期限切れのものは除く
+func (tr *threadRepository) FindBansByThreadID(threadID string) ([]*entity.ThreadBan, error) {
+	rows, err := tr.sqlHandler.Query(`
+		SELECT id, user_id, banned_by, reason, expires_at, created_at
+		FROM thread_bans
+		WHERE thread_id=? AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY created_at DESC
+	`, threadID, time.Now())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var bans []*entity.ThreadBan
+	for rows.Next() {
+		var ban entity.ThreadBan
+		var user entity.User
+		var bannedBy entity.User
+		if err = rows.Scan(&ban.ID, &user.ID, &bannedBy.ID, &ban.Reason, &ban.ExpiresAt, &ban.CreatedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		ban.Thread = &entity.Thread{ID: threadID}
+		ban.User = &user
+		ban.BannedBy = &bannedBy
+		bans = append(bans, &ban)
+	}
+	return bans, nil
+}
+
+// SaveJoinRequest 却下されたあとに申請し直したときは同じ行をpendingに戻す
+func (tr *threadRepository) SaveJoinRequest(request *entity.JoinRequest) error {
+	_, err := tr.sqlHandler.Exec(`
+		INSERT INTO thread_join_requests(id, thread_id, user_id, status, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE id=VALUES(id), status=VALUES(status), decided_by=NULL, decided_at=NULL, created_at=VALUES(created_at)
+	`,
+		request.ID,
+		request.Thread.ID,
+		request.User.ID,
+		string(request.Status),
+		request.CreatedAt,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to insert join request")
 	}
 	return nil
 }
+
+const joinRequestColumns = `id, thread_id, user_id, status, COALESCE(decided_by, ''), decided_at, created_at`
+
+func scanJoinRequest(scanner rowScanner) (*entity.JoinRequest, error) {
+	var request entity.JoinRequest
+	var thread entity.Thread
+	var user entity.User
+	var decidedBy entity.User
+	if err := scanner.Scan(&request.ID, &thread.ID, &user.ID, &request.Status, &decidedBy.ID, &request.DecidedAt, &request.CreatedAt); err != nil {
+		return nil, err
+	}
+	request.Thread = &thread
+	request.User = &user
+	if decidedBy.ID != "" {
+		request.DecidedBy = &decidedBy
+	}
+	return &request, nil
+}
+
+func (tr *threadRepository) FindJoinRequestByID(id string) (*entity.JoinRequest, error) {
+	row := tr.sqlHandler.QueryRow(`
+		SELECT `+joinRequestColumns+`
+		FROM thread_join_requests
+		WHERE id=?
+	`, id)
+	request, err := scanJoinRequest(row)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+	return request, nil
+}
+
+// FindJoinRequest 申請していなければnilを返す
+func (tr *threadRepository) FindJoinRequest(threadID, userID string) (*entity.JoinRequest, error) {
+	row := tr.sqlHandler.QueryRow(`
+		SELECT `+joinRequestColumns+`
+		FROM thread_join_requests
+		WHERE thread_id=? AND user_id=?
+	`, threadID, userID)
+	request, err := scanJoinRequest(row)
+	if err != nil {
+		if row.CheckNoRows(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+	return request, nil
+}
+
+// FindJoinRequestsByThreadID statusが空ならすべて返す
+func (tr *threadRepository) FindJoinRequestsByThreadID(threadID string, status entity.JoinRequestStatus) ([]*entity.JoinRequest, error) {
+	rows, err := tr.sqlHandler.Query(`
+		SELECT `+joinRequestColumns+`
+		FROM thread_join_requests
+		WHERE thread_id=? AND (?='' OR status=?)
+		ORDER BY created_at
+	`, threadID, string(status), string(status))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var requests []*entity.JoinRequest
+	for rows.Next() {
+		request, err := scanJoinRequest(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+func (tr *threadRepository) FindJoinRequestsByUserID(userID string) ([]*entity.JoinRequest, error) {
+	rows, err := tr.sqlHandler.Query(`
+		SELECT `+joinRequestColumns+`
+		FROM thread_join_requests
+		WHERE user_id=?
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var requests []*entity.JoinRequest
+	for rows.Next() {
+		request, err := scanJoinRequest(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+// DecideJoinRequest pendingの申請だけを承認か却下する。既に決まっていればfalse。
+// 承認したときはentryでメンバーに追加し、満員ならrequest.Waitlistにentryを入れて参加待ちにする
+func (tr *threadRepository) DecideJoinRequest(request *entity.JoinRequest, entry *entity.WaitlistEntry) (bool, error) {
+	decided := false
+	err := tr.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		res, err := tx.Exec(`
+			UPDATE thread_join_requests
+			SET status=?, decided_by=?, decided_at=?
+			WHERE id=? AND status=?
+		`, string(request.Status), request.DecidedBy.ID, request.DecidedAt, request.ID, string(entity.JoinRequestPending))
+		if err != nil {
+			return errors.Wrap(err, "failed to update join request")
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return errors.Wrap(err, "failed to get affected rows")
+		}
+		if affected == 0 {
+			return nil
+		}
+		decided = true
+		if request.Status != entity.JoinRequestApproved {
+			return nil
+		}
+		added, err := addMemberOrWait(tx, entry)
+		if err != nil {
+			return err
+		}
+		if !added {
+			request.Waitlist = entry
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return decided, nil
+}
+
+// Archive 既にアーカイブ中ならfalse
+func (tr *threadRepository) Archive(threadID string, archivedAt time.Time) (bool, error) {
+	res, err := tr.sqlHandler.Exec(`
+		UPDATE threads
+		SET archived_at=?
+		WHERE id=? AND archived_at IS NULL
+	`, archivedAt, threadID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to archive thread")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get affected rows")
+	}
+	return affected > 0, nil
+}
+
+// Restore アーカイブしていなければfalse
+func (tr *threadRepository) Restore(threadID string) (bool, error) {
+	res, err := tr.sqlHandler.Exec(`
+		UPDATE threads
+		SET archived_at=NULL
+		WHERE id=? AND archived_at IS NOT NULL
+	`, threadID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to restore thread")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get affected rows")
+	}
+	return affected > 0, nil
+}
+
+// FindWaitlistByThreadID 参加待ちの順に並べる
+func (tr *threadRepository) FindWaitlistByThreadID(threadID string) ([]*entity.WaitlistEntry, error) {
+	rows, err := tr.sqlHandler.Query(`
+		SELECT id, thread_id, user_id, created_at
+		FROM thread_waitlists
+		WHERE thread_id=?
+		ORDER BY created_at, id
+	`, threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var entries []*entity.WaitlistEntry
+	for rows.Next() {
+		entry, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		entry.Position = len(entries) + 1
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func scanWaitlistEntry(scanner rowScanner) (*entity.WaitlistEntry, error) {
+	var entry entity.WaitlistEntry
+	var thread entity.Thread
+	var user entity.User
+	if err := scanner.Scan(&entry.ID, &thread.ID, &user.ID, &entry.CreatedAt); err != nil {
+		return nil, err
+	}
+	entry.Thread = &thread
+	entry.User = &user
+	return &entry, nil
+}
+
+// RemoveFromWaitlist 参加待ちでなければfalse
+func (tr *threadRepository) RemoveFromWaitlist(threadID, userID string) (bool, error) {
+	res, err := tr.sqlHandler.Exec(`
+		DELETE FROM thread_waitlists
+		WHERE user_id=? and thread_id=?
+	`, userID, threadID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete waitlist")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get affected rows")
+	}
+	return affected > 0, nil
+}
+
+// PromoteFromWaitlist 空きがあれば一番前の参加待ちをmemberIDでメンバーにする。
+// 空きがないか誰も待っていないか、アーカイブ中ならnilを返す
+func (tr *threadRepository) PromoteFromWaitlist(threadID, memberID string) (*entity.WaitlistEntry, error) {
+	var promoted *entity.WaitlistEntry
+	err := tr.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		vacant, err := lockVacancy(tx, threadID)
+		if err == errThreadArchived {
+			return nil
+		}
+		if err != nil || !vacant {
+			return err
+		}
+		row := tx.QueryRow(`
+			SELECT id, thread_id, user_id, created_at
+			FROM thread_waitlists
+			WHERE thread_id=?
+			ORDER BY created_at, id
+			LIMIT 1
+		`, threadID)
+		entry, err := scanWaitlistEntry(row)
+		if err != nil {
+			if row.CheckNoRows(err) {
+				return nil
+			}
+			return errors.Wrap(err, "failed to scan")
+		}
+		res, err := tx.Exec(`
+			DELETE FROM thread_waitlists
+			WHERE id=?
+		`, entry.ID)
+		if err != nil {
+			return errors.Wrap(err, "failed to delete waitlist")
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return errors.Wrap(err, "failed to get affected rows")
+		}
+		// 参加待ちをやめたところなら誰も上げない
+		if affected == 0 {
+			return nil
+		}
+		if err = insertMember(tx, memberID, threadID, entry.User.ID, entity.RoleMember); err != nil {
+			return err
+		}
+		promoted = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return promoted, nil
+}
+
+// DeleteIfEmpty メンバーがおらず、メッセージも書き出しもなければ関連する行ごと削除する。
+// 残すべき履歴があればfalseを返して削除しない
+func (tr *threadRepository) DeleteIfEmpty(id string) (bool, error) {
+	deleted := false
+	err := tr.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		row := tx.QueryRow(`
+			SELECT
+				(SELECT COUNT(*) FROM users_threads WHERE thread_id=t.id)
+				+ (SELECT COUNT(*) FROM messages WHERE thread_id=t.id)
+				+ (SELECT COUNT(*) FROM archives WHERE thread_id=t.id)
+			FROM threads AS t
+			WHERE t.id=?
+			FOR UPDATE
+		`, id)
+		var count int
+		if err := row.Scan(&count); err != nil {
+			return errors.Wrap(err, "failed to lock thread")
+		}
+		if count > 0 {
+			return nil
+		}
+		for _, table := range []string{"threads_tags", "thread_bans", "thread_invite_uses", "thread_invites", "thread_join_requests", "thread_waitlists"} {
+			if _, err := tx.Exec(`DELETE FROM `+table+` WHERE thread_id=?`, id); err != nil {
+				return errors.Wrap(err, "failed to delete "+table)
+			}
+		}
+		if _, err := tx.Exec(`DELETE FROM threads WHERE id=?`, id); err != nil {
+			return errors.Wrap(err, "failed to delete")
+		}
+		deleted = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return deleted, nil
+}
+
+// Delete スレッドを参照している行もまとめて消す。途中で失敗したら何も消さない
+func (tr *threadRepository) Delete(id string) error {
+	return tr.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		for _, table := range []string{"users_threads", "threads_tags", "thread_bans", "thread_invite_uses", "thread_invites", "thread_join_requests", "thread_waitlists", "pinned_messages", "read_markers", "archives", "message_deletions"} {
+			if _, err := tx.Exec(`DELETE FROM `+table+` WHERE thread_id=?`, id); err != nil {
+				return errors.Wrap(err, "failed to delete "+table)
+			}
+		}
+		for _, table := range []string{"message_mentions", "message_reactions", "message_revisions"} {
+			if _, err := tx.Exec(`DELETE FROM `+table+` WHERE message_id IN (SELECT id FROM messages WHERE thread_id=?)`, id); err != nil {
+				return errors.Wrap(err, "failed to delete "+table)
+			}
+		}
+		// 返信は同じthreadのメッセージを参照しているので、先に外してから消す
+		if _, err := tx.Exec(`UPDATE messages SET parent_id=NULL WHERE thread_id=? AND parent_id IS NOT NULL`, id); err != nil {
+			return errors.Wrap(err, "failed to detach replies")
+		}
+		if _, err := tx.Exec(`DELETE FROM messages WHERE thread_id=?`, id); err != nil {
+			return errors.Wrap(err, "failed to delete messages")
+		}
+		if _, err := tx.Exec(`DELETE FROM threads WHERE id=?`, id); err != nil {
+			return errors.Wrap(err, "failed to delete")
+		}
+		return nil
+	})
+}
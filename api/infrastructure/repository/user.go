@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"app/api/constants"
 	"app/api/domain/entity"
 	"app/api/domain/repository"
 	"app/api/infrastructure/database"
@@ -136,7 +137,8 @@ func (repo *userRepository) FindAll() ([]*entity.User, error) {
 	rows, err := repo.sqlHandler.Query(`
 		SELECT id, user_id, name, image, profile, is_admin, mail, login_at, created_at, updated_at, password
 		FROM users
-	`)
+		WHERE id<>?
+	`, constants.DeletedUserID)
 	var users []*entity.User
 	for rows.Next() {
 		var user entity.User
@@ -151,16 +153,184 @@ func (repo *userRepository) FindAll() ([]*entity.User, error) {
 	return users, nil
 }
 
-func (repo *userRepository) DeleteByID(id string) error {
+// ScheduleDeletion atを過ぎたらPurgeの対象になる
+func (repo *userRepository) ScheduleDeletion(id string, at time.Time) error {
 	_, err := repo.sqlHandler.Exec(`
-		DELETE FROM users WHERE id=?
-	`, id)
+		UPDATE users
+		SET deletion_scheduled_at=?
+		WHERE id=?
+	`, at, id)
 	if err != nil {
-		return errors.Wrap(err, "failed to delete from db")
+		return errors.Wrap(err, "failed to schedule deletion")
 	}
 	return nil
 }
 
+// CancelDeletion 削除待ちでなければfalse
+func (repo *userRepository) CancelDeletion(id string) (bool, error) {
+	res, err := repo.sqlHandler.Exec(`
+		UPDATE users
+		SET deletion_scheduled_at=NULL
+		WHERE id=? AND deletion_scheduled_at IS NOT NULL
+	`, id)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to cancel deletion")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get affected rows")
+	}
+	return affected > 0, nil
+}
+
+func (repo *userRepository) FindIDsScheduledForDeletion(before time.Time) ([]string, error) {
+	rows, err := repo.sqlHandler.Query(`
+		SELECT id
+		FROM users
+		WHERE deletion_scheduled_at <= ?
+	`, before)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Purge ユーザを1つのトランザクションで削除する。
+// ownerのスレッドは後任に譲り、誰も残っていなければアーカイブする。
+// メッセージなど他のユーザにも見える記録は削除されたユーザに付け替え、本人にしか関係のない行は消す
+func (repo *userRepository) Purge(id string, at time.Time) ([]*entity.Succession, error) {
+	var successions []*entity.Succession
+	err := repo.sqlHandler.Transaction(func(tx database.SQLHandler) error {
+		threadIDs, err := findOwnedThreadIDs(tx, id)
+		if err != nil {
+			return err
+		}
+		for _, threadID := range threadIDs {
+			succession, err := handOverThread(tx, threadID, id, at)
+			if err != nil {
+				return err
+			}
+			successions = append(successions, succession)
+		}
+
+		for _, q := range []string{
+			`DELETE FROM users_threads WHERE user_id=?`,
+			`DELETE FROM thread_waitlists WHERE user_id=?`,
+			`DELETE FROM thread_join_requests WHERE user_id=?`,
+			`DELETE FROM thread_bans WHERE user_id=?`,
+			`DELETE FROM message_reactions WHERE user_id=?`,
+			`DELETE FROM message_mentions WHERE user_id=?`,
+			`DELETE FROM read_markers WHERE user_id=?`,
+			`DELETE FROM users_tags WHERE user_id=?`,
+			`DELETE FROM users_followers WHERE user_id=?`,
+			`DELETE FROM users_followers WHERE followed_user_id=?`,
+			`DELETE FROM evaluation_scores WHERE user_id=?`,
+		} {
+			if _, err = tx.Exec(q, id); err != nil {
+				return errors.Wrap(err, "failed to delete")
+			}
+		}
+
+		// 自分宛ての招待は使えないようにして残す
+		_, err = tx.Exec(`
+			UPDATE thread_invites
+			SET invitee_id=?, revoked_at=COALESCE(revoked_at, ?)
+			WHERE invitee_id=?
+		`, constants.DeletedUserID, at, id)
+		if err != nil {
+			return errors.Wrap(err, "failed to revoke invites")
+		}
+		for _, q := range []string{
+			`UPDATE messages SET user_id=? WHERE user_id=?`,
+			`UPDATE message_deletions SET author_id=? WHERE author_id=?`,
+			`UPDATE message_deletions SET deleted_by=? WHERE deleted_by=?`,
+			`UPDATE pinned_messages SET user_id=? WHERE user_id=?`,
+			`UPDATE thread_bans SET banned_by=? WHERE banned_by=?`,
+			`UPDATE thread_invites SET created_by=? WHERE created_by=?`,
+			`UPDATE thread_invite_uses SET user_id=? WHERE user_id=?`,
+			`UPDATE thread_join_requests SET decided_by=? WHERE decided_by=?`,
+			`UPDATE archives SET created_by=? WHERE created_by=?`,
+		} {
+			if _, err = tx.Exec(q, constants.DeletedUserID, id); err != nil {
+				return errors.Wrap(err, "failed to anonymize")
+			}
+		}
+
+		if _, err = tx.Exec(`DELETE FROM users WHERE id=?`, id); err != nil {
+			return errors.Wrap(err, "failed to delete from db")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return successions, nil
+}
+
+// findOwnedThreadIDs ownerのスレッドと、作成者のまま抜けていたスレッド
+func findOwnedThreadIDs(tx database.SQLHandler, userID string) ([]string, error) {
+	rows, err := tx.Query(`
+		SELECT thread_id
+		FROM users_threads
+		WHERE user_id=? AND role=?
+		UNION
+		SELECT id
+		FROM threads
+		WHERE user_id=?
+	`, userID, string(entity.RoleOwner), userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select")
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// handOverThread 後任がいなければ削除されたユーザを作成者にしてアーカイブする
+func handOverThread(tx database.SQLHandler, threadID, userID string, at time.Time) (*entity.Succession, error) {
+	_, err := tx.Exec(`
+		DELETE FROM users_threads
+		WHERE user_id=? and thread_id=?
+	`, userID, threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to delete relation")
+	}
+	succession := &entity.Succession{Thread: &entity.Thread{ID: threadID}}
+	successorID, err := promoteSuccessor(tx, threadID)
+	if err != nil {
+		return nil, err
+	}
+	if successorID != "" {
+		succession.NewOwner = &entity.User{ID: successorID}
+		return succession, nil
+	}
+	_, err = tx.Exec(`
+		UPDATE threads
+		SET user_id=?, archived_at=COALESCE(archived_at, ?)
+		WHERE id=?
+	`, constants.DeletedUserID, at, threadID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to archive thread")
+	}
+	return succession, nil
+}
+
 func (repo *userRepository) FindFollows(id string) ([]*entity.User, error) {
 	rows, err := repo.sqlHandler.Query(`
 		SELECT u.id, u.user_id, u.name, u.mail, u.image, u.profile, u.created_at, u.updated_at, u.login_at
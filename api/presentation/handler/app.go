@@ -4,7 +4,13 @@ import (
 	"app/api/application/interactor"
 	"app/api/domain/service"
 	"app/api/infrastructure/database"
+	"app/api/infrastructure/lsocket"
+	"app/api/infrastructure/nosql"
 	"app/api/infrastructure/repository"
+	"app/api/llog"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 type AppHandler struct {
@@ -16,6 +22,12 @@ type AppHandler struct {
 	MessageHandler  MessageHandler
 	SocketHandler   SocketHandler
 	FileHandler     FileHandler
+	SearchHandler   SearchHandler
+	InviteHandler   InviteHandler
+	ExportHandler   ExportHandler
+
+	hub            *lsocket.Hub
+	userInteractor interactor.UserInteractor
 }
 
 func NewAppHandler(sqlHandler database.SQLHandler) *AppHandler {
@@ -26,6 +38,9 @@ func NewAppHandler(sqlHandler database.SQLHandler) *AppHandler {
 	threadRepository := repository.NewThreadRepository(sqlHandler)
 	messageRepository := repository.NewMessageRepository(sqlHandler)
 	fileRepository := repository.NewFileRepository()
+	messageSearchIndex := repository.NewMessageSearchIndex(sqlHandler)
+	inviteRepository := repository.NewInviteRepository(sqlHandler)
+	exportRepository := repository.NewExportRepository(sqlHandler)
 
 	// service
 	userService := service.NewUserService(userRepository, fileRepository)
@@ -33,8 +48,22 @@ func NewAppHandler(sqlHandler database.SQLHandler) *AppHandler {
 	categoryService := service.NewCategoryService(categoryRepository)
 	tagService := service.NewTagService(tagRepository)
 	threadService := service.NewThreadService(threadRepository, fileRepository)
-	messageService := service.NewMessageService(messageRepository)
+	messageService := service.NewMessageService(messageRepository, userRepository, threadRepository, messageSearchIndex)
 	fileService := service.NewFileService(fileRepository)
+	inviteService := service.NewInviteService(inviteRepository)
+	exportService := service.NewExportService(exportRepository, fileRepository, userRepository)
+
+	// プロセス内の索引は起動時に作り直す
+	if !messageSearchIndex.Persistent() {
+		go func() {
+			if err := messageService.BuildSearchIndex(); err != nil {
+				llog.Warn(errors.Wrap(err, "failed to build search index"))
+			}
+		}()
+	}
+
+	// websocket hub
+	hub := lsocket.NewHub(nosql.NewRedisBroker(), nosql.NewRedisPresenceStore(), lsocket.NewConfig())
 
 	// interactor
 	userInteractor := interactor.NewUserInteractor(userService, authService, tagService, categoryService)
@@ -44,15 +73,48 @@ func NewAppHandler(sqlHandler database.SQLHandler) *AppHandler {
 	threadInteractor := interactor.NewThreadInteractor(threadService, userService, tagService, categoryService)
 	messageInteractor := interactor.NewMessageInteractor(messageService, threadService, userService)
 	fileInteractor := interactor.NewFileInteractor(fileService)
+	inviteInteractor := interactor.NewInviteInteractor(inviteService, threadService, userService)
+	exportInteractor := interactor.NewExportInteractor(exportService, threadService, messageService, userService, authService)
 
 	return &AppHandler{
 		AuthHandler:     NewAuthHandler(authInteractor),
-		UserHandler:     NewUserHandler(userInteractor),
+		UserHandler:     NewUserHandler(hub, userInteractor),
 		CategoryHandler: NewCategoryHandler(categoryInteractor),
-		TagHandler:      NewTagHandler(tagInteractor, categoryInteractor),
-		ThreadHandler:   NewThreadHandler(threadInteractor),
-		MessageHandler:  NewMessageHandler(messageInteractor, threadInteractor),
-		SocketHandler:   NewSocketHandler(messageInteractor, userInteractor, threadInteractor),
-		FileHandler:     NewFileHandler(fileInteractor, userInteractor, threadInteractor, messageInteractor),
+		TagHandler:      NewTagHandler(tagInteractor, categoryInteractor, threadInteractor),
+		ThreadHandler:   NewThreadHandler(hub, threadInteractor, userInteractor, messageInteractor),
+		MessageHandler:  NewMessageHandler(hub, messageInteractor, threadInteractor),
+		SocketHandler:   NewSocketHandler(hub, messageInteractor, userInteractor, threadInteractor),
+		FileHandler:     NewFileHandler(hub, fileInteractor, userInteractor, threadInteractor, messageInteractor),
+		SearchHandler:   NewSearchHandler(messageInteractor, threadInteractor),
+		InviteHandler:   NewInviteHandler(hub, inviteInteractor, threadInteractor, userInteractor),
+		ExportHandler:   NewExportHandler(hub, exportInteractor, threadInteractor),
+		hub:             hub,
+		userInteractor:  userInteractor,
+	}
+}
+
+// RunHub websocket hubのイベントループ。goroutineで1つだけ起動する
+func (ah *AppHandler) RunHub() {
+	ah.hub.Run()
+}
+
+// RunAccountPurge 猶予期間を過ぎたアカウントをintervalごとに削除する。stopが閉じられるまでブロックする
+func (ah *AppHandler) RunAccountPurge(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			deletions, err := ah.userInteractor.PurgeScheduled()
+			if err != nil {
+				llog.Warn(errors.Wrap(err, "failed to purge accounts"))
+			}
+			for _, deletion := range deletions {
+				ah.hub.Disconnect(deletion.User.ID)
+				broadcastSuccessions(ah.hub, deletion.Successions)
+			}
+		case <-stop:
+			return
+		}
 	}
 }
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"app/api/application/interactor"
+	"app/api/domain/entity"
+	"app/api/infrastructure/lcontext"
+	"app/api/infrastructure/lsocket"
+	"app/api/llog"
+	"app/api/presentation/request"
+	"app/api/presentation/response"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+type ExportHandler interface {
+	Create(w http.ResponseWriter, r *http.Request)        //Start exporting thread into zip bundle
+	GetByThreadID(w http.ResponseWriter, r *http.Request) //Get exports of thread
+	Download(w http.ResponseWriter, r *http.Request)      //Download zip bundle with password
+}
+
+type exportHandler struct {
+	hub              *lsocket.Hub
+	exportInteractor interactor.ExportInteractor
+	threadInteractor interactor.ThreadInteractor
+}
+
+func NewExportHandler(hub *lsocket.Hub, ei interactor.ExportInteractor, ti interactor.ThreadInteractor) ExportHandler {
+	return &exportHandler{
+		hub:              hub,
+		exportInteractor: ei,
+		threadInteractor: ti,
+	}
+}
+
+// Create 書き出しはバックグラウンドで行い、終わったら書き出したユーザに通知する
+func (eh *exportHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	src, err := ReadRequestBody(r, &request.CreateExportRequest{})
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read request"), "failed to read request")
+		return
+	}
+	req, _ := src.(*request.CreateExportRequest)
+	if err = req.Validation(); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+	if _, err = eh.threadInteractor.GetByID(threadID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get thread"), "thread is not found")
+		return
+	}
+	if err = eh.threadInteractor.CheckPermission(threadID, userID, entity.PermissionExport); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
+
+	export, err := eh.exportInteractor.Create(userID, threadID, req.IsPublic, req.Password)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to create export"), "failed to create export")
+		return
+	}
+	go eh.build(export.ID, export.CreatedBy.ID, req.Password)
+	response.Success(w, response.ConvertToExportResponse(export))
+}
+
+func (eh *exportHandler) build(exportID, userUUID, password string) {
+	socketType := socketTypeExportReady
+	export, err := eh.exportInteractor.Build(exportID, password)
+	if err != nil {
+		llog.Error(errors.Wrap(err, "failed to build export"))
+		socketType = socketTypeExportFailed
+	}
+	if export == nil {
+		return
+	}
+	if err = sendToUser(eh.hub, userUUID, socketType, response.ConvertToExportResponse(export)); err != nil {
+		llog.Warn(errors.Wrap(err, "failed to notify export"))
+	}
+}
+
+func (eh *exportHandler) GetByThreadID(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	if _, err = eh.threadInteractor.GetByID(threadID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get thread"), "thread is not found")
+		return
+	}
+
+	exports, err := eh.exportInteractor.GetByThreadID(userID, threadID)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to get exports"), errors.Cause(err).Error())
+		return
+	}
+	response.Success(w, response.ConvertToExportsResponse(exports))
+}
+
+// Download パスワードをURLに残さないようにbodyで受け取る
+func (eh *exportHandler) Download(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	exportID, err := ReadPathParam(r, "exportID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	// パスワードのない書き出しはbodyを省略できる
+	req := &request.DownloadExportRequest{}
+	if r.ContentLength != 0 {
+		if _, err = ReadRequestBody(r, req); err != nil {
+			response.BadRequest(w, errors.Wrap(err, "failed to read request"), "failed to read request")
+			return
+		}
+	}
+	if err = eh.exportInteractor.CheckDownloadable(userID, threadID, exportID, req.Password); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check downloadable"), errors.Cause(err).Error())
+		return
+	}
+
+	file, err := eh.exportInteractor.Download(exportID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to download export"), "failed to download export")
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+exportID+`.zip"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(file)
+}
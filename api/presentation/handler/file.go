@@ -2,7 +2,10 @@ package handler
 
 import (
 	"app/api/application/interactor"
+	"app/api/constants"
+	"app/api/domain/entity"
 	"app/api/infrastructure/lcontext"
+	"app/api/infrastructure/lsocket"
 	"app/api/llog"
 	"app/api/presentation/response"
 	"io/ioutil"
@@ -21,14 +24,16 @@ type FileHandler interface {
 }
 
 type fileHandler struct {
+	hub               *lsocket.Hub
 	fileInteractor    interactor.FileInteractor
 	userInteractor    interactor.UserInteractor
 	threadInteractor  interactor.ThreadInteractor
 	messageInteractor interactor.MessageInteractor
 }
 
-func NewFileHandler(fi interactor.FileInteractor, ui interactor.UserInteractor, ti interactor.ThreadInteractor, mi interactor.MessageInteractor) FileHandler {
+func NewFileHandler(hub *lsocket.Hub, fi interactor.FileInteractor, ui interactor.UserInteractor, ti interactor.ThreadInteractor, mi interactor.MessageInteractor) FileHandler {
 	return &fileHandler{
+		hub:               hub,
 		fileInteractor:    fi,
 		userInteractor:    ui,
 		threadInteractor:  ti,
@@ -85,8 +90,9 @@ func (fh *fileHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !fh.threadInteractor.IsParticipated(threadID, userID) {
-		response.BadRequest(w, errors.New(userID+" are not participated in room "+threadID), userID+" are not participated in room "+threadID)
+	if err = fh.threadInteractor.CheckPermission(threadID, userID, entity.PermissionUpload); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
 	}
 
 	r.ParseMultipartForm(10 << 20)
@@ -109,11 +115,14 @@ func (fh *fileHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	message, err := fh.messageInteractor.Create(fileName, 10, userID, threadID)
+	message, err := fh.messageInteractor.Create(fileName, constants.FileMessageGrade, userID, threadID, "")
 	if err != nil {
 		response.InternalServerError(w, errors.Wrap(err, "failed to create message"), "failed to create message")
 		return
 	}
+	if err = broadcastMessage(fh.hub, message); err != nil {
+		llog.Warn(errors.Wrap(err, "failed to broadcast message"))
+	}
 
 	response.Success(w, response.ConvertToMessageResponse(message))
 }
@@ -174,19 +183,12 @@ func (fh *fileHandler) SetThreadIcon(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	thread, err := fh.threadInteractor.GetByID(threadID)
-	if err != nil {
+	if _, err = fh.threadInteractor.GetByID(threadID); err != nil {
 		response.InternalServerError(w, errors.Wrap(err, "failed to get thread"), "failed to get thread")
 		return
 	}
-	user, err := fh.userInteractor.GetByUserID(userID)
-	if err != nil {
-		response.InternalServerError(w, errors.Wrap(err, "failed to get user"), "failed to get user")
-		return
-	}
-
-	if thread.Author.ID != user.ID {
-		response.BadRequest(w, errors.New("do not have permissions to change icon"), "do not have permissions to change room icon")
+	if err = fh.threadInteractor.CheckPermission(threadID, userID, entity.PermissionChangeIcon); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
 		return
 	}
 
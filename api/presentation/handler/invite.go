@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"app/api/application/interactor"
+	"app/api/domain/entity"
+	"app/api/infrastructure/lcontext"
+	"app/api/infrastructure/lsocket"
+	"app/api/llog"
+	"app/api/presentation/request"
+	"app/api/presentation/response"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+type InviteHandler interface {
+	Create(w http.ResponseWriter, r *http.Request)        //Create invite code or direct invite
+	GetByThreadID(w http.ResponseWriter, r *http.Request) //Get invites of thread with usage
+	Revoke(w http.ResponseWriter, r *http.Request)        //Revoke invite
+	GetMine(w http.ResponseWriter, r *http.Request)       //Get invites addressed to me
+	GetByCode(w http.ResponseWriter, r *http.Request)     //Get invite by code
+	Join(w http.ResponseWriter, r *http.Request)          //Join thread with invite code
+}
+
+type inviteHandler struct {
+	hub              *lsocket.Hub
+	inviteInteractor interactor.InviteInteractor
+	threadInteractor interactor.ThreadInteractor
+	userInteractor   interactor.UserInteractor
+}
+
+func NewInviteHandler(hub *lsocket.Hub, ii interactor.InviteInteractor, ti interactor.ThreadInteractor, ui interactor.UserInteractor) InviteHandler {
+	return &inviteHandler{
+		hub:              hub,
+		inviteInteractor: ii,
+		threadInteractor: ti,
+		userInteractor:   ui,
+	}
+}
+
+func (ih *inviteHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	src, err := ReadRequestBody(r, &request.CreateInviteRequest{})
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read request"), "failed to read request")
+		return
+	}
+	req, _ := src.(*request.CreateInviteRequest)
+	if err = req.Validation(); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+	if _, err = ih.threadInteractor.GetByID(threadID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get thread"), "thread is not found")
+		return
+	}
+	if err = ih.threadInteractor.CheckPermission(threadID, userID, entity.PermissionInvite); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
+	if req.UserID != "" {
+		if _, err = ih.userInteractor.GetByUserID(req.UserID); err != nil {
+			response.NotFound(w, errors.Wrap(err, "failed to get user"), "user is not found")
+			return
+		}
+	}
+
+	invite, err := ih.inviteInteractor.Create(userID, threadID, req.UserID, req.MaxUses, req.ExpiresAt)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to create invite"), "failed to create invite")
+		return
+	}
+	res := response.ConvertToInviteResponse(invite)
+	if invite.Invitee != nil {
+		if err = sendToUser(ih.hub, invite.Invitee.ID, socketTypeInvited, res); err != nil {
+			llog.Warn(errors.Wrap(err, "failed to notify invite"))
+		}
+	}
+	response.Success(w, res)
+}
+
+func (ih *inviteHandler) GetByThreadID(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	if err = ih.threadInteractor.CheckPermission(threadID, userID, entity.PermissionInvite); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
+
+	invites, err := ih.inviteInteractor.GetByThreadID(threadID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get invites"), "failed to get invites")
+		return
+	}
+	response.Success(w, response.ConvertToInvitesResponse(invites))
+}
+
+func (ih *inviteHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	inviteID, err := ReadPathParam(r, "inviteID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	if err = ih.threadInteractor.CheckPermission(threadID, userID, entity.PermissionInvite); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
+
+	revoked, err := ih.inviteInteractor.Revoke(threadID, inviteID)
+	if err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to revoke invite"), "invite is not found")
+		return
+	}
+	if !revoked {
+		response.BadRequest(w, errors.New("invite is already revoked"), "invite is already revoked")
+		return
+	}
+	response.NoContent(w)
+}
+
+func (ih *inviteHandler) GetMine(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	invites, err := ih.inviteInteractor.GetForUser(userID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get invites"), "failed to get invites")
+		return
+	}
+	response.Success(w, response.ConvertToInvitesResponse(invites))
+}
+
+func (ih *inviteHandler) GetByCode(w http.ResponseWriter, r *http.Request) {
+	code, err := ReadPathParam(r, "code")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	invite, err := ih.inviteInteractor.GetByCode(code)
+	if err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get invite"), "invite is not found")
+		return
+	}
+	response.Success(w, response.ConvertToInviteResponse(invite))
+}
+
+func (ih *inviteHandler) Join(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	code, err := ReadPathParam(r, "code")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	if err = ih.inviteInteractor.CheckJoinable(code, userID); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check invite"), errors.Cause(err).Error())
+		return
+	}
+
+	thread, waiting, err := ih.inviteInteractor.Join(code, userID)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to join thread"), "failed to join thread")
+		return
+	}
+	if waiting != nil {
+		response.Success(w, response.ConvertToWaitlistEntryResponse(waiting))
+		return
+	}
+	user, err := ih.userInteractor.GetByUserID(userID)
+	if err == nil {
+		err = broadcastMembership(ih.hub, socketTypeJoined, thread.ID, user)
+	}
+	if err != nil {
+		llog.Warn(errors.Wrap(err, "failed to notify membership"))
+	}
+	response.Success(w, response.ConvertToThreadResponse(thread))
+}
@@ -2,8 +2,11 @@ package handler
 
 import (
 	"app/api/application/interactor"
+	"app/api/constants"
 	"app/api/domain/entity"
 	"app/api/infrastructure/lcontext"
+	"app/api/infrastructure/lsocket"
+	"app/api/llog"
 	"app/api/presentation/request"
 	"app/api/presentation/response"
 	"net/http"
@@ -12,18 +15,33 @@ import (
 )
 
 type MessageHandler interface {
-	Create(w http.ResponseWriter, r *http.Request)        //Create Massage
-	GetByThreadID(w http.ResponseWriter, r *http.Request) //Get Thread Messages
-	AddFavorite(w http.ResponseWriter, r *http.Request)   //Add favorite(Like) message
+	Create(w http.ResponseWriter, r *http.Request)         //Create Massage
+	GetByThreadID(w http.ResponseWriter, r *http.Request)  //Get Thread Messages
+	Update(w http.ResponseWriter, r *http.Request)         //Edit message
+	GetRevisions(w http.ResponseWriter, r *http.Request)   //Get edit history of message
+	GetReplies(w http.ResponseWriter, r *http.Request)     //Get reply chain of message
+	Delete(w http.ResponseWriter, r *http.Request)         //Delete message by author or thread admin
+	GetDeletions(w http.ResponseWriter, r *http.Request)   //Get deletion audit log of thread
+	AddFavorite(w http.ResponseWriter, r *http.Request)    //Add default reaction to message (compat)
+	AddReaction(w http.ResponseWriter, r *http.Request)    //Add emoji reaction to message
+	RemoveReaction(w http.ResponseWriter, r *http.Request) //Remove own reaction from message
+	MarkAsRead(w http.ResponseWriter, r *http.Request)     //Move read marker of thread
+	GetReadMarkers(w http.ResponseWriter, r *http.Request) //Get read markers of thread members
+	GetMentions(w http.ResponseWriter, r *http.Request)    //Get messages mentioning me
+	Pin(w http.ResponseWriter, r *http.Request)            //Pin message to thread by thread admin
+	Unpin(w http.ResponseWriter, r *http.Request)          //Unpin message by thread admin
+	GetPins(w http.ResponseWriter, r *http.Request)        //Get pinned messages of thread
 }
 
 type messageHandler struct {
+	hub               *lsocket.Hub
 	messageInteractor interactor.MessageInteractor
 	threadInteractor  interactor.ThreadInteractor
 }
 
-func NewMessageHandler(mi interactor.MessageInteractor, ti interactor.ThreadInteractor) MessageHandler {
+func NewMessageHandler(hub *lsocket.Hub, mi interactor.MessageInteractor, ti interactor.ThreadInteractor) MessageHandler {
 	return &messageHandler{
+		hub:               hub,
 		messageInteractor: mi,
 		threadInteractor:  ti,
 	}
@@ -54,12 +72,20 @@ func (mh *messageHandler) Create(w http.ResponseWriter, r *http.Request) {
 		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
 		return
 	}
+	if err = mh.threadInteractor.CheckPermission(threadID, userID, entity.PermissionPost); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
 
-	message, err := mh.messageInteractor.Create(req.Message, req.Grade, userID, threadID)
+	message, err := mh.messageInteractor.Create(req.Message, req.Grade, userID, threadID, req.ParentID)
 	if err != nil {
 		response.InternalServerError(w, errors.Wrap(err, "failed to create message"), "failed to create message")
 		return
 	}
+	if err = broadcastMessage(mh.hub, message); err != nil {
+		llog.Warn(errors.Wrap(err, "failed to broadcast message"))
+	}
+	notifyMentions(mh.hub, message)
 	response.Success(w, response.ConvertToMessageResponse(message))
 }
 
@@ -87,15 +113,229 @@ func (mh *messageHandler) GetByThreadID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	messages, err := mh.messageInteractor.GetByThreadID(threadID)
+	req, err := request.NewGetMessagesRequest(r.URL.Query())
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read query"), err.Error())
+		return
+	}
+	if err = req.Validation(); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+
+	page, err := mh.messageInteractor.GetByThreadID(threadID, userID, req.Before, req.After, req.Limit)
 	if err != nil {
 		response.InternalServerError(w, errors.Wrap(err, "failed to get messages"), "failed to get messages")
 		return
 	}
-	response.Success(w, response.ConvertToMessagesResponse(messages))
+	response.Success(w, response.ConvertToMessagePageResponse(page))
+}
+
+func (mh *messageHandler) Update(w http.ResponseWriter, r *http.Request) {
+	threadID, err := ReadPathParam(r, "threadID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	messageID, err := ReadPathParam(r, "messageID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	src, err := ReadRequestBody(r, &request.UpdateMessageRequest{})
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read request"), "failed to read request")
+		return
+	}
+	req, _ := src.(*request.UpdateMessageRequest)
+	if err = req.Validation(mh.messageInteractor, threadID, messageID, userID); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+
+	if err = mh.threadInteractor.CheckWritable(threadID); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check writable"), err.Error())
+		return
+	}
+
+	message, err := mh.messageInteractor.Edit(threadID, messageID, userID, req.Message)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to edit message"), "failed to edit message")
+		return
+	}
+	if err = broadcastEdited(mh.hub, message); err != nil {
+		llog.Warn(errors.Wrap(err, "failed to broadcast edited message"))
+	}
+	response.Success(w, response.ConvertToMessageResponse(message))
+}
+
+func (mh *messageHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	threadID, err := ReadPathParam(r, "threadID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	messageID, err := ReadPathParam(r, "messageID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	if err = mh.threadInteractor.CheckWritable(threadID); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check writable"), err.Error())
+		return
+	}
+	if err = mh.messageInteractor.CheckDeletable(threadID, messageID, userID); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+
+	message, err := mh.messageInteractor.Delete(threadID, messageID, userID, r.URL.Query().Get("reason"))
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to delete message"), "failed to delete message")
+		return
+	}
+	if err = broadcastDeleted(mh.hub, message); err != nil {
+		llog.Warn(errors.Wrap(err, "failed to broadcast deleted message"))
+	}
+	response.NoContent(w)
+}
+
+func (mh *messageHandler) GetDeletions(w http.ResponseWriter, r *http.Request) {
+	threadID, err := ReadPathParam(r, "threadID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	if !mh.threadInteractor.IsAdmin(threadID, userID) {
+		response.BadRequest(w, errors.New("not admin of thread"), "not admin of thread")
+		return
+	}
+
+	deletions, err := mh.messageInteractor.GetDeletions(threadID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get deletions"), "failed to get deletions")
+		return
+	}
+	response.Success(w, response.ConvertToMessageDeletionsResponse(deletions))
+}
+
+func (mh *messageHandler) GetReplies(w http.ResponseWriter, r *http.Request) {
+	threadID, err := ReadPathParam(r, "threadID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	messageID, err := ReadPathParam(r, "messageID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	members, err := mh.threadInteractor.GetMembersByThreadID(threadID)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to members of thread"), "failed to members of thread")
+		return
+	}
+	if !checkMember(userID, members) {
+		response.BadRequest(w, errors.New("not memeber of thread"), "not member of thread")
+		return
+	}
+
+	chain, err := mh.messageInteractor.GetReplyChain(threadID, messageID, userID)
+	if err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get replies"), "failed to get replies")
+		return
+	}
+	response.Success(w, response.ConvertToReplyChainResponse(chain))
 }
 
+func (mh *messageHandler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	threadID, err := ReadPathParam(r, "threadID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	messageID, err := ReadPathParam(r, "messageID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	members, err := mh.threadInteractor.GetMembersByThreadID(threadID)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to members of thread"), "failed to members of thread")
+		return
+	}
+	if !checkMember(userID, members) {
+		response.BadRequest(w, errors.New("not memeber of thread"), "not member of thread")
+		return
+	}
+
+	revisions, err := mh.messageInteractor.GetRevisions(threadID, messageID)
+	if err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get revisions"), "failed to get revisions")
+		return
+	}
+	response.Success(w, response.ConvertToMessageRevisionsResponse(revisions))
+}
+
+// AddFavorite 互換のため残している。デフォルトのリアクションを付ける
 func (mh *messageHandler) AddFavorite(w http.ResponseWriter, r *http.Request) {
+	mh.addReaction(w, r, constants.DefaultReaction)
+}
+
+func (mh *messageHandler) AddReaction(w http.ResponseWriter, r *http.Request) {
+	src, err := ReadRequestBody(r, &request.AddReactionRequest{})
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read request"), "failed to read request")
+		return
+	}
+	req, _ := src.(*request.AddReactionRequest)
+	if err = req.Validation(); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+	mh.addReaction(w, r, req.Reaction)
+}
+
+func (mh *messageHandler) addReaction(w http.ResponseWriter, r *http.Request, reaction string) {
 	threadID, err := ReadPathParam(r, "threadID")
 	if err != nil {
 		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
@@ -114,24 +354,169 @@ func (mh *messageHandler) AddFavorite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = mh.threadInteractor.GetByID(threadID)
+	members, err := mh.threadInteractor.GetMembersByThreadID(threadID)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to members of thread"), "failed to members of thread")
+		return
+	}
+	if !checkMember(userID, members) {
+		response.BadRequest(w, errors.New("not memeber of thread"), "not member of thread")
+		return
+	}
+
+	if err = mh.threadInteractor.CheckWritable(threadID); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check writable"), err.Error())
+		return
+	}
+
+	message, added, err := mh.messageInteractor.AddReaction(threadID, messageID, userID, reaction)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to add reaction"), "failed to add reaction")
+		return
+	}
+	if added {
+		if err = broadcastReaction(mh.hub, message, userID, reaction, true); err != nil {
+			llog.Warn(errors.Wrap(err, "failed to notify reaction"))
+		}
+	}
+	response.Success(w, response.ConvertToReactionsResponse(message.Reactions))
+}
+
+func (mh *messageHandler) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	threadID, err := ReadPathParam(r, "threadID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	messageID, err := ReadPathParam(r, "messageID")
 	if err != nil {
-		response.NotFound(w, errors.Wrap(err, "failed to find thread"), "failed to find thread")
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
 		return
 	}
-	_, err = mh.messageInteractor.GetByID(messageID)
+
+	reaction, err := ReadPathParam(r, "reaction")
 	if err != nil {
-		response.NotFound(w, errors.Wrap(err, "failed to find message"), "failed to find message")
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	if err = request.ValidateReaction(reaction); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	members, err := mh.threadInteractor.GetMembersByThreadID(threadID)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to members of thread"), "failed to members of thread")
+		return
+	}
+	if !checkMember(userID, members) {
+		response.BadRequest(w, errors.New("not memeber of thread"), "not member of thread")
 		return
 	}
 
-	if err = mh.messageInteractor.AddFavorite(messageID, userID); err != nil {
-		response.InternalServerError(w, errors.Wrap(err, "failed to add favorite"), "failed to add favorite")
+	if err = mh.threadInteractor.CheckWritable(threadID); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check writable"), err.Error())
 		return
 	}
+
+	message, removed, err := mh.messageInteractor.RemoveReaction(threadID, messageID, userID, reaction)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to remove reaction"), "failed to remove reaction")
+		return
+	}
+	if removed {
+		if err = broadcastReaction(mh.hub, message, userID, reaction, false); err != nil {
+			llog.Warn(errors.Wrap(err, "failed to notify reaction"))
+		}
+	}
 	response.NoContent(w)
 }
 
+func (mh *messageHandler) MarkAsRead(w http.ResponseWriter, r *http.Request) {
+	threadID, err := ReadPathParam(r, "threadID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	src, err := ReadRequestBody(r, &request.ReadMessageRequest{})
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read request"), "failed to read request")
+		return
+	}
+	req, _ := src.(*request.ReadMessageRequest)
+	if err = req.Validation(); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+
+	members, err := mh.threadInteractor.GetMembersByThreadID(threadID)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to members of thread"), "failed to members of thread")
+		return
+	}
+	if !checkMember(userID, members) {
+		response.BadRequest(w, errors.New("not memeber of thread"), "not member of thread")
+		return
+	}
+
+	marker, updated, err := mh.messageInteractor.MarkAsRead(threadID, req.MessageID, userID)
+	if err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to mark as read"), "failed to mark as read")
+		return
+	}
+	if updated {
+		if err = broadcastRead(mh.hub, marker); err != nil {
+			llog.Warn(errors.Wrap(err, "failed to broadcast read marker"))
+		}
+	}
+	response.Success(w, response.ConvertToReadMarkerResponse(marker))
+}
+
+func (mh *messageHandler) GetReadMarkers(w http.ResponseWriter, r *http.Request) {
+	threadID, err := ReadPathParam(r, "threadID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	members, err := mh.threadInteractor.GetMembersByThreadID(threadID)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to members of thread"), "failed to members of thread")
+		return
+	}
+	if !checkMember(userID, members) {
+		response.BadRequest(w, errors.New("not memeber of thread"), "not member of thread")
+		return
+	}
+
+	markers, err := mh.messageInteractor.GetReadMarkers(threadID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get read markers"), "failed to get read markers")
+		return
+	}
+	response.Success(w, response.ConvertToReadMarkersResponse(markers))
+}
+
 func checkMember(userID string, members []*entity.User) bool {
 	for _, member := range members {
 		if member.UserID == userID {
@@ -140,3 +525,133 @@ func checkMember(userID string, members []*entity.User) bool {
 	}
 	return false
 }
+
+func (mh *messageHandler) GetMentions(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	req, err := request.NewGetMentionsRequest(r.URL.Query())
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read query"), err.Error())
+		return
+	}
+	if err = req.Validation(); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+
+	messages, next, err := mh.messageInteractor.GetMentions(userID, req.Before, req.Limit)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get mentions"), "failed to get mentions")
+		return
+	}
+	response.Success(w, response.ConvertToMentionsResponse(messages, next))
+}
+
+func (mh *messageHandler) Pin(w http.ResponseWriter, r *http.Request) {
+	threadID, err := ReadPathParam(r, "threadID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	messageID, err := ReadPathParam(r, "messageID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	if err = mh.threadInteractor.CheckPermission(threadID, userID, entity.PermissionPin); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
+
+	_, added, err := mh.messageInteractor.Pin(threadID, messageID, userID)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to pin message"), err.Error())
+		return
+	}
+	if added {
+		if err = broadcastPin(mh.hub, threadID, messageID, userID, true); err != nil {
+			llog.Warn(errors.Wrap(err, "failed to broadcast pin"))
+		}
+	}
+	response.NoContent(w)
+}
+
+func (mh *messageHandler) Unpin(w http.ResponseWriter, r *http.Request) {
+	threadID, err := ReadPathParam(r, "threadID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	messageID, err := ReadPathParam(r, "messageID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	if err = mh.threadInteractor.CheckPermission(threadID, userID, entity.PermissionPin); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
+
+	removed, err := mh.messageInteractor.Unpin(threadID, messageID, userID)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to unpin message"), "failed to unpin message")
+		return
+	}
+	if removed {
+		if err = broadcastPin(mh.hub, threadID, messageID, userID, false); err != nil {
+			llog.Warn(errors.Wrap(err, "failed to broadcast unpin"))
+		}
+	}
+	response.NoContent(w)
+}
+
+func (mh *messageHandler) GetPins(w http.ResponseWriter, r *http.Request) {
+	threadID, err := ReadPathParam(r, "threadID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	members, err := mh.threadInteractor.GetMembersByThreadID(threadID)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to members of thread"), "failed to members of thread")
+		return
+	}
+	if !checkMember(userID, members) {
+		response.BadRequest(w, errors.New("not memeber of thread"), "not member of thread")
+		return
+	}
+
+	pins, err := mh.messageInteractor.GetPins(threadID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get pinned messages"), "failed to get pinned messages")
+		return
+	}
+	response.Success(w, response.ConvertToPinsResponse(pins))
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"app/api/application/interactor"
+	"app/api/infrastructure/lcontext"
+	"app/api/presentation/request"
+	"app/api/presentation/response"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+type SearchHandler interface {
+	SearchMessages(w http.ResponseWriter, r *http.Request) //Search messages in my threads
+	SearchThreads(w http.ResponseWriter, r *http.Request)  //Search public threads and my private threads
+}
+
+type searchHandler struct {
+	messageInteractor interactor.MessageInteractor
+	threadInteractor  interactor.ThreadInteractor
+}
+
+func NewSearchHandler(mi interactor.MessageInteractor, ti interactor.ThreadInteractor) SearchHandler {
+	return &searchHandler{
+		messageInteractor: mi,
+		threadInteractor:  ti,
+	}
+}
+
+func (sh *searchHandler) SearchMessages(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	req, err := request.NewSearchMessagesRequest(r.URL.Query())
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read query"), err.Error())
+		return
+	}
+	if err = req.Validation(); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+
+	result, err := sh.messageInteractor.Search(userID, req.ToQuery())
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to search messages"), "failed to search messages")
+		return
+	}
+	response.Success(w, response.ConvertToMessageSearchResponse(result))
+}
+
+func (sh *searchHandler) SearchThreads(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	req, err := request.NewSearchThreadsRequest(r.URL.Query())
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read query"), err.Error())
+		return
+	}
+	if err = req.Validation(); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+
+	result, err := sh.threadInteractor.Search(userID, req.ToQuery())
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to search threads"), "failed to search threads")
+		return
+	}
+	response.Success(w, response.ConvertToThreadSearchResponse(result))
+}
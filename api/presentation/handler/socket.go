@@ -2,12 +2,15 @@ package handler
 
 import (
 	"app/api/application/interactor"
+	"app/api/constants"
+	"app/api/domain/entity"
 	"app/api/infrastructure/lcontext"
+	"app/api/infrastructure/lsocket"
 	"app/api/llog"
 	"app/api/presentation/response"
 	"encoding/json"
-	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -19,203 +22,593 @@ type SocketHandler interface {
 }
 
 type socketHandler struct {
+	hub               *lsocket.Hub
+	typing            *lsocket.Typing
 	messageInteractor interactor.MessageInteractor
 	userInteractor    interactor.UserInteractor
 	threadInteractor  interactor.ThreadInteractor
 }
 
-func NewSocketHandler(mi interactor.MessageInteractor, ui interactor.UserInteractor, ti interactor.ThreadInteractor) SocketHandler {
-	return &socketHandler{
+func NewSocketHandler(hub *lsocket.Hub, mi interactor.MessageInteractor, ui interactor.UserInteractor, ti interactor.ThreadInteractor) SocketHandler {
+	sh := &socketHandler{
+		hub:               hub,
 		messageInteractor: mi,
 		userInteractor:    ui,
 		threadInteractor:  ti,
 	}
+	sh.typing = lsocket.NewTyping(hub.Config().TypingTimeout, sh.broadcastTypingStop)
+	go sh.watchPresence()
+	return sh
 }
 
+// socket data types
+const (
+	socketTypeSetup         = "setup"
+	socketTypeSubscribe     = "subscribe"
+	socketTypeUnsubscribe   = "unsubscribe"
+	socketTypeMessage       = "Message"
+	socketTypeNotice        = "notice"
+	socketTypeNewMessage    = "message"
+	socketTypeEdited        = "message_edited"
+	socketTypeDeleted       = "message_deleted"
+	socketTypeJoined        = "member_joined"
+	socketTypeLeft          = "member_left"
+	socketTypeRemoved       = "member_removed"
+	socketTypeRole          = "role_changed"
+	socketTypeInvited       = "invited"
+	socketTypeJoinRequested = "join_requested"
+	socketTypeJoinDecided   = "join_request_decided"
+	socketTypePromoted      = "waitlist_promoted"
+	socketTypeArchived      = "thread_archived"
+	socketTypeRestored      = "thread_restored"
+	socketTypeExportReady   = "export_ready"
+	socketTypeExportFailed  = "export_failed"
+	socketTypeTypingStart   = "typing_start"
+	socketTypeTypingStop    = "typing_stop"
+	socketTypePresenceIn    = "presence_join"
+	socketTypePresenceOut   = "presence_leave"
+	socketTypeRead          = "read"
+	socketTypeReaction      = "reaction"
+	socketTypeMention       = "mention"
+	socketTypePinned        = "pinned"
+	socketTypeUnpinned      = "unpinned"
+)
+
 type SocketData struct {
 	Type string `json:"type"`
 	Data string `json:"data"`
 }
 
 type SocketMessageResponse struct {
+	ID        string     `json:"id"`
 	AuthorID  string     `json:"author"`
 	ThreadID  string     `json:"thread"`
 	Grade     int        `json:"grade"`
 	Message   string     `json:"message"`
+	ParentID  string     `json:"parent_id,omitempty"`
 	CreatedAt *time.Time `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type SocketTypingResponse struct {
+	ThreadID string `json:"thread"`
+	UserID   string `json:"user"`
+}
+
+type SocketPresenceResponse struct {
+	ThreadID string `json:"thread"`
+	UserID   string `json:"user"`
+	Status   string `json:"status"`
+}
+
+type SocketReadResponse struct {
+	ThreadID  string     `json:"thread"`
+	UserID    string     `json:"user"`
+	MessageID string     `json:"message_id"`
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+// SocketReactionResponse countは操作後のそのリアクションの数
+type SocketReactionResponse struct {
+	ThreadID  string `json:"thread"`
+	MessageID string `json:"message_id"`
+	UserID    string `json:"user"`
+	Reaction  string `json:"reaction"`
+	Added     bool   `json:"added"`
+	Count     int    `json:"count"`
+}
+
+// SocketMentionResponse メンションされたユーザにroomの購読に関係なく送る
+type SocketMentionResponse struct {
+	ThreadID  string `json:"thread"`
+	MessageID string `json:"message_id"`
+	AuthorID  string `json:"author"`
+	Message   string `json:"message"`
+}
+
+type SocketPinResponse struct {
+	ThreadID  string `json:"thread"`
+	MessageID string `json:"message_id"`
+	UserID    string `json:"user"`
+}
+
+type SocketMemberResponse struct {
+	ThreadID string `json:"thread"`
+	ID       string `json:"id"`
+	UserID   string `json:"user_id"`
+	Name     string `json:"name"`
+}
+
+// SocketMemberRemovedResponse 作成者や管理者に外されたときの通知
+type SocketMemberRemovedResponse struct {
+	*SocketMemberResponse
+	Banned bool   `json:"banned"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type SocketRoleResponse struct {
+	*SocketMemberResponse
+	Role string `json:"role"`
+}
+
+// SocketArchiveResponse 解除したときはarchived_atがnull
+type SocketArchiveResponse struct {
+	ThreadID   string     `json:"thread"`
+	ArchivedAt *time.Time `json:"archived_at"`
+}
+
+// SocketSetupRequest setupのdataはthreadIDか、このjson
+type SocketSetupRequest struct {
+	Threads []*SocketSubscribeRequest `json:"threads"`
+}
+
+// SocketSubscribeRequest last_message_idかsinceを指定すると、それ以降のメッセージを再送してから配信を始める
+type SocketSubscribeRequest struct {
+	ThreadID      string     `json:"thread"`
+	LastMessageID string     `json:"last_message_id"`
+	Since         *time.Time `json:"since"`
+}
+
+type SocketReadRequest struct {
+	ThreadID  string `json:"thread"`
+	MessageID string `json:"message_id"`
 }
 
 type SocketMessageRequest struct {
-	Message string `json:"message"`
-	Grade   int    `json:"grade"`
+	ThreadID string `json:"thread"`
+	Message  string `json:"message"`
+	Grade    int    `json:"grade"`
+	ParentID string `json:"parent_id"`
 }
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
 }
 
-var connList = make(map[string]*websocket.Conn)
-
 func (sh *socketHandler) WebsocketConnect(w http.ResponseWriter, r *http.Request) {
-	upgrader.CheckOrigin = func(r *http.Request) bool { return true }
-
-	connect, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		llog.Error(err)
-	}
 	userID, err := lcontext.GetUserIDFromContext(r.Context())
 	if err != nil {
 		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
 		return
 	}
-
-	thread, err := sh.CheckReadOnly(connect, userID)
+	user, err := sh.userInteractor.GetByUserID(userID)
 	if err != nil {
-		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		response.Unauthorized(w, errors.Wrap(err, "failed to get user"), "failed to authentication. please login")
 		return
 	}
 
-	connList[userID] = connect
-	if thread != "" {
-		SendNotices(userID, "Web Socket Connected (room: "+thread+")")
-		go sh.webSocketProccessing(connect, userID, thread)
-	} else {
-		SendNotices(userID, "Web Socket Connected")
+	connect, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		llog.Error(errors.Wrap(err, "failed to upgrade connection"))
+		return
 	}
+
+	client := lsocket.NewClient(sh.hub, connect, user.ID)
+	sh.hub.Register(client)
+	go client.WritePump()
+	go client.ReadPump(func(c *lsocket.Client, frame []byte) {
+		sh.handleFrame(c, user, frame)
+	})
+
+	sh.sendNotice(client, "Web Socket Connected")
 }
 
-func (sh *socketHandler) webSocketProccessing(connect *websocket.Conn, userID string, thread string) {
+func (sh *socketHandler) handleFrame(c *lsocket.Client, user *entity.User, frame []byte) {
 	var sd SocketData
+	if err := json.Unmarshal(frame, &sd); err != nil {
+		sh.sendNotice(c, "invalid socket data")
+		return
+	}
+
 	var err error
-	for {
-		//read message from websocket
-		mType, p, err := connect.ReadMessage()
-		if err != nil {
-			llog.Error(err)
+	switch sd.Type {
+	case socketTypeSetup:
+		var req SocketSetupRequest
+		if req, err = parseSetupRequest(sd.Data); err != nil {
 			break
 		}
-		//connection closed
-		if mType == -1 {
-			err = nil
+		for _, sub := range req.Threads {
+			if subErr := sh.subscribe(c, user, sub); subErr != nil {
+				llog.Error(subErr)
+				sh.sendNotice(c, subErr.Error())
+			}
+		}
+	case socketTypeSubscribe:
+		var req *SocketSubscribeRequest
+		if req, err = parseSubscribeRequest(sd.Data); err != nil {
 			break
 		}
-		//unmarshal message to socketdata
-		if err = json.Unmarshal(p, &sd); err != nil {
-			llog.Error(err)
+		err = sh.subscribe(c, user, req)
+	case socketTypeUnsubscribe:
+		sh.hub.Unsubscribe(c, sd.Data)
+		sh.sendNotice(c, "Unsubscribed (room: "+sd.Data+")")
+	case socketTypeMessage:
+		var message SocketMessageRequest
+		if err = json.Unmarshal([]byte(sd.Data), &message); err != nil {
+			err = errors.Wrap(err, "failed to unmarshal message")
 			break
 		}
-
-		//check datatype
-		if sd.Type == "Message" {
-			var message SocketMessageRequest
-			if err = json.Unmarshal([]byte(sd.Data), &message); err != nil {
-				llog.Error(err)
-				break
-			}
-			if err = sh.sendMessage(userID, thread, message); err != nil {
-				llog.Error(err)
-			}
+		err = sh.sendMessage(c, user, message)
+	case socketTypeRead:
+		var read SocketReadRequest
+		if err = json.Unmarshal([]byte(sd.Data), &read); err != nil {
+			err = errors.Wrap(err, "failed to unmarshal read")
+			break
 		}
+		err = sh.markAsRead(c, user, read)
+	case socketTypeTypingStart:
+		if !sh.hub.IsSubscribed(c, sd.Data) {
+			err = errors.New("not subscribed to room " + sd.Data)
+			break
+		}
+		if sh.typing.Start(sd.Data, user.ID) {
+			err = broadcastToRoom(sh.hub, sd.Data, socketTypeTypingStart, &SocketTypingResponse{ThreadID: sd.Data, UserID: user.ID})
+		}
+	case socketTypeTypingStop:
+		if sh.typing.Stop(sd.Data, user.ID) {
+			sh.broadcastTypingStop(sd.Data, user.ID)
+		}
+	default:
+		err = errors.New("unknown socket data type: " + sd.Type)
+	}
+	if err != nil {
+		llog.Error(err)
+		sh.sendNotice(c, err.Error())
 	}
-	removeConnect(userID, err)
 }
 
-func SendNotices(userID string, notice string) error {
-	sd := &SocketData{
-		Type: "notice",
-		Data: notice,
+func (sh *socketHandler) subscribe(c *lsocket.Client, user *entity.User, req *SocketSubscribeRequest) error {
+	threadID := req.ThreadID
+	if threadID == "" {
+		return errors.New("Socket data is empty")
+	}
+	//check authorization
+	if !sh.threadInteractor.IsParticipated(threadID, user.ID) {
+		return errors.New(user.UserID + " are not participated in room " + threadID)
+	}
+	if req.LastMessageID == "" && req.Since == nil {
+		sh.hub.Subscribe(c, threadID)
+		sh.sendNotice(c, "Web Socket Connected (room: "+threadID+")")
+		return nil
 	}
-	js, err := json.Marshal(sd)
+
+	// 再送中に届いたメッセージはreleaseするまでhubに溜めておく
+	sh.hub.SubscribeHeld(c, threadID)
+	messages, truncated, err := sh.messageInteractor.GetMissed(threadID, req.LastMessageID, req.Since, constants.WSReplayLimit)
 	if err != nil {
-		llog.Warn(err)
+		sh.hub.Release(c, threadID, nil)
+		return errors.Wrap(err, "failed to get missed messages")
 	}
-	connList[userID].WriteMessage(1, js)
+	replay := make([]*lsocket.Frame, 0, len(messages)+2)
+	if truncated {
+		// 取りこぼした分はGET /threads/{threadID}/messagesで取得してもらう
+		frame, err := marshalSocketData(socketTypeNotice, "Replay truncated (room: "+threadID+")")
+		if err == nil {
+			replay = append(replay, &lsocket.Frame{Data: frame})
+		}
+	}
+	for _, message := range messages {
+		frame, err := marshalMessage(message)
+		if err != nil {
+			sh.hub.Release(c, threadID, nil)
+			return err
+		}
+		replay = append(replay, &lsocket.Frame{Key: message.ID, Data: frame})
+	}
+	if frame, err := marshalSocketData(socketTypeNotice, "Web Socket Connected (room: "+threadID+")"); err == nil {
+		replay = append(replay, &lsocket.Frame{Data: frame})
+	}
+	sh.hub.Release(c, threadID, replay)
 	return nil
 }
 
-func (sh *socketHandler) sendMessage(authorID string, threadID string, msg SocketMessageRequest) error {
-	message, err := sh.messageInteractor.Create(msg.Message, msg.Grade, authorID, threadID)
-	if err != nil {
-		return err
+func parseSetupRequest(data string) (SocketSetupRequest, error) {
+	var req SocketSetupRequest
+	if !strings.HasPrefix(strings.TrimSpace(data), "{") {
+		// threadIDだけのsetup
+		req.Threads = []*SocketSubscribeRequest{{ThreadID: data}}
+		return req, nil
 	}
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		return req, errors.Wrap(err, "failed to unmarshal setup")
+	}
+	return req, nil
+}
 
-	smrs := &SocketMessageResponse{
-		AuthorID:  authorID,
-		ThreadID:  threadID,
-		Message:   message.Message,
-		Grade:     message.Grade,
-		CreatedAt: message.CreatedAt,
+func parseSubscribeRequest(data string) (*SocketSubscribeRequest, error) {
+	if !strings.HasPrefix(strings.TrimSpace(data), "{") {
+		return &SocketSubscribeRequest{ThreadID: data}, nil
+	}
+	var req SocketSubscribeRequest
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal subscribe")
 	}
+	return &req, nil
+}
 
-	str, err := json.Marshal(smrs)
+func (sh *socketHandler) sendNotice(c *lsocket.Client, notice string) {
+	frame, err := marshalSocketData(socketTypeNotice, notice)
 	if err != nil {
-		return err
+		llog.Warn(err)
+		return
 	}
+	sh.hub.SendToClient(c, frame)
+}
 
-	sd := &SocketData{
-		Type: "message",
-		Data: string(str),
+func (sh *socketHandler) sendMessage(c *lsocket.Client, author *entity.User, msg SocketMessageRequest) error {
+	threadID := msg.ThreadID
+	if threadID == "" {
+		// 1つのroomだけ購読している場合はthreadを省略できる
+		rooms := sh.hub.Rooms(c)
+		if len(rooms) != 1 {
+			return errors.New("thread is not specified")
+		}
+		threadID = rooms[0]
+	}
+	if !sh.hub.IsSubscribed(c, threadID) {
+		return errors.New("not subscribed to room " + threadID)
+	}
+	if msg.Grade == constants.FileMessageGrade {
+		return errors.New("file message must be uploaded")
+	}
+	if err := sh.threadInteractor.CheckPermission(threadID, author.UserID, entity.PermissionPost); err != nil {
+		return err
 	}
 
-	sdJson, err := json.Marshal(sd)
+	message, err := sh.messageInteractor.Create(msg.Message, msg.Grade, author.UserID, threadID, msg.ParentID)
 	if err != nil {
 		return err
 	}
+	if sh.typing.Stop(threadID, author.ID) {
+		sh.broadcastTypingStop(threadID, author.ID)
+	}
+	if err = broadcastMessage(sh.hub, message); err != nil {
+		return err
+	}
+	notifyMentions(sh.hub, message)
+	return nil
+}
 
-	members, err := sh.threadInteractor.GetMembersByThreadID(threadID)
+func (sh *socketHandler) markAsRead(c *lsocket.Client, user *entity.User, req SocketReadRequest) error {
+	if !sh.hub.IsSubscribed(c, req.ThreadID) {
+		return errors.New("not subscribed to room " + req.ThreadID)
+	}
+	marker, updated, err := sh.messageInteractor.MarkAsRead(req.ThreadID, req.MessageID, user.UserID)
 	if err != nil {
 		return err
 	}
+	if !updated {
+		return nil
+	}
+	return broadcastRead(sh.hub, marker)
+}
 
-	for _, user := range members {
-		if connList[user.ID] != nil {
-			connList[user.ID].WriteJSON(sdJson)
-		}
+// broadcastRead 既読位置が進んだことをroomに通知する
+func broadcastRead(hub *lsocket.Hub, marker *entity.ReadMarker) error {
+	return broadcastToRoom(hub, marker.Thread.ID, socketTypeRead, &SocketReadResponse{
+		ThreadID:  marker.Thread.ID,
+		UserID:    marker.User.ID,
+		MessageID: marker.Message.ID,
+		ReadAt:    marker.ReadAt,
+	})
+}
+
+// notifyMentions メンションされたユーザの全クライアントに通知する
+func notifyMentions(hub *lsocket.Hub, message *entity.Message) {
+	if len(message.Mentions) == 0 {
+		return
+	}
+	frame, err := marshalSocketData(socketTypeMention, &SocketMentionResponse{
+		ThreadID:  message.Thread.ID,
+		MessageID: message.ID,
+		AuthorID:  message.Author.ID,
+		Message:   message.Message,
+	})
+	if err != nil {
+		llog.Warn(errors.Wrap(err, "failed to notify mentions"))
+		return
 	}
+	for _, mention := range message.Mentions {
+		hub.SendToUser(mention.User.ID, frame)
+	}
+}
 
-	return nil
+// broadcastPin pinnedがfalseならピン留めの解除を配信する
+func broadcastPin(hub *lsocket.Hub, threadID, messageID, userID string, pinned bool) error {
+	dataType := socketTypePinned
+	if !pinned {
+		dataType = socketTypeUnpinned
+	}
+	return broadcastToRoom(hub, threadID, dataType, &SocketPinResponse{
+		ThreadID:  threadID,
+		MessageID: messageID,
+		UserID:    userID,
+	})
+}
+
+func broadcastReaction(hub *lsocket.Hub, message *entity.Message, userID, reaction string, added bool) error {
+	var count int
+	for _, r := range message.Reactions {
+		if r.Reaction == reaction {
+			count = r.Count
+		}
+	}
+	return broadcastToRoom(hub, message.Thread.ID, socketTypeReaction, &SocketReactionResponse{
+		ThreadID:  message.Thread.ID,
+		MessageID: message.ID,
+		UserID:    userID,
+		Reaction:  reaction,
+		Added:     added,
+		Count:     count,
+	})
 }
 
-func removeConnect(id string, err error) {
+func (sh *socketHandler) broadcastTypingStop(threadID, userID string) {
+	err := broadcastToRoom(sh.hub, threadID, socketTypeTypingStop, &SocketTypingResponse{ThreadID: threadID, UserID: userID})
 	if err != nil {
-		connList[id].WriteMessage(websocket.CloseMessage, []byte(err.Error()))
-	} else {
-		connList[id].WriteMessage(websocket.CloseMessage, []byte("websocket connetion closed"))
+		llog.Warn(errors.Wrap(err, "failed to notify typing stop"))
 	}
+}
 
-	connList[id].Close()
-	delete(connList, id)
-	log.Println(id + ":Client disconnected")
+// watchPresence このインスタンスでのroomへの出入りをroomに通知する
+func (sh *socketHandler) watchPresence() {
+	for event := range sh.hub.RoomEvents() {
+		dataType := socketTypePresenceIn
+		if !event.Joined {
+			dataType = socketTypePresenceOut
+			if sh.typing.Stop(event.Room, event.UserID) {
+				sh.broadcastTypingStop(event.Room, event.UserID)
+			}
+		}
+		err := broadcastToRoom(sh.hub, event.Room, dataType, &SocketPresenceResponse{
+			ThreadID: event.Room,
+			UserID:   event.UserID,
+			Status:   sh.hub.Status(event.UserID),
+		})
+		if err != nil {
+			llog.Warn(errors.Wrap(err, "failed to notify presence"))
+		}
+	}
 }
 
-func (sh *socketHandler) CheckReadOnly(connect *websocket.Conn, userID string) (string, error) {
-	_, data, err := connect.ReadMessage()
+// broadcastMessage 作成されたメッセージを全インスタンスのroomに配信する
+func broadcastMessage(hub *lsocket.Hub, message *entity.Message) error {
+	frame, err := marshalMessage(message)
 	if err != nil {
-		return "", err
+		return err
 	}
-	var sd SocketData
-	if err = json.Unmarshal(data, &sd); err != nil {
-		log.Print(err)
-		return "", err
+	hub.BroadcastToRoomWithKey(message.Thread.ID, message.ID, frame)
+	return nil
+}
+
+// broadcastEdited 編集されたメッセージをroomに配信する。クライアントはidで既存のメッセージを置き換える
+func broadcastEdited(hub *lsocket.Hub, message *entity.Message) error {
+	return broadcastToRoom(hub, message.Thread.ID, socketTypeEdited, convertToSocketMessage(message))
+}
+
+// broadcastDeleted 削除されたメッセージをroomに配信する。本文は空になっている
+func broadcastDeleted(hub *lsocket.Hub, message *entity.Message) error {
+	return broadcastToRoom(hub, message.Thread.ID, socketTypeDeleted, convertToSocketMessage(message))
+}
+
+func marshalMessage(message *entity.Message) ([]byte, error) {
+	return marshalSocketData(socketTypeNewMessage, convertToSocketMessage(message))
+}
+
+func convertToSocketMessage(message *entity.Message) *SocketMessageResponse {
+	var parentID string
+	if message.Parent != nil {
+		parentID = message.Parent.ID
+	}
+	return &SocketMessageResponse{
+		ID:        message.ID,
+		AuthorID:  message.Author.ID,
+		ThreadID:  message.Thread.ID,
+		Message:   message.Message,
+		Grade:     message.Grade,
+		ParentID:  parentID,
+		CreatedAt: message.CreatedAt,
+		EditedAt:  message.EditedAt,
+		DeletedAt: message.DeletedAt,
 	}
+}
 
-	if sd.Type != "setup" {
-		return "", errors.New("Socket data type is not 'setup'")
+// broadcastMembership スレッドへの参加、退出をroomに通知する。退出の場合はそのユーザの購読も解除する
+func broadcastMembership(hub *lsocket.Hub, dataType, threadID string, user *entity.User) error {
+	err := broadcastToRoom(hub, threadID, dataType, &SocketMemberResponse{
+		ThreadID: threadID,
+		ID:       user.ID,
+		UserID:   user.UserID,
+		Name:     user.Name,
+	})
+	if dataType == socketTypeLeft {
+		hub.EvictFromRoom(threadID, user.ID)
 	}
+	return err
+}
+
+// broadcastRemoved 外されたユーザにも届くよう、通知してから購読を閉じる
+func broadcastRemoved(hub *lsocket.Hub, threadID string, user *entity.User, banned bool, reason string) error {
+	err := broadcastToRoom(hub, threadID, socketTypeRemoved, &SocketMemberRemovedResponse{
+		SocketMemberResponse: &SocketMemberResponse{
+			ThreadID: threadID,
+			ID:       user.ID,
+			UserID:   user.UserID,
+			Name:     user.Name,
+		},
+		Banned: banned,
+		Reason: reason,
+	})
+	hub.EvictFromRoom(threadID, user.ID)
+	return err
+}
+
+func broadcastRoleChanged(hub *lsocket.Hub, threadID string, user *entity.User, role entity.Role) error {
+	return broadcastToRoom(hub, threadID, socketTypeRole, &SocketRoleResponse{
+		SocketMemberResponse: &SocketMemberResponse{
+			ThreadID: threadID,
+			ID:       user.ID,
+			UserID:   user.UserID,
+			Name:     user.Name,
+		},
+		Role: string(role),
+	})
+}
 
-	if sd.Data == "" {
-		return "", errors.New("Socket data is empty")
+// sendToUser userIDはUUID。接続中のすべてのクライアントに送る
+func sendToUser(hub *lsocket.Hub, userID, dataType string, data interface{}) error {
+	frame, err := marshalSocketData(dataType, data)
+	if err != nil {
+		return err
 	}
-	user, err := sh.userInteractor.GetByID(userID)
+	hub.SendToUser(userID, frame)
+	return nil
+}
+
+func broadcastToRoom(hub *lsocket.Hub, threadID, dataType string, data interface{}) error {
+	frame, err := marshalSocketData(dataType, data)
 	if err != nil {
-		return "", err
+		return err
 	}
+	hub.BroadcastToRoom(threadID, frame)
+	return nil
+}
 
-	//check authorization
-	if !sh.threadInteractor.IsParticipated(sd.Data, user.ID) {
-		return "", errors.New(userID + " are not participated in room " + sd.Data)
-	} else {
-		return sd.Data, nil
+// marshalSocketData dataは文字列ならそのまま、それ以外はjsonにしてSocketData.Dataに詰める
+func marshalSocketData(dataType string, data interface{}) ([]byte, error) {
+	str, ok := data.(string)
+	if !ok {
+		js, err := json.Marshal(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal socket data")
+		}
+		str = string(js)
 	}
+	return json.Marshal(&SocketData{
+		Type: dataType,
+		Data: str,
+	})
 }
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"app/api/application/interactor"
+	"app/api/domain/entity"
 	"app/api/infrastructure/lcontext"
 	"app/api/presentation/request"
 	"app/api/presentation/response"
@@ -23,12 +24,14 @@ type TagHandler interface {
 type tagHandler struct {
 	tagInteractor      interactor.TagInteractor
 	categoryInteractor interactor.CategoryInteractor
+	threadInteractor   interactor.ThreadInteractor
 }
 
-func NewTagHandler(ti interactor.TagInteractor, ci interactor.CategoryInteractor) TagHandler {
+func NewTagHandler(ti interactor.TagInteractor, ci interactor.CategoryInteractor, thi interactor.ThreadInteractor) TagHandler {
 	return &tagHandler{
 		tagInteractor:      ti,
 		categoryInteractor: ci,
+		threadInteractor:   thi,
 	}
 }
 
@@ -123,11 +126,20 @@ func (th *tagHandler) RemoveTagFromUser(w http.ResponseWriter, r *http.Request)
 }
 
 func (th *tagHandler) AddTagToThread(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
 	threadID, err := ReadPathParam(r, "threadID")
 	if err != nil {
 		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
 		return
 	}
+	if err = th.threadInteractor.CheckPermission(threadID, userID, entity.PermissionManageTags); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
 	src, err := ReadRequestBody(r, &request.CreateTagRequest{})
 	if err != nil {
 		response.BadRequest(w, errors.Wrap(err, "failed to read request"), "failed to read request")
@@ -149,11 +161,20 @@ func (th *tagHandler) AddTagToThread(w http.ResponseWriter, r *http.Request) {
 }
 
 func (th *tagHandler) RemoveTagFromThread(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
 	threadID, err := ReadPathParam(r, "threadID")
 	if err != nil {
 		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
 		return
 	}
+	if err = th.threadInteractor.CheckPermission(threadID, userID, entity.PermissionManageTags); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
 	tagID, err := ReadPathParam(r, "tagID")
 	if err != nil {
 		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
@@ -2,7 +2,10 @@ package handler
 
 import (
 	"app/api/application/interactor"
+	"app/api/domain/entity"
 	"app/api/infrastructure/lcontext"
+	"app/api/infrastructure/lsocket"
+	"app/api/llog"
 	"app/api/presentation/request"
 	"app/api/presentation/response"
 	"net/http"
@@ -11,26 +14,45 @@ import (
 )
 
 type ThreadHandler interface {
-	Create(w http.ResponseWriter, r *http.Request)               //Create thread
-	GetAll(w http.ResponseWriter, r *http.Request)               //Get all threads
-	GetByID(w http.ResponseWriter, r *http.Request)              //Get thread by ID
-	GetByUserID(w http.ResponseWriter, r *http.Request)          //Get thread by user ID
-	GetOnlyPublic(w http.ResponseWriter, r *http.Request)        //Get public thread
-	GetMembersByThreadID(w http.ResponseWriter, r *http.Request) //Get members in thread
-	Update(w http.ResponseWriter, r *http.Request)               //Thread update
-	Delete(w http.ResponseWriter, r *http.Request)               //Thread delete
-	Join(w http.ResponseWriter, r *http.Request)                 //Join member to thread
-	Leave(w http.ResponseWriter, r *http.Request)                //Leave the thread
-	ForceToLeave(w http.ResponseWriter, r *http.Request)         //Kicked the member from thread
+	Create(w http.ResponseWriter, r *http.Request)                //Create thread
+	GetAll(w http.ResponseWriter, r *http.Request)                //Get all threads
+	GetByID(w http.ResponseWriter, r *http.Request)               //Get thread by ID
+	GetByUserID(w http.ResponseWriter, r *http.Request)           //Get thread by user ID
+	GetRecommendations(w http.ResponseWriter, r *http.Request)    //Get recommended threads for me
+	GetOnlyPublic(w http.ResponseWriter, r *http.Request)         //Get public thread
+	GetMembersByThreadID(w http.ResponseWriter, r *http.Request)  //Get members in thread
+	GetPresenceByThreadID(w http.ResponseWriter, r *http.Request) //Get online status of members in thread
+	Update(w http.ResponseWriter, r *http.Request)                //Thread update
+	Delete(w http.ResponseWriter, r *http.Request)                //Thread delete
+	Join(w http.ResponseWriter, r *http.Request)                  //Join member to thread
+	Leave(w http.ResponseWriter, r *http.Request)                 //Leave the thread
+	ForceToLeave(w http.ResponseWriter, r *http.Request)          //Kick or ban the member from thread
+	GetBans(w http.ResponseWriter, r *http.Request)               //Get banned users of thread
+	Unban(w http.ResponseWriter, r *http.Request)                 //Lift the ban of user
+	ChangeRole(w http.ResponseWriter, r *http.Request)            //Promote or demote the member
+	TransferOwnership(w http.ResponseWriter, r *http.Request)     //Hand over the owner role to the member
+	GetJoinRequests(w http.ResponseWriter, r *http.Request)       //Get join requests of thread
+	GetWaitlist(w http.ResponseWriter, r *http.Request)           //Get waitlist of full thread
+	Archive(w http.ResponseWriter, r *http.Request)               //Make thread read-only
+	Restore(w http.ResponseWriter, r *http.Request)               //Restore archived thread
+	LeaveWaitlist(w http.ResponseWriter, r *http.Request)         //Leave waitlist of thread
+	GetMyJoinRequests(w http.ResponseWriter, r *http.Request)     //Get my join requests
+	DecideJoinRequest(w http.ResponseWriter, r *http.Request)     //Approve or reject the join request
 }
 
 type threadHandler struct {
-	threadInteractor interactor.ThreadInteractor
+	hub               *lsocket.Hub
+	threadInteractor  interactor.ThreadInteractor
+	userInteractor    interactor.UserInteractor
+	messageInteractor interactor.MessageInteractor
 }
 
-func NewThreadHandler(ti interactor.ThreadInteractor) ThreadHandler {
+func NewThreadHandler(hub *lsocket.Hub, ti interactor.ThreadInteractor, ui interactor.UserInteractor, mi interactor.MessageInteractor) ThreadHandler {
 	return &threadHandler{
-		threadInteractor: ti,
+		hub:               hub,
+		threadInteractor:  ti,
+		userInteractor:    ui,
+		messageInteractor: mi,
 	}
 }
 
@@ -53,7 +75,7 @@ func (th *threadHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	thread, err := th.threadInteractor.Create(req.Name, req.Description, req.LimitUsers, req.IsPublic, userID)
+	thread, err := th.threadInteractor.Create(req.Name, req.Description, req.LimitUsers, req.IsPublic, entity.JoinPolicy(req.JoinPolicy), userID)
 	if err != nil {
 		response.InternalServerError(w, errors.Wrap(err, "failed to create thread"), "failed to create thread")
 		return
@@ -82,7 +104,13 @@ func (th *threadHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		response.InternalServerError(w, errors.Wrap(err, "failed to get thread"), "failed to get thread")
 		return
 	}
-	response.Success(w, response.ConvertToThreadResponse(thread))
+	// ログインしていなくても見えるので、ピン留めはメッセージIDだけ返す
+	pins, err := th.messageInteractor.GetPins(thread.ID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get pinned messages"), "failed to get pinned messages")
+		return
+	}
+	response.Success(w, response.ConvertToThreadDetailResponse(thread, pins))
 }
 
 func (th *threadHandler) GetByUserID(w http.ResponseWriter, r *http.Request) {
@@ -96,7 +124,35 @@ func (th *threadHandler) GetByUserID(w http.ResponseWriter, r *http.Request) {
 		response.InternalServerError(w, errors.Wrap(err, "failed to get threads"), "failed to get threads")
 		return
 	}
-	response.Success(w, response.ConvertToThreadsResponse(threads))
+	unreads, err := th.messageInteractor.GetUnreadCounts(userID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get unread counts"), "failed to get unread counts")
+		return
+	}
+	response.Success(w, response.ConvertToUserThreadsResponse(threads, unreads))
+}
+
+func (th *threadHandler) GetRecommendations(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	req, err := request.NewGetRecommendationsRequest(r.URL.Query())
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read query"), err.Error())
+		return
+	}
+	if err = req.Validation(); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+	recommendations, err := th.threadInteractor.GetRecommendations(userID, req.Limit)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get recommendations"), "failed to get recommendations")
+		return
+	}
+	response.Success(w, response.ConvertToThreadRecommendationsResponse(recommendations))
 }
 
 func (th *threadHandler) GetOnlyPublic(w http.ResponseWriter, r *http.Request) {
@@ -119,7 +175,41 @@ func (th *threadHandler) GetMembersByThreadID(w http.ResponseWriter, r *http.Req
 		response.InternalServerError(w, errors.Wrap(err, "failed to get members"), "failed to get members")
 		return
 	}
-	response.Success(w, response.ConvertToUsersResponse(members))
+	roles, err := th.threadInteractor.GetRoles(id)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get roles"), "failed to get roles")
+		return
+	}
+	response.Success(w, response.ConvertToMembersResponse(members, roles))
+}
+
+func (th *threadHandler) GetPresenceByThreadID(w http.ResponseWriter, r *http.Request) {
+	id, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	members, err := th.threadInteractor.GetMembersByThreadID(id)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get members"), "failed to get members")
+		return
+	}
+	if !checkMember(userID, members) {
+		response.BadRequest(w, errors.New("not memeber of thread"), "not member of thread")
+		return
+	}
+
+	statuses := make(map[string]string, len(members))
+	for _, member := range members {
+		statuses[member.ID] = th.hub.Status(member.ID)
+	}
+	response.Success(w, response.ConvertToPresencesResponse(members, statuses))
 }
 
 func (th *threadHandler) Update(w http.ResponseWriter, r *http.Request) {
@@ -146,20 +236,35 @@ func (th *threadHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	thread, err := th.threadInteractor.Update(id, req.Name, req.Description, req.LimitUsers, req.IsPublic)
+	thread, err := th.threadInteractor.Update(id, req.Name, req.Description, req.LimitUsers, req.IsPublic, entity.JoinPolicy(req.JoinPolicy))
 	if err != nil {
 		response.InternalServerError(w, errors.Wrap(err, "failed to update thread"), "failed to update thread")
 		return
 	}
+	// 人数制限を増やしたときは空いた分だけ参加待ちが入る
+	th.promoteWaitlist(id)
 	response.Success(w, response.ConvertToThreadResponse(thread))
 }
 
 func (th *threadHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
 	id, err := ReadPathParam(r, "id")
 	if err != nil {
 		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
 		return
 	}
+	if _, err = th.threadInteractor.GetByID(id); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get thread"), "thread is not found")
+		return
+	}
+	if err = th.threadInteractor.CheckPermission(id, userID, entity.PermissionDeleteThread); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
 
 	if err := th.threadInteractor.Delete(id); err != nil {
 		response.InternalServerError(w, errors.Wrap(err, "failed to delete thread"), "failed to delete thread")
@@ -179,19 +284,156 @@ func (th *threadHandler) Join(w http.ResponseWriter, r *http.Request) {
 		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
 		return
 	}
-	_, err = th.threadInteractor.GetByID(threadID)
-	if err != nil {
+	if _, err = th.threadInteractor.GetByID(threadID); err != nil {
 		response.NotFound(w, errors.Wrap(err, "failed to get thread"), "thread is not found")
 		return
 	}
+	if err = th.threadInteractor.CheckJoinable(threadID, userID); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check joinable"), errors.Cause(err).Error())
+		return
+	}
 
-	if err = th.threadInteractor.AddMember(threadID, userID); err != nil {
+	joinRequest, waiting, err := th.threadInteractor.AddMember(threadID, userID)
+	if err != nil {
 		response.InternalServerError(w, errors.Wrap(err, "failed to join thread"), "failed to join thread")
 		return
 	}
+	if joinRequest != nil {
+		// 承認制のスレッドでは申請を作って管理者に知らせる
+		th.notifyJoinRequest(joinRequest)
+		response.Success(w, response.ConvertToJoinRequestResponse(joinRequest))
+		return
+	}
+	if waiting != nil {
+		response.Success(w, response.ConvertToWaitlistEntryResponse(waiting))
+		return
+	}
+	th.notifyMembership(socketTypeJoined, threadID, userID)
 	response.NoContent(w)
 }
 
+func (th *threadHandler) GetJoinRequests(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	req, err := request.NewGetJoinRequestsRequest(r.URL.Query())
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read query"), err.Error())
+		return
+	}
+	if err = req.Validation(); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+	if err = th.threadInteractor.CheckPermission(threadID, userID, entity.PermissionApprove); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
+
+	requests, err := th.threadInteractor.GetJoinRequests(threadID, entity.JoinRequestStatus(req.Status))
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get join requests"), "failed to get join requests")
+		return
+	}
+	response.Success(w, response.ConvertToJoinRequestsResponse(requests))
+}
+
+func (th *threadHandler) GetMyJoinRequests(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+
+	requests, err := th.threadInteractor.GetJoinRequestsByUserID(userID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get join requests"), "failed to get join requests")
+		return
+	}
+	response.Success(w, response.ConvertToJoinRequestsResponse(requests))
+}
+
+func (th *threadHandler) DecideJoinRequest(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	requestID, err := ReadPathParam(r, "requestID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	src, err := ReadRequestBody(r, &request.DecideJoinRequestRequest{})
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read request"), "failed to read request")
+		return
+	}
+	req, _ := src.(*request.DecideJoinRequestRequest)
+	if err = req.Validation(); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+	if err = th.threadInteractor.CheckPermission(threadID, userID, entity.PermissionApprove); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
+
+	approve := req.Status == string(entity.JoinRequestApproved)
+	joinRequest, decided, err := th.threadInteractor.DecideJoinRequest(userID, threadID, requestID, approve)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to decide join request"), "failed to decide join request")
+		return
+	}
+	if !decided {
+		response.BadRequest(w, errors.New("join request is already decided"), "join request is already decided")
+		return
+	}
+	res := response.ConvertToJoinRequestResponse(joinRequest)
+	if err = sendToUser(th.hub, joinRequest.User.ID, socketTypeJoinDecided, res); err != nil {
+		llog.Warn(errors.Wrap(err, "failed to notify join request"))
+	}
+	// 満員で参加待ちに入ったときは、空きができてメンバーになったときに知らせる
+	if approve && joinRequest.Waitlist == nil {
+		if err = broadcastMembership(th.hub, socketTypeJoined, threadID, joinRequest.User); err != nil {
+			llog.Warn(errors.Wrap(err, "failed to notify membership"))
+		}
+	}
+	response.Success(w, res)
+}
+
+// notifyJoinRequest 承認できるメンバーにだけ送る
+func (th *threadHandler) notifyJoinRequest(joinRequest *entity.JoinRequest) {
+	roles, err := th.threadInteractor.GetRoles(joinRequest.Thread.ID)
+	if err != nil {
+		llog.Warn(errors.Wrap(err, "failed to notify join request"))
+		return
+	}
+	frame, err := marshalSocketData(socketTypeJoinRequested, response.ConvertToJoinRequestResponse(joinRequest))
+	if err != nil {
+		llog.Warn(errors.Wrap(err, "failed to notify join request"))
+		return
+	}
+	for memberID, role := range roles {
+		if role.Can(entity.PermissionApprove) {
+			th.hub.SendToUser(memberID, frame)
+		}
+	}
+}
+
 func (th *threadHandler) Leave(w http.ResponseWriter, r *http.Request) {
 	userID, err := lcontext.GetUserIDFromContext(r.Context())
 	if err != nil {
@@ -203,14 +445,414 @@ func (th *threadHandler) Leave(w http.ResponseWriter, r *http.Request) {
 		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
 		return
 	}
+	if _, err = th.threadInteractor.GetByID(threadID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get thread"), "thread is not found")
+		return
+	}
+	if err = th.threadInteractor.CheckLeavable(threadID, userID); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check leavable"), errors.Cause(err).Error())
+		return
+	}
 
-	if err = th.threadInteractor.RemoveMember(threadID, userID); err != nil {
+	succession, err := th.threadInteractor.RemoveMember(threadID, userID)
+	if err != nil {
 		response.InternalServerError(w, errors.Wrap(err, "failed to leave thread"), "failed to leave thread")
 		return
 	}
+	th.notifyMembership(socketTypeLeft, threadID, userID)
+	if succession != nil {
+		// ownerが抜けたときは後任を知らせる。誰も残っていなければ削除かアーカイブ済み
+		if succession.NewOwner == nil {
+			response.NoContent(w)
+			return
+		}
+		if err = broadcastRoleChanged(th.hub, threadID, succession.NewOwner, entity.RoleOwner); err != nil {
+			llog.Warn(errors.Wrap(err, "failed to broadcast role"))
+		}
+	}
+	th.promoteWaitlist(threadID)
+	response.NoContent(w)
+}
+
+func (th *threadHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	if _, err = th.threadInteractor.GetByID(threadID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get thread"), "thread is not found")
+		return
+	}
+	if err = th.threadInteractor.CheckPermission(threadID, userID, entity.PermissionArchive); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
+
+	thread, archived, err := th.threadInteractor.Archive(threadID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to archive thread"), "failed to archive thread")
+		return
+	}
+	if !archived {
+		response.BadRequest(w, errors.New("already archived"), "thread is already archived")
+		return
+	}
+	err = broadcastToRoom(th.hub, threadID, socketTypeArchived, &SocketArchiveResponse{
+		ThreadID:   threadID,
+		ArchivedAt: thread.ArchivedAt,
+	})
+	if err != nil {
+		llog.Warn(errors.Wrap(err, "failed to notify archive"))
+	}
+	response.Success(w, response.ConvertToThreadResponse(thread))
+}
+
+func (th *threadHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	if _, err = th.threadInteractor.GetByID(threadID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get thread"), "thread is not found")
+		return
+	}
+	if err = th.threadInteractor.CheckPermission(threadID, userID, entity.PermissionArchive); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
+
+	restored, err := th.threadInteractor.Restore(threadID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to restore thread"), "failed to restore thread")
+		return
+	}
+	if !restored {
+		response.BadRequest(w, errors.New("not archived"), "thread is not archived")
+		return
+	}
+	if err = broadcastToRoom(th.hub, threadID, socketTypeRestored, &SocketArchiveResponse{ThreadID: threadID}); err != nil {
+		llog.Warn(errors.Wrap(err, "failed to notify restore"))
+	}
+	// アーカイブ中に空いた席には参加待ちを入れていない
+	th.promoteWaitlist(threadID)
+	response.NoContent(w)
+}
+
+// GetWaitlist 誰が参加待ちかはメンバーにだけ見せる
+func (th *threadHandler) GetWaitlist(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	if _, err = th.threadInteractor.GetByID(threadID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get thread"), "thread is not found")
+		return
+	}
+	members, err := th.threadInteractor.GetMembersByThreadID(threadID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get members"), "failed to get members")
+		return
+	}
+	if !checkMember(userID, members) {
+		response.BadRequest(w, errors.New("not memeber of thread"), "not member of thread")
+		return
+	}
+
+	entries, err := th.threadInteractor.GetWaitlist(threadID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get waitlist"), "failed to get waitlist")
+		return
+	}
+	response.Success(w, response.ConvertToWaitlistResponse(entries))
+}
+
+func (th *threadHandler) LeaveWaitlist(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	removed, err := th.threadInteractor.LeaveWaitlist(threadID, userID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to leave waitlist"), "failed to leave waitlist")
+		return
+	}
+	if !removed {
+		response.BadRequest(w, errors.New("not on waitlist"), "you are not on waitlist")
+		return
+	}
 	response.NoContent(w)
 }
 
+// promoteWaitlist 空いた席に参加待ちを上げて、ルームと本人に知らせる
+func (th *threadHandler) promoteWaitlist(threadID string) {
+	users, err := th.threadInteractor.PromoteWaitlist(threadID)
+	if err != nil {
+		llog.Warn(errors.Wrap(err, "failed to promote waitlist"))
+	}
+	for _, user := range users {
+		if err = broadcastMembership(th.hub, socketTypeJoined, threadID, user); err != nil {
+			llog.Warn(errors.Wrap(err, "failed to notify membership"))
+		}
+		err = sendToUser(th.hub, user.ID, socketTypePromoted, &SocketMemberResponse{
+			ThreadID: threadID,
+			ID:       user.ID,
+			UserID:   user.UserID,
+			Name:     user.Name,
+		})
+		if err != nil {
+			llog.Warn(errors.Wrap(err, "failed to notify promotion"))
+		}
+	}
+}
+
 func (th *threadHandler) ForceToLeave(w http.ResponseWriter, r *http.Request) {
-	response.NotImplemented(w)
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	leavedUserID, err := ReadPathParam(r, "userID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	req, err := request.NewForceToLeaveRequest(r.URL.Query())
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read query"), err.Error())
+		return
+	}
+	if err = req.Validation(); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+	if _, err = th.threadInteractor.GetByID(threadID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get thread"), "thread is not found")
+		return
+	}
+	target, err := th.userInteractor.GetByUserID(leavedUserID)
+	if err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get user"), "user is not found")
+		return
+	}
+	if err = th.threadInteractor.CheckRemovable(userID, threadID, leavedUserID); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), errors.Cause(err).Error())
+		return
+	}
+	if !req.Ban && !th.threadInteractor.IsParticipated(threadID, target.ID) {
+		response.BadRequest(w, errors.New("not memeber of thread"), "user is not member of thread")
+		return
+	}
+
+	user, err := th.threadInteractor.ForceToLeave(userID, threadID, leavedUserID, req.Ban, req.Reason, req.ExpiresAt)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to remove member"), "failed to remove member")
+		return
+	}
+	if err = broadcastRemoved(th.hub, threadID, user, req.Ban, req.Reason); err != nil {
+		llog.Warn(errors.Wrap(err, "failed to notify membership"))
+	}
+	th.promoteWaitlist(threadID)
+	response.NoContent(w)
+}
+
+func (th *threadHandler) GetBans(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	if _, err = th.threadInteractor.GetByID(threadID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get thread"), "thread is not found")
+		return
+	}
+	if err = th.threadInteractor.CheckPermission(threadID, userID, entity.PermissionKick); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), err.Error())
+		return
+	}
+
+	bans, err := th.threadInteractor.GetBans(threadID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to get bans"), "failed to get bans")
+		return
+	}
+	response.Success(w, response.ConvertToThreadBansResponse(bans))
+}
+
+func (th *threadHandler) Unban(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	bannedUserID, err := ReadPathParam(r, "userID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	if _, err = th.threadInteractor.GetByID(threadID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get thread"), "thread is not found")
+		return
+	}
+	if _, err = th.userInteractor.GetByUserID(bannedUserID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get user"), "user is not found")
+		return
+	}
+	if err = th.threadInteractor.CheckRemovable(userID, threadID, bannedUserID); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to check permission"), errors.Cause(err).Error())
+		return
+	}
+
+	removed, err := th.threadInteractor.Unban(userID, threadID, bannedUserID)
+	if err != nil {
+		response.InternalServerError(w, errors.Wrap(err, "failed to unban"), "failed to unban")
+		return
+	}
+	if !removed {
+		response.NotFound(w, errors.New("ban is not found"), "ban is not found")
+		return
+	}
+	response.NoContent(w)
+}
+
+func (th *threadHandler) ChangeRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+	targetUserID, err := ReadPathParam(r, "userID")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	src, err := ReadRequestBody(r, &request.ChangeRoleRequest{})
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read request"), "failed to read request")
+		return
+	}
+	req, _ := src.(*request.ChangeRoleRequest)
+	if err = req.Validation(); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+	if _, err = th.threadInteractor.GetByID(threadID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get thread"), "thread is not found")
+		return
+	}
+	if _, err = th.userInteractor.GetByUserID(targetUserID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get user"), "user is not found")
+		return
+	}
+
+	role := entity.Role(req.Role)
+	user, err := th.threadInteractor.ChangeRole(userID, threadID, targetUserID, role)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to change role"), errors.Cause(err).Error())
+		return
+	}
+	if err = broadcastRoleChanged(th.hub, threadID, user, role); err != nil {
+		llog.Warn(errors.Wrap(err, "failed to broadcast role"))
+	}
+	response.Success(w, response.ConvertToMemberResponse(user, role))
+}
+
+func (th *threadHandler) TransferOwnership(w http.ResponseWriter, r *http.Request) {
+	userID, err := lcontext.GetUserIDFromContext(r.Context())
+	if err != nil {
+		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication. please login")
+		return
+	}
+	threadID, err := ReadPathParam(r, "id")
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "path parameter is empty"), "path parameter is empty")
+		return
+	}
+
+	src, err := ReadRequestBody(r, &request.TransferOwnershipRequest{})
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to read request"), "failed to read request")
+		return
+	}
+	req, _ := src.(*request.TransferOwnershipRequest)
+	if err = req.Validation(); err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to validation"), err.Error())
+		return
+	}
+	if _, err = th.threadInteractor.GetByID(threadID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get thread"), "thread is not found")
+		return
+	}
+	if _, err = th.userInteractor.GetByUserID(req.UserID); err != nil {
+		response.NotFound(w, errors.Wrap(err, "failed to get user"), "user is not found")
+		return
+	}
+
+	thread, previous, err := th.threadInteractor.TransferOwnership(userID, threadID, req.UserID)
+	if err != nil {
+		response.BadRequest(w, errors.Wrap(err, "failed to transfer ownership"), errors.Cause(err).Error())
+		return
+	}
+	if err = broadcastRoleChanged(th.hub, threadID, thread.Author, entity.RoleOwner); err != nil {
+		llog.Warn(errors.Wrap(err, "failed to broadcast role"))
+	}
+	if err = broadcastRoleChanged(th.hub, threadID, previous, entity.RoleModerator); err != nil {
+		llog.Warn(errors.Wrap(err, "failed to broadcast role"))
+	}
+	response.Success(w, response.ConvertToThreadResponse(thread))
+}
+
+func (th *threadHandler) notifyMembership(dataType, threadID, userID string) {
+	user, err := th.userInteractor.GetByUserID(userID)
+	if err == nil {
+		err = broadcastMembership(th.hub, dataType, threadID, user)
+	}
+	if err != nil {
+		llog.Warn(errors.Wrap(err, "failed to notify membership"))
+	}
 }
@@ -2,8 +2,11 @@ package handler
 
 import (
 	"app/api/application/interactor"
+	"app/api/domain/entity"
 	"app/api/infrastructure/lcontext"
 	"app/api/infrastructure/lsession"
+	"app/api/infrastructure/lsocket"
+	"app/api/llog"
 	"app/api/presentation/request"
 	"app/api/presentation/response"
 	"net/http"
@@ -12,6 +15,7 @@ import (
 )
 
 type userHandler struct {
+	hub            *lsocket.Hub
 	userInteractor interactor.UserInteractor
 }
 
@@ -30,8 +34,9 @@ type UserHandler interface {
 	GetFollowers(w http.ResponseWriter, r *http.Request)   //Get followers by user ID
 }
 
-func NewUserHandler(ui interactor.UserInteractor) UserHandler {
+func NewUserHandler(hub *lsocket.Hub, ui interactor.UserInteractor) UserHandler {
 	return &userHandler{
+		hub:            hub,
 		userInteractor: ui,
 	}
 }
@@ -187,15 +192,36 @@ func (uh *userHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
 		response.Unauthorized(w, errors.Wrap(err, "failed to authentication"), "failed to authentication")
 		return
 	}
-	err = uh.userInteractor.Delete(userID)
+	deletion, err := uh.userInteractor.Delete(userID)
 	if err != nil {
 		response.InternalServerError(w, errors.Wrap(err, "failed to delete user"), "failed to delete")
 		return
 	}
-	lsession.EndSession(w, r)
+	// 他の端末のセッションとwebsocketも切る。猶予期間中はログインすれば削除を取り消せる
+	if err = lsession.EndAllSessions(w, r); err != nil {
+		llog.Warn(errors.Wrap(err, "failed to end sessions"))
+	}
+	uh.hub.Disconnect(deletion.User.ID)
+	if deletion.ScheduledAt != nil {
+		response.Success(w, response.ConvertToAccountDeletionResponse(deletion))
+		return
+	}
+	broadcastSuccessions(uh.hub, deletion.Successions)
 	response.NoContent(w)
 }
 
+// broadcastSuccessions 削除されたユーザが持っていたスレッドの後任を知らせる
+func broadcastSuccessions(hub *lsocket.Hub, successions []*entity.Succession) {
+	for _, succession := range successions {
+		if succession.NewOwner == nil {
+			continue
+		}
+		if err := broadcastRoleChanged(hub, succession.Thread.ID, succession.NewOwner, entity.RoleOwner); err != nil {
+			llog.Warn(errors.Wrap(err, "failed to broadcast role"))
+		}
+	}
+}
+
 func (uh *userHandler) GetFollows(w http.ResponseWriter, r *http.Request) {
 	id, err := ReadPathParam(r, "id")
 	if err != nil {
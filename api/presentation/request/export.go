@@ -0,0 +1,27 @@
+package request
+
+import (
+	"app/api/constants"
+
+	"github.com/pkg/errors"
+)
+
+// CreateExportRequest passwordを指定するとzipを暗号化し、ダウンロードにもパスワードが必要になる
+type CreateExportRequest struct {
+	IsPublic int    `json:"is_public"`
+	Password string `json:"password"`
+}
+
+func (r *CreateExportRequest) Validation() error {
+	if r.IsPublic != 0 && r.IsPublic != 1 {
+		return errors.New("is_public allow 0 or 1")
+	}
+	if len(r.Password) > constants.ExportPasswordMaxLength {
+		return errors.Errorf("password allow up to %d characters", constants.ExportPasswordMaxLength)
+	}
+	return nil
+}
+
+type DownloadExportRequest struct {
+	Password string `json:"password"`
+}
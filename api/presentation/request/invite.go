@@ -0,0 +1,29 @@
+package request
+
+import (
+	"app/api/constants"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CreateInviteRequest user_idを指定するとそのユーザ宛ての招待になる
+type CreateInviteRequest struct {
+	UserID    string     `json:"user_id"`
+	MaxUses   int        `json:"max_uses"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (r *CreateInviteRequest) Validation() error {
+	if r.MaxUses < 0 || r.MaxUses > constants.InviteMaxUses {
+		return errors.Errorf("max_uses allow 0 to %d", constants.InviteMaxUses)
+	}
+	if r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now()) {
+		return errors.New("expires_at must be in the future")
+	}
+	// ユーザ宛ての招待は1回だけ使える
+	if r.UserID != "" {
+		r.MaxUses = 1
+	}
+	return nil
+}
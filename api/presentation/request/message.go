@@ -1,10 +1,24 @@
 package request
 
-import "github.com/pkg/errors"
+import (
+	"app/api/application/interactor"
+	"app/api/constants"
+	"app/api/domain/entity"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
 
 type CreateMessageRequest struct {
-	Message string `json:"message"`
-	Grade   int    `json:"grade"`
+	Message  string `json:"message"`
+	Grade    int    `json:"grade"`
+	ParentID string `json:"parent_id"`
 }
 
 func (r *CreateMessageRequest) Validation() error {
@@ -14,5 +28,146 @@ func (r *CreateMessageRequest) Validation() error {
 	if r.Grade < 1 {
 		return errors.New("grande don't allow minas")
 	}
+	// ファイルのメッセージはアップロードからしか作れない
+	if r.Grade == constants.FileMessageGrade {
+		return errors.New("file message must be uploaded")
+	}
+	return nil
+}
+
+type UpdateMessageRequest struct {
+	Message string `json:"message"`
+}
+
+func (r *UpdateMessageRequest) Validation(mi interactor.MessageInteractor, threadID, messageID, requestUserID string) error {
+	if r.Message == "" {
+		return errors.New("required field is empty")
+	}
+	return mi.CheckEditable(threadID, messageID, requestUserID)
+}
+
+type ReadMessageRequest struct {
+	MessageID string `json:"message_id"`
+}
+
+func (r *ReadMessageRequest) Validation() error {
+	if r.MessageID == "" {
+		return errors.New("required field is empty")
+	}
+	return nil
+}
+
+type AddReactionRequest struct {
+	Reaction string `json:"reaction"`
+}
+
+func (r *AddReactionRequest) Validation() error {
+	return ValidateReaction(r.Reaction)
+}
+
+// ValidateReaction リアクションは絵文字か短い名前("heart"など)。path parameterにも使うので空白と/は不可
+func ValidateReaction(reaction string) error {
+	if reaction == "" {
+		return errors.New("required field is empty")
+	}
+	if utf8.RuneCountInString(reaction) > constants.ReactionMaxLength {
+		return errors.New("reaction is too long")
+	}
+	if strings.ContainsRune(reaction, '/') || strings.IndexFunc(reaction, unicode.IsSpace) >= 0 {
+		return errors.New("reaction contains invalid character")
+	}
 	return nil
 }
+
+// GetMessagesRequest query parameterのbefore, afterはレスポンスのprev_cursor, next_cursorをそのまま渡す
+type GetMessagesRequest struct {
+	Before *entity.MessageCursor
+	After  *entity.MessageCursor
+	Limit  int
+}
+
+func NewGetMessagesRequest(query url.Values) (*GetMessagesRequest, error) {
+	req := &GetMessagesRequest{Limit: constants.MessagePageSize}
+	var err error
+	if before := query.Get("before"); before != "" {
+		if req.Before, err = DecodeMessageCursor(before); err != nil {
+			return nil, errors.Wrap(err, "invalid before")
+		}
+	}
+	if after := query.Get("after"); after != "" {
+		if req.After, err = DecodeMessageCursor(after); err != nil {
+			return nil, errors.Wrap(err, "invalid after")
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if req.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, errors.Wrap(err, "invalid limit")
+		}
+	}
+	return req, nil
+}
+
+func (r *GetMessagesRequest) Validation() error {
+	if r.Before != nil && r.After != nil {
+		return errors.New("before and after cannot be specified together")
+	}
+	if r.Limit < 1 {
+		return errors.New("limit must be positive")
+	}
+	if r.Limit > constants.MessagePageSizeMax {
+		r.Limit = constants.MessagePageSizeMax
+	}
+	return nil
+}
+
+// GetMentionsRequest beforeはレスポンスのnext_cursorをそのまま渡す
+type GetMentionsRequest struct {
+	Before *entity.MessageCursor
+	Limit  int
+}
+
+func NewGetMentionsRequest(query url.Values) (*GetMentionsRequest, error) {
+	req := &GetMentionsRequest{Limit: constants.MessagePageSize}
+	var err error
+	if before := query.Get("before"); before != "" {
+		if req.Before, err = DecodeMessageCursor(before); err != nil {
+			return nil, errors.Wrap(err, "invalid before")
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if req.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, errors.Wrap(err, "invalid limit")
+		}
+	}
+	return req, nil
+}
+
+func (r *GetMentionsRequest) Validation() error {
+	if r.Limit < 1 {
+		return errors.New("limit must be positive")
+	}
+	if r.Limit > constants.MessagePageSizeMax {
+		r.Limit = constants.MessagePageSizeMax
+	}
+	return nil
+}
+
+// DecodeMessageCursor "created_at(RFC3339Nano)_id"をbase64urlにしたもの
+func DecodeMessageCursor(cursor string) (*entity.MessageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode cursor")
+	}
+	parts := strings.SplitN(string(raw), "_", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, errors.New("malformed cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse cursor time")
+	}
+	return &entity.MessageCursor{
+		CreatedAt: &createdAt,
+		ID:        parts[1],
+	}, nil
+}
@@ -0,0 +1,226 @@
+package request
+
+import (
+	"app/api/constants"
+	"app/api/domain/entity"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SearchThreadsRequest tagはカンマ区切りか複数指定。active_withinは"24h"のような期間
+type SearchThreadsRequest struct {
+	Keywords     []string
+	TagIDs       []string
+	CategoryID   string
+	IsPublic     *int
+	HasVacancy   bool
+	ActiveWithin time.Duration
+	Sort         string
+	Offset       int
+	Limit        int
+}
+
+func NewSearchThreadsRequest(query url.Values) (*SearchThreadsRequest, error) {
+	req := &SearchThreadsRequest{
+		Keywords:   strings.Fields(query.Get("q")),
+		CategoryID: query.Get("category"),
+		Sort:       query.Get("sort"),
+		Limit:      constants.ThreadPageSize,
+	}
+	for _, tags := range query["tag"] {
+		for _, tagID := range strings.Split(tags, ",") {
+			if tagID = strings.TrimSpace(tagID); tagID != "" {
+				req.TagIDs = append(req.TagIDs, tagID)
+			}
+		}
+	}
+	var err error
+	if isPublic := query.Get("is_public"); isPublic != "" {
+		b, err := strconv.ParseBool(isPublic)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid is_public")
+		}
+		v := 0
+		if b {
+			v = 1
+		}
+		req.IsPublic = &v
+	}
+	if vacancy := query.Get("has_vacancy"); vacancy != "" {
+		if req.HasVacancy, err = strconv.ParseBool(vacancy); err != nil {
+			return nil, errors.Wrap(err, "invalid has_vacancy")
+		}
+	}
+	if within := query.Get("active_within"); within != "" {
+		if req.ActiveWithin, err = time.ParseDuration(within); err != nil {
+			return nil, errors.Wrap(err, "invalid active_within")
+		}
+	}
+	if offset := query.Get("offset"); offset != "" {
+		if req.Offset, err = strconv.Atoi(offset); err != nil {
+			return nil, errors.Wrap(err, "invalid offset")
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if req.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, errors.Wrap(err, "invalid limit")
+		}
+	}
+	return req, nil
+}
+
+func (r *SearchThreadsRequest) Validation() error {
+	switch r.Sort {
+	case "":
+		r.Sort = entity.ThreadSortCreated
+	case entity.ThreadSortCreated, entity.ThreadSortActive, entity.ThreadSortMembers, entity.ThreadSortName:
+	default:
+		return errors.New("unknown sort")
+	}
+	if r.ActiveWithin < 0 {
+		return errors.New("active_within must be positive")
+	}
+	if r.Offset < 0 {
+		return errors.New("offset must not be negative")
+	}
+	if r.Limit < 1 {
+		return errors.New("limit must be positive")
+	}
+	if r.Limit > constants.ThreadPageSizeMax {
+		r.Limit = constants.ThreadPageSizeMax
+	}
+	return nil
+}
+
+func (r *SearchThreadsRequest) ToQuery() *entity.ThreadSearchQuery {
+	query := &entity.ThreadSearchQuery{
+		Keywords:   r.Keywords,
+		TagIDs:     r.TagIDs,
+		CategoryID: r.CategoryID,
+		IsPublic:   r.IsPublic,
+		HasVacancy: r.HasVacancy,
+		Sort:       r.Sort,
+		Offset:     r.Offset,
+		Limit:      r.Limit,
+	}
+	if r.ActiveWithin > 0 {
+		since := time.Now().Add(-r.ActiveWithin)
+		query.ActiveSince = &since
+	}
+	return query
+}
+
+type GetRecommendationsRequest struct {
+	Limit int
+}
+
+func NewGetRecommendationsRequest(query url.Values) (*GetRecommendationsRequest, error) {
+	req := &GetRecommendationsRequest{Limit: constants.ThreadPageSize}
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		if req.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, errors.Wrap(err, "invalid limit")
+		}
+	}
+	return req, nil
+}
+
+func (r *GetRecommendationsRequest) Validation() error {
+	if r.Limit < 1 {
+		return errors.New("limit must be positive")
+	}
+	if r.Limit > constants.ThreadPageSizeMax {
+		r.Limit = constants.ThreadPageSizeMax
+	}
+	return nil
+}
+
+// SearchMessagesRequest since, untilはRFC3339。beforeはレスポンスのnext_cursorをそのまま渡す
+type SearchMessagesRequest struct {
+	Terms    []string
+	ThreadID string
+	Author   string
+	Since    *time.Time
+	Until    *time.Time
+	HasFile  *bool
+	Before   *entity.MessageCursor
+	Limit    int
+}
+
+func NewSearchMessagesRequest(query url.Values) (*SearchMessagesRequest, error) {
+	req := &SearchMessagesRequest{
+		Terms:    strings.Fields(query.Get("q")),
+		ThreadID: query.Get("thread"),
+		Author:   query.Get("author"),
+		Limit:    constants.MessagePageSize,
+	}
+	var err error
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid since")
+		}
+		req.Since = &t
+	}
+	if until := query.Get("until"); until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid until")
+		}
+		req.Until = &t
+	}
+	if hasFile := query.Get("has_file"); hasFile != "" {
+		b, err := strconv.ParseBool(hasFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid has_file")
+		}
+		req.HasFile = &b
+	}
+	if before := query.Get("before"); before != "" {
+		if req.Before, err = DecodeMessageCursor(before); err != nil {
+			return nil, errors.Wrap(err, "invalid before")
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if req.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, errors.Wrap(err, "invalid limit")
+		}
+	}
+	return req, nil
+}
+
+func (r *SearchMessagesRequest) Validation() error {
+	if len(r.Terms) == 0 {
+		return errors.New("q is empty")
+	}
+	if r.Since != nil && r.Until != nil && !r.Since.Before(*r.Until) {
+		return errors.New("since must be before until")
+	}
+	if r.Limit < 1 {
+		return errors.New("limit must be positive")
+	}
+	if r.Limit > constants.MessagePageSizeMax {
+		r.Limit = constants.MessagePageSizeMax
+	}
+	return nil
+}
+
+func (r *SearchMessagesRequest) ToQuery() *entity.MessageSearchQuery {
+	query := &entity.MessageSearchQuery{
+		Terms:    r.Terms,
+		AuthorID: r.Author,
+		Since:    r.Since,
+		Until:    r.Until,
+		HasFile:  r.HasFile,
+		Before:   r.Before,
+		Limit:    r.Limit,
+	}
+	if r.ThreadID != "" {
+		query.ThreadIDs = []string{r.ThreadID}
+	}
+	return query
+}
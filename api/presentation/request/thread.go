@@ -2,15 +2,22 @@ package request
 
 import (
 	"app/api/application/interactor"
+	"app/api/domain/entity"
+	"net/url"
+	"strconv"
+	"time"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
 )
 
+// CreateThreadRequest join_policyを省略すると公開スレッドはopen、非公開スレッドはinvite_onlyになる
 type CreateThreadRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	LimitUsers  int    `json:"limit_users"`
 	IsPublic    int    `json:"is_public"`
+	JoinPolicy  string `json:"join_policy"`
 }
 
 func (r *CreateThreadRequest) Validation() error {
@@ -23,7 +30,7 @@ func (r *CreateThreadRequest) Validation() error {
 	if r.LimitUsers < 0 {
 		return errors.New("limit_users allow cant minas")
 	}
-	return nil
+	return validateJoinPolicy(&r.JoinPolicy, r.IsPublic)
 }
 
 type UpdateThreadRequest struct {
@@ -31,6 +38,7 @@ type UpdateThreadRequest struct {
 	Description string `json:"description"`
 	LimitUsers  int    `json:"limit_users"`
 	IsPublic    int    `json:"is_public"`
+	JoinPolicy  string `json:"join_policy"`
 }
 
 func (r *UpdateThreadRequest) Validation(ti interactor.ThreadInteractor, threadID, requestUserID string) error {
@@ -41,17 +49,136 @@ func (r *UpdateThreadRequest) Validation(ti interactor.ThreadInteractor, threadI
 		return errors.New("is_public allow 0 or 1")
 	}
 
-	if r.LimitUsers < 1 {
-		return errors.New("limit_users allow more 1")
+	if r.LimitUsers < 0 {
+		return errors.New("limit_users allow cant minas")
+	}
+	if err := validateJoinPolicy(&r.JoinPolicy, r.IsPublic); err != nil {
+		return err
 	}
 
-	thread, err := ti.GetByID(threadID)
-	if err != nil {
+	if _, err := ti.GetByID(threadID); err != nil {
 		return errors.New("thread is not found")
 	}
-	if thread.Author.UserID != requestUserID {
-		return errors.New("no authorized")
+	if err := ti.CheckPermission(threadID, requestUserID, entity.PermissionEditThread); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateJoinPolicy 空なら公開範囲に合わせた既定値を入れる。非公開スレッドはopenにできない
+func validateJoinPolicy(joinPolicy *string, isPublic int) error {
+	if *joinPolicy == "" {
+		*joinPolicy = string(entity.JoinPolicyOpen)
+		if isPublic == 0 {
+			*joinPolicy = string(entity.JoinPolicyInviteOnly)
+		}
+	}
+	policy := entity.JoinPolicy(*joinPolicy)
+	if !policy.IsValid() {
+		return errors.New("join_policy allow open, request or invite_only")
+	}
+	if isPublic == 0 && policy == entity.JoinPolicyOpen {
+		return errors.New("private thread cannot be open")
+	}
+	return nil
+}
+
+// ForceToLeaveRequest banがfalseならキックのみで、再参加できる
+type ForceToLeaveRequest struct {
+	Ban       bool
+	Reason    string
+	ExpiresAt *time.Time
+}
+
+func NewForceToLeaveRequest(query url.Values) (*ForceToLeaveRequest, error) {
+	req := &ForceToLeaveRequest{Reason: query.Get("reason")}
+	var err error
+	if ban := query.Get("ban"); ban != "" {
+		if req.Ban, err = strconv.ParseBool(ban); err != nil {
+			return nil, errors.Wrap(err, "invalid ban")
+		}
+	}
+	if expiresAt := query.Get("expires_at"); expiresAt != "" {
+		t, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid expires_at")
+		}
+		req.ExpiresAt = &t
+	}
+	return req, nil
+}
+
+func (r *ForceToLeaveRequest) Validation() error {
+	if utf8.RuneCountInString(r.Reason) > 150 {
+		return errors.New("reason is too long")
+	}
+	if r.ExpiresAt != nil {
+		if !r.Ban {
+			return errors.New("expires_at requires ban")
+		}
+		if !r.ExpiresAt.After(time.Now()) {
+			return errors.New("expires_at must be in the future")
+		}
+	}
+	return nil
+}
+
+type ChangeRoleRequest struct {
+	Role string `json:"role"`
+}
+
+func (r *ChangeRoleRequest) Validation() error {
+	if r.Role == "" {
+		return errors.New("required filed is empty")
+	}
+	if !entity.Role(r.Role).IsValid() {
+		return errors.New("role allow owner, moderator, member or read_only")
 	}
+	return nil
+}
 
+// TransferOwnershipRequest user_idはスレッドのメンバーに限る
+type TransferOwnershipRequest struct {
+	UserID string `json:"user_id"`
+}
+
+func (r *TransferOwnershipRequest) Validation() error {
+	if r.UserID == "" {
+		return errors.New("required filed is empty")
+	}
 	return nil
 }
+
+// DecideJoinRequestRequest statusはapprovedかrejected
+type DecideJoinRequestRequest struct {
+	Status string `json:"status"`
+}
+
+func (r *DecideJoinRequestRequest) Validation() error {
+	if r.Status != string(entity.JoinRequestApproved) && r.Status != string(entity.JoinRequestRejected) {
+		return errors.New("status allow approved or rejected")
+	}
+	return nil
+}
+
+// GetJoinRequestsRequest statusを省略するとpending、空にするとすべての申請を返す
+type GetJoinRequestsRequest struct {
+	Status string
+}
+
+func NewGetJoinRequestsRequest(query url.Values) (*GetJoinRequestsRequest, error) {
+	req := &GetJoinRequestsRequest{Status: string(entity.JoinRequestPending)}
+	if status, ok := query["status"]; ok {
+		req.Status = status[0]
+	}
+	return req, nil
+}
+
+func (r *GetJoinRequestsRequest) Validation() error {
+	switch entity.JoinRequestStatus(r.Status) {
+	case "", entity.JoinRequestPending, entity.JoinRequestApproved, entity.JoinRequestRejected:
+		return nil
+	}
+	return errors.New("status allow pending, approved or rejected")
+}
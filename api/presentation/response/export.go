@@ -0,0 +1,42 @@
+package response
+
+import (
+	"app/api/domain/entity"
+	"time"
+)
+
+type ExportResponse struct {
+	ID          string        `json:"id"`
+	ThreadID    string        `json:"thread_id"`
+	IsPublic    int           `json:"is_public"`
+	IsProtected bool          `json:"is_protected"`
+	Status      string        `json:"status"`
+	CreatedBy   *UserResponse `json:"created_by"`
+	CreatedAt   *time.Time    `json:"created_at"`
+}
+
+type ExportsResponse struct {
+	Exports []*ExportResponse `json:"exports"`
+}
+
+func ConvertToExportResponse(export *entity.Export) *ExportResponse {
+	return &ExportResponse{
+		ID:          export.ID,
+		ThreadID:    export.Thread.ID,
+		IsPublic:    export.IsPublic,
+		IsProtected: export.IsProtected(),
+		Status:      string(export.Status),
+		CreatedBy:   ConvertToUserResponse(export.CreatedBy),
+		CreatedAt:   export.CreatedAt,
+	}
+}
+
+func ConvertToExportsResponse(exports []*entity.Export) *ExportsResponse {
+	res := make([]*ExportResponse, 0, len(exports))
+	for _, export := range exports {
+		res = append(res, ConvertToExportResponse(export))
+	}
+	return &ExportsResponse{
+		Exports: res,
+	}
+}
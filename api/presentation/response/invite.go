@@ -0,0 +1,66 @@
+package response
+
+import (
+	"app/api/domain/entity"
+	"time"
+)
+
+type InviteUseResponse struct {
+	User      *UserResponse `json:"user"`
+	CreatedAt *time.Time    `json:"created_at"`
+}
+
+type InviteResponse struct {
+	ID        string               `json:"id"`
+	Code      string               `json:"code"`
+	Thread    *ThreadResponse      `json:"thread"`
+	CreatedBy *UserResponse        `json:"created_by"`
+	Invitee   *UserResponse        `json:"invitee"`
+	MaxUses   int                  `json:"max_uses"`
+	Uses      int                  `json:"uses"`
+	ExpiresAt *time.Time           `json:"expires_at"`
+	RevokedAt *time.Time           `json:"revoked_at"`
+	CreatedAt *time.Time           `json:"created_at"`
+	UsedBy    []*InviteUseResponse `json:"used_by,omitempty"`
+}
+
+type InvitesResponse struct {
+	Invites []*InviteResponse `json:"invites"`
+}
+
+func ConvertToInviteResponse(invite *entity.ThreadInvite) *InviteResponse {
+	var invitee *UserResponse
+	if invite.Invitee != nil {
+		invitee = ConvertToUserResponse(invite.Invitee)
+	}
+	var usedBy []*InviteUseResponse
+	for _, use := range invite.UsedBy {
+		usedBy = append(usedBy, &InviteUseResponse{
+			User:      ConvertToUserResponse(use.User),
+			CreatedAt: use.CreatedAt,
+		})
+	}
+	return &InviteResponse{
+		ID:        invite.ID,
+		Code:      invite.Code,
+		Thread:    ConvertToThreadResponse(invite.Thread),
+		CreatedBy: ConvertToUserResponse(invite.CreatedBy),
+		Invitee:   invitee,
+		MaxUses:   invite.MaxUses,
+		Uses:      invite.Uses,
+		ExpiresAt: invite.ExpiresAt,
+		RevokedAt: invite.RevokedAt,
+		CreatedAt: invite.CreatedAt,
+		UsedBy:    usedBy,
+	}
+}
+
+func ConvertToInvitesResponse(invites []*entity.ThreadInvite) *InvitesResponse {
+	res := make([]*InviteResponse, 0, len(invites))
+	for _, invite := range invites {
+		res = append(res, ConvertToInviteResponse(invite))
+	}
+	return &InvitesResponse{
+		Invites: res,
+	}
+}
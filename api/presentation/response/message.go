@@ -2,15 +2,36 @@ package response
 
 import (
 	"app/api/domain/entity"
+	"encoding/base64"
 	"time"
 )
 
 type MessageResponse struct {
-	ID        string        `json:"id"`
-	Message   string        `json:"message"`
-	Grade     int           `json:"grade"`
-	CreatedAt *time.Time    `json:"created_at"`
-	Author    *UserResponse `json:"author"`
+	ID         string                 `json:"id"`
+	Message    string                 `json:"message"`
+	Grade      int                    `json:"grade"`
+	CreatedAt  *time.Time             `json:"created_at"`
+	EditedAt   *time.Time             `json:"edited_at"`
+	DeletedAt  *time.Time             `json:"deleted_at"`
+	Author     *UserResponse          `json:"author"`
+	Parent     *QuotedMessageResponse `json:"parent"`
+	ReplyCount int                    `json:"reply_count"`
+	Reactions  []*ReactionResponse    `json:"reactions"`
+}
+
+// ReactionResponse reactedはリクエストしたユーザがリアクションしているか
+type ReactionResponse struct {
+	Reaction string `json:"reaction"`
+	Count    int    `json:"count"`
+	Reacted  bool   `json:"reacted"`
+}
+
+// QuotedMessageResponse 返信元の引用
+type QuotedMessageResponse struct {
+	ID        string     `json:"id"`
+	Message   string     `json:"message"`
+	AuthorID  string     `json:"author_id"`
+	DeletedAt *time.Time `json:"deleted_at"`
 }
 
 type MessagesResponse struct {
@@ -18,12 +39,51 @@ type MessagesResponse struct {
 }
 
 func ConvertToMessageResponse(msg *entity.Message) *MessageResponse {
-	return &MessageResponse{
-		ID:        msg.ID,
-		Message:   msg.Message,
-		Grade:     msg.Grade,
-		CreatedAt: msg.CreatedAt,
-		Author:    ConvertToUserResponse(msg.Author),
+	res := &MessageResponse{
+		ID:         msg.ID,
+		Message:    msg.Message,
+		Grade:      msg.Grade,
+		CreatedAt:  msg.CreatedAt,
+		EditedAt:   msg.EditedAt,
+		DeletedAt:  msg.DeletedAt,
+		Author:     ConvertToUserResponse(msg.Author),
+		ReplyCount: msg.ReplyCount,
+		Reactions:  ConvertToReactionsResponse(msg.Reactions),
+	}
+	if msg.Parent != nil {
+		res.Parent = &QuotedMessageResponse{
+			ID:        msg.Parent.ID,
+			Message:   msg.Parent.Message,
+			AuthorID:  msg.Parent.Author.ID,
+			DeletedAt: msg.Parent.DeletedAt,
+		}
+	}
+	return res
+}
+
+func ConvertToReactionsResponse(reactions []*entity.Reaction) []*ReactionResponse {
+	result := make([]*ReactionResponse, 0, len(reactions))
+	for _, reaction := range reactions {
+		result = append(result, &ReactionResponse{
+			Reaction: reaction.Reaction,
+			Count:    reaction.Count,
+			Reacted:  reaction.Reacted,
+		})
+	}
+	return result
+}
+
+type ReplyChainResponse struct {
+	Ancestors []*MessageResponse `json:"ancestors"`
+	Message   *MessageResponse   `json:"message"`
+	Replies   []*MessageResponse `json:"replies"`
+}
+
+func ConvertToReplyChainResponse(chain *entity.ReplyChain) *ReplyChainResponse {
+	return &ReplyChainResponse{
+		Ancestors: ConvertToMessagesResponse(chain.Ancestors).Messages,
+		Message:   ConvertToMessageResponse(chain.Message),
+		Replies:   ConvertToMessagesResponse(chain.Replies).Messages,
 	}
 }
 
@@ -36,3 +96,163 @@ func ConvertToMessagesResponse(messages []*entity.Message) *MessagesResponse {
 		Messages: result,
 	}
 }
+
+type MessagePageResponse struct {
+	Messages   []*MessageResponse `json:"messages"`
+	PrevCursor string             `json:"prev_cursor"`
+	NextCursor string             `json:"next_cursor"`
+}
+
+func ConvertToMessagePageResponse(page *entity.MessagePage) *MessagePageResponse {
+	return &MessagePageResponse{
+		Messages:   ConvertToMessagesResponse(page.Messages).Messages,
+		PrevCursor: EncodeMessageCursor(page.Prev),
+		NextCursor: EncodeMessageCursor(page.Next),
+	}
+}
+
+// EncodeMessageCursor request.DecodeMessageCursorで読める形にする。cursorがnilなら空文字
+func EncodeMessageCursor(cursor *entity.MessageCursor) string {
+	if cursor == nil {
+		return ""
+	}
+	raw := cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "_" + cursor.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+type PinResponse struct {
+	ID        string           `json:"id"`
+	Message   *MessageResponse `json:"message"`
+	PinnedBy  *UserResponse    `json:"pinned_by"`
+	CreatedAt *time.Time       `json:"created_at"`
+}
+
+type PinsResponse struct {
+	Pins []*PinResponse `json:"pins"`
+}
+
+func ConvertToPinsResponse(pins []*entity.Pin) *PinsResponse {
+	return &PinsResponse{
+		Pins: ConvertToPinResponses(pins),
+	}
+}
+
+func ConvertToPinResponses(pins []*entity.Pin) []*PinResponse {
+	result := make([]*PinResponse, 0, len(pins))
+	for _, pin := range pins {
+		result = append(result, &PinResponse{
+			ID:        pin.ID,
+			Message:   ConvertToMessageResponse(pin.Message),
+			PinnedBy:  ConvertToUserResponse(pin.PinnedBy),
+			CreatedAt: pin.CreatedAt,
+		})
+	}
+	return result
+}
+
+// MentionResponse メンションされたメッセージにスレッドIDを付けたもの
+type MentionResponse struct {
+	*MessageResponse
+	ThreadID string `json:"thread_id"`
+}
+
+type MentionsResponse struct {
+	Mentions   []*MentionResponse `json:"mentions"`
+	NextCursor string             `json:"next_cursor"`
+}
+
+func ConvertToMentionsResponse(messages []*entity.Message, next *entity.MessageCursor) *MentionsResponse {
+	result := make([]*MentionResponse, 0, len(messages))
+	for _, message := range messages {
+		result = append(result, &MentionResponse{
+			MessageResponse: ConvertToMessageResponse(message),
+			ThreadID:        message.Thread.ID,
+		})
+	}
+	return &MentionsResponse{
+		Mentions:   result,
+		NextCursor: EncodeMessageCursor(next),
+	}
+}
+
+type MessageRevisionResponse struct {
+	Message  string     `json:"message"`
+	EditedAt *time.Time `json:"edited_at"`
+}
+
+type MessageRevisionsResponse struct {
+	Revisions []*MessageRevisionResponse `json:"revisions"`
+}
+
+func ConvertToMessageRevisionsResponse(revisions []*entity.MessageRevision) *MessageRevisionsResponse {
+	result := make([]*MessageRevisionResponse, 0, len(revisions))
+	for _, revision := range revisions {
+		result = append(result, &MessageRevisionResponse{
+			Message:  revision.Body,
+			EditedAt: revision.EditedAt,
+		})
+	}
+	return &MessageRevisionsResponse{
+		Revisions: result,
+	}
+}
+
+type MessageDeletionResponse struct {
+	ID        string        `json:"id"`
+	MessageID string        `json:"message_id"`
+	Author    *UserResponse `json:"author"`
+	DeletedBy *UserResponse `json:"deleted_by"`
+	Reason    string        `json:"reason"`
+	CreatedAt *time.Time    `json:"created_at"`
+}
+
+type MessageDeletionsResponse struct {
+	Deletions []*MessageDeletionResponse `json:"deletions"`
+}
+
+func ConvertToMessageDeletionsResponse(deletions []*entity.MessageDeletion) *MessageDeletionsResponse {
+	result := make([]*MessageDeletionResponse, 0, len(deletions))
+	for _, deletion := range deletions {
+		result = append(result, &MessageDeletionResponse{
+			ID:        deletion.ID,
+			MessageID: deletion.Message.ID,
+			Author:    ConvertToUserResponse(deletion.Author),
+			DeletedBy: ConvertToUserResponse(deletion.DeletedBy),
+			Reason:    deletion.Reason,
+			CreatedAt: deletion.CreatedAt,
+		})
+	}
+	return &MessageDeletionsResponse{
+		Deletions: result,
+	}
+}
+
+type ReadMarkerResponse struct {
+	User      *UserResponse `json:"user"`
+	ThreadID  string        `json:"thread_id"`
+	MessageID string        `json:"message_id"`
+	ReadAt    *time.Time    `json:"read_at"`
+}
+
+type ReadMarkersResponse struct {
+	ReadMarkers []*ReadMarkerResponse `json:"read_markers"`
+}
+
+func ConvertToReadMarkerResponse(marker *entity.ReadMarker) *ReadMarkerResponse {
+	return &ReadMarkerResponse{
+		User:      ConvertToUserResponse(marker.User),
+		ThreadID:  marker.Thread.ID,
+		MessageID: marker.Message.ID,
+		ReadAt:    marker.ReadAt,
+	}
+}
+
+func ConvertToReadMarkersResponse(markers []*entity.ReadMarker) *ReadMarkersResponse {
+	result := make([]*ReadMarkerResponse, 0, len(markers))
+	for _, marker := range markers {
+		result = append(result, ConvertToReadMarkerResponse(marker))
+	}
+	return &ReadMarkersResponse{
+		ReadMarkers: result,
+	}
+}
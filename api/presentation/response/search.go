@@ -0,0 +1,58 @@
+package response
+
+import (
+	"app/api/domain/entity"
+	"time"
+)
+
+type MessageSearchHitResponse struct {
+	Message  *MessageResponse `json:"message"`
+	ThreadID string           `json:"thread_id"`
+	Snippet  string           `json:"snippet"`
+}
+
+type MessageSearchResponse struct {
+	Hits       []*MessageSearchHitResponse `json:"hits"`
+	NextCursor string                      `json:"next_cursor"`
+}
+
+func ConvertToMessageSearchResponse(result *entity.MessageSearchResult) *MessageSearchResponse {
+	hits := make([]*MessageSearchHitResponse, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		hits = append(hits, &MessageSearchHitResponse{
+			Message:  ConvertToMessageResponse(hit.Message),
+			ThreadID: hit.Message.Thread.ID,
+			Snippet:  hit.Snippet,
+		})
+	}
+	return &MessageSearchResponse{
+		Hits:       hits,
+		NextCursor: EncodeMessageCursor(result.Next),
+	}
+}
+
+type ThreadSearchHitResponse struct {
+	*ThreadResponse
+	MemberCount  int        `json:"member_count"`
+	LastActiveAt *time.Time `json:"last_active_at"`
+}
+
+type ThreadSearchResponse struct {
+	Threads []*ThreadSearchHitResponse `json:"threads"`
+	HasNext bool                       `json:"has_next"`
+}
+
+func ConvertToThreadSearchResponse(result *entity.ThreadSearchResult) *ThreadSearchResponse {
+	threads := make([]*ThreadSearchHitResponse, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		threads = append(threads, &ThreadSearchHitResponse{
+			ThreadResponse: ConvertToThreadResponse(hit.Thread),
+			MemberCount:    hit.MemberCount,
+			LastActiveAt:   hit.LastActiveAt,
+		})
+	}
+	return &ThreadSearchResponse{
+		Threads: threads,
+		HasNext: result.HasNext,
+	}
+}
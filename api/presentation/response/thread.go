@@ -6,15 +6,19 @@ import (
 )
 
 type ThreadResponse struct {
-	ID          string         `json:"id"`
-	Name        string         `json:"name"`
-	Descirption string         `json:"description"`
-	LimitUsers  int            `json:"limit_users"`
-	IsPublic    int            `json:"is_public"`
-	CreatedAt   *time.Time     `json:"created_at"`
-	UpdatedAt   *time.Time     `json:"updated_at"`
-	Author      *UserResponse  `json:"author"`
-	Tags        []*TagResponse `json:"tags"`
+	ID            string         `json:"id"`
+	Name          string         `json:"name"`
+	Descirption   string         `json:"description"`
+	LimitUsers    int            `json:"limit_users"`
+	IsPublic      int            `json:"is_public"`
+	JoinPolicy    string         `json:"join_policy"`
+	ArchivedAt    *time.Time     `json:"archived_at"`
+	MemberCount   int            `json:"member_count"`
+	WaitlistCount int            `json:"waitlist_count"`
+	CreatedAt     *time.Time     `json:"created_at"`
+	UpdatedAt     *time.Time     `json:"updated_at"`
+	Author        *UserResponse  `json:"author"`
+	Tags          []*TagResponse `json:"tags"`
 }
 
 type ThreadsResponse struct {
@@ -23,15 +27,19 @@ type ThreadsResponse struct {
 
 func ConvertToThreadResponse(thread *entity.Thread) *ThreadResponse {
 	return &ThreadResponse{
-		ID:          thread.ID,
-		Name:        thread.Name,
-		Descirption: thread.Description,
-		LimitUsers:  thread.LimitUsers,
-		IsPublic:    thread.IsPublic,
-		CreatedAt:   thread.CreatedAt,
-		UpdatedAt:   thread.UpdatedAt,
-		Author:      ConvertToUserResponse(thread.Author),
-		Tags:        ConvertToTagsResponse(thread.Tags).Tags,
+		ID:            thread.ID,
+		Name:          thread.Name,
+		Descirption:   thread.Description,
+		LimitUsers:    thread.LimitUsers,
+		IsPublic:      thread.IsPublic,
+		JoinPolicy:    string(thread.JoinPolicy),
+		ArchivedAt:    thread.ArchivedAt,
+		MemberCount:   thread.MemberCount,
+		WaitlistCount: thread.WaitlistCount,
+		CreatedAt:     thread.CreatedAt,
+		UpdatedAt:     thread.UpdatedAt,
+		Author:        ConvertToUserResponse(thread.Author),
+		Tags:          ConvertToTagsResponse(thread.Tags).Tags,
 	}
 }
 
@@ -44,3 +52,196 @@ func ConvertToThreadsResponse(threads []*entity.Thread) *ThreadsResponse {
 		Threads: res,
 	}
 }
+
+// ThreadDetailResponse GET /threads/{id}のレスポンス。ピン留めの本文は/threads/{threadID}/pinsで取得する
+type ThreadDetailResponse struct {
+	*ThreadResponse
+	PinnedMessageIDs []string `json:"pinned_message_ids"`
+}
+
+func ConvertToThreadDetailResponse(thread *entity.Thread, pins []*entity.Pin) *ThreadDetailResponse {
+	ids := make([]string, 0, len(pins))
+	for _, pin := range pins {
+		ids = append(ids, pin.Message.ID)
+	}
+	return &ThreadDetailResponse{
+		ThreadResponse:   ConvertToThreadResponse(thread),
+		PinnedMessageIDs: ids,
+	}
+}
+
+// UserThreadResponse 参加しているスレッドに既読情報を付けたもの
+type UserThreadResponse struct {
+	*ThreadResponse
+	UnreadCount       int    `json:"unread_count"`
+	LastReadMessageID string `json:"last_read_message_id"`
+}
+
+type UserThreadsResponse struct {
+	Threads []*UserThreadResponse `json:"threads"`
+}
+
+// ConvertToUserThreadsResponse unreadsはスレッドIDをkeyにした未読数
+func ConvertToUserThreadsResponse(threads []*entity.Thread, unreads map[string]*entity.UnreadCount) *UserThreadsResponse {
+	res := make([]*UserThreadResponse, 0, len(threads))
+	for _, thread := range threads {
+		userThread := &UserThreadResponse{
+			ThreadResponse: ConvertToThreadResponse(thread),
+		}
+		if unread, ok := unreads[thread.ID]; ok {
+			userThread.UnreadCount = unread.Count
+			userThread.LastReadMessageID = unread.LastReadMessageID
+		}
+		res = append(res, userThread)
+	}
+	return &UserThreadsResponse{
+		Threads: res,
+	}
+}
+
+// ThreadRecommendationResponse reasonはおすすめした理由
+type ThreadRecommendationResponse struct {
+	*ThreadResponse
+	Score  int                           `json:"score"`
+	Reason *RecommendationReasonResponse `json:"reason"`
+}
+
+type RecommendationReasonResponse struct {
+	MatchedTags       []*TagResponse      `json:"matched_tags"`
+	MatchedCategories []*CategoryResponse `json:"matched_categories"`
+	FollowedActive    int                 `json:"followed_active"`
+}
+
+type ThreadRecommendationsResponse struct {
+	Threads []*ThreadRecommendationResponse `json:"threads"`
+}
+
+func ConvertToThreadRecommendationsResponse(recommendations []*entity.ThreadRecommendation) *ThreadRecommendationsResponse {
+	res := make([]*ThreadRecommendationResponse, 0, len(recommendations))
+	for _, rec := range recommendations {
+		categories := make([]*CategoryResponse, 0, len(rec.MatchedCategories))
+		for _, category := range rec.MatchedCategories {
+			categories = append(categories, ConvertToCategoryResponse(category))
+		}
+		res = append(res, &ThreadRecommendationResponse{
+			ThreadResponse: ConvertToThreadResponse(rec.Thread),
+			Score:          rec.Score,
+			Reason: &RecommendationReasonResponse{
+				MatchedTags:       ConvertToTagsResponse(rec.MatchedTags).Tags,
+				MatchedCategories: categories,
+				FollowedActive:    rec.FollowedActive,
+			},
+		})
+	}
+	return &ThreadRecommendationsResponse{
+		Threads: res,
+	}
+}
+
+type ThreadBanResponse struct {
+	ID        string        `json:"id"`
+	User      *UserResponse `json:"user"`
+	BannedBy  *UserResponse `json:"banned_by"`
+	Reason    string        `json:"reason"`
+	ExpiresAt *time.Time    `json:"expires_at"`
+	CreatedAt *time.Time    `json:"created_at"`
+}
+
+type ThreadBansResponse struct {
+	Bans []*ThreadBanResponse `json:"bans"`
+}
+
+func ConvertToThreadBansResponse(bans []*entity.ThreadBan) *ThreadBansResponse {
+	result := make([]*ThreadBanResponse, 0, len(bans))
+	for _, ban := range bans {
+		result = append(result, &ThreadBanResponse{
+			ID:        ban.ID,
+			User:      ConvertToUserResponse(ban.User),
+			BannedBy:  ConvertToUserResponse(ban.BannedBy),
+			Reason:    ban.Reason,
+			ExpiresAt: ban.ExpiresAt,
+			CreatedAt: ban.CreatedAt,
+		})
+	}
+	return &ThreadBansResponse{
+		Bans: result,
+	}
+}
+
+type JoinRequestResponse struct {
+	ID        string                 `json:"id"`
+	Thread    *ThreadResponse        `json:"thread"`
+	User      *UserResponse          `json:"user"`
+	Status    string                 `json:"status"`
+	DecidedBy *UserResponse          `json:"decided_by"`
+	DecidedAt *time.Time             `json:"decided_at"`
+	CreatedAt *time.Time             `json:"created_at"`
+	Waitlist  *WaitlistEntryResponse `json:"waitlist,omitempty"`
+}
+
+type JoinRequestsResponse struct {
+	Requests []*JoinRequestResponse `json:"requests"`
+}
+
+func ConvertToJoinRequestResponse(request *entity.JoinRequest) *JoinRequestResponse {
+	var decidedBy *UserResponse
+	if request.DecidedBy != nil {
+		decidedBy = ConvertToUserResponse(request.DecidedBy)
+	}
+	var waitlist *WaitlistEntryResponse
+	if request.Waitlist != nil {
+		waitlist = ConvertToWaitlistEntryResponse(request.Waitlist)
+	}
+	return &JoinRequestResponse{
+		ID:        request.ID,
+		Thread:    ConvertToThreadResponse(request.Thread),
+		User:      ConvertToUserResponse(request.User),
+		Status:    string(request.Status),
+		DecidedBy: decidedBy,
+		DecidedAt: request.DecidedAt,
+		CreatedAt: request.CreatedAt,
+		Waitlist:  waitlist,
+	}
+}
+
+func ConvertToJoinRequestsResponse(requests []*entity.JoinRequest) *JoinRequestsResponse {
+	res := make([]*JoinRequestResponse, 0, len(requests))
+	for _, request := range requests {
+		res = append(res, ConvertToJoinRequestResponse(request))
+	}
+	return &JoinRequestsResponse{
+		Requests: res,
+	}
+}
+
+type WaitlistEntryResponse struct {
+	ID        string        `json:"id"`
+	ThreadID  string        `json:"thread_id"`
+	User      *UserResponse `json:"user"`
+	Position  int           `json:"position"`
+	CreatedAt *time.Time    `json:"created_at"`
+}
+
+type WaitlistResponse struct {
+	Entries []*WaitlistEntryResponse `json:"entries"`
+}
+
+func ConvertToWaitlistEntryResponse(entry *entity.WaitlistEntry) *WaitlistEntryResponse {
+	return &WaitlistEntryResponse{
+		ID:        entry.ID,
+		ThreadID:  entry.Thread.ID,
+		User:      ConvertToUserResponse(entry.User),
+		Position:  entry.Position,
+		CreatedAt: entry.CreatedAt,
+	}
+}
+
+func ConvertToWaitlistResponse(entries []*entity.WaitlistEntry) *WaitlistResponse {
+	res := make([]*WaitlistEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		res = append(res, ConvertToWaitlistEntryResponse(entry))
+	}
+	return &WaitlistResponse{
+		Entries: res,
+	}
+}
@@ -23,6 +23,16 @@ type UsersResponse struct {
 	Users []*UserResponse `json:"users"`
 }
 
+type AccountDeletionResponse struct {
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+}
+
+func ConvertToAccountDeletionResponse(deletion *entity.AccountDeletion) *AccountDeletionResponse {
+	return &AccountDeletionResponse{
+		DeletionScheduledAt: deletion.ScheduledAt,
+	}
+}
+
 func ConvertToUserResponse(user *entity.User) *UserResponse {
 	return &UserResponse{
 		ID:        user.ID,
@@ -48,3 +58,60 @@ func ConvertToUsersResponse(users []*entity.User) *UsersResponse {
 		Users: res,
 	}
 }
+
+// MemberResponse スレッドのメンバー。usersと同じ形に役割を足したもの
+type MemberResponse struct {
+	*UserResponse
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+}
+
+type MembersResponse struct {
+	Users []*MemberResponse `json:"users"`
+}
+
+func ConvertToMemberResponse(user *entity.User, role entity.Role) *MemberResponse {
+	permissions := make([]string, 0, len(role.Permissions()))
+	for _, permission := range role.Permissions() {
+		permissions = append(permissions, string(permission))
+	}
+	return &MemberResponse{
+		UserResponse: ConvertToUserResponse(user),
+		Role:         string(role),
+		Permissions:  permissions,
+	}
+}
+
+// ConvertToMembersResponse rolesはuserのIDをkeyにした役割
+func ConvertToMembersResponse(users []*entity.User, roles map[string]entity.Role) *MembersResponse {
+	res := make([]*MemberResponse, 0, len(users))
+	for _, user := range users {
+		res = append(res, ConvertToMemberResponse(user, roles[user.ID]))
+	}
+	return &MembersResponse{
+		Users: res,
+	}
+}
+
+type PresenceResponse struct {
+	User   *UserResponse `json:"user"`
+	Status string        `json:"status"`
+}
+
+type PresencesResponse struct {
+	Presences []*PresenceResponse `json:"presences"`
+}
+
+// ConvertToPresencesResponse statusesはuserのIDをkeyにしたオンライン状態
+func ConvertToPresencesResponse(users []*entity.User, statuses map[string]string) *PresencesResponse {
+	res := make([]*PresenceResponse, 0, len(users))
+	for _, user := range users {
+		res = append(res, &PresenceResponse{
+			User:   ConvertToUserResponse(user),
+			Status: statuses[user.ID],
+		})
+	}
+	return &PresencesResponse{
+		Presences: res,
+	}
+}
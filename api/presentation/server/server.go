@@ -79,13 +79,19 @@ func (s *server) Route(appHandler *handler.AppHandler) {
 		authRouter.HandleFunc("/account/tags/{tagID}", appHandler.TagHandler.RemoveTagFromUser).Methods(http.MethodDelete, http.MethodOptions)
 
 		authRouter.HandleFunc("/account/threads", appHandler.ThreadHandler.GetByUserID).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/account/recommendations/threads", appHandler.ThreadHandler.GetRecommendations).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/account/mentions", appHandler.MessageHandler.GetMentions).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/account/invites", appHandler.InviteHandler.GetMine).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/account/join-requests", appHandler.ThreadHandler.GetMyJoinRequests).Methods(http.MethodGet, http.MethodOptions)
 
 		authRouter.HandleFunc("/users/{followedUUID}/follows", appHandler.UserHandler.Follow).Methods(http.MethodPost, http.MethodOptions)
 		authRouter.HandleFunc("/users/{followedUUID}/follows", appHandler.UserHandler.Unfollow).Methods(http.MethodDelete, http.MethodOptions)
 
 		authRouter.HandleFunc("/tags", appHandler.TagHandler.Create).Methods(http.MethodPost, http.MethodOptions)
 
-		// TODO: thread search
+		authRouter.HandleFunc("/search/threads", appHandler.SearchHandler.SearchThreads).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/search/messages", appHandler.SearchHandler.SearchMessages).Methods(http.MethodGet, http.MethodOptions)
+
 		authRouter.HandleFunc("/threads", appHandler.ThreadHandler.Create).Methods(http.MethodPost, http.MethodOptions)
 		authRouter.HandleFunc("/threads/{id}", appHandler.ThreadHandler.Update).Methods(http.MethodPut, http.MethodOptions)
 		authRouter.HandleFunc("/threads/{id}", appHandler.ThreadHandler.Delete).Methods(http.MethodDelete, http.MethodOptions)
@@ -94,12 +100,45 @@ func (s *server) Route(appHandler *handler.AppHandler) {
 
 		authRouter.HandleFunc("/threads/{id}/members", appHandler.ThreadHandler.Join).Methods(http.MethodPost, http.MethodOptions)
 		authRouter.HandleFunc("/threads/{id}/members", appHandler.ThreadHandler.Leave).Methods(http.MethodDelete, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/presence", appHandler.ThreadHandler.GetPresenceByThreadID).Methods(http.MethodGet, http.MethodOptions)
 
 		authRouter.HandleFunc("/threads/{id}/members/{userID}", appHandler.ThreadHandler.ForceToLeave).Methods(http.MethodDelete, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/members/{userID}/role", appHandler.ThreadHandler.ChangeRole).Methods(http.MethodPut, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/owner", appHandler.ThreadHandler.TransferOwnership).Methods(http.MethodPut, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/archives", appHandler.ThreadHandler.Archive).Methods(http.MethodPost, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/archives", appHandler.ThreadHandler.Restore).Methods(http.MethodDelete, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/exports", appHandler.ExportHandler.GetByThreadID).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/exports", appHandler.ExportHandler.Create).Methods(http.MethodPost, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/exports/{exportID}/download", appHandler.ExportHandler.Download).Methods(http.MethodPost, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/waitlist", appHandler.ThreadHandler.GetWaitlist).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/waitlist", appHandler.ThreadHandler.LeaveWaitlist).Methods(http.MethodDelete, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/join-requests", appHandler.ThreadHandler.GetJoinRequests).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/join-requests/{requestID}", appHandler.ThreadHandler.DecideJoinRequest).Methods(http.MethodPut, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/invites", appHandler.InviteHandler.GetByThreadID).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/invites", appHandler.InviteHandler.Create).Methods(http.MethodPost, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/invites/{inviteID}", appHandler.InviteHandler.Revoke).Methods(http.MethodDelete, http.MethodOptions)
+		authRouter.HandleFunc("/invites/{code}", appHandler.InviteHandler.GetByCode).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/invites/{code}", appHandler.InviteHandler.Join).Methods(http.MethodPost, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/bans", appHandler.ThreadHandler.GetBans).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{id}/bans/{userID}", appHandler.ThreadHandler.Unban).Methods(http.MethodDelete, http.MethodOptions)
 
 		authRouter.HandleFunc("/threads/{threadID}/messages", appHandler.MessageHandler.GetByThreadID).Methods(http.MethodGet, http.MethodOptions)
 		authRouter.HandleFunc("/threads/{threadID}/messages", appHandler.MessageHandler.Create).Methods(http.MethodPost, http.MethodOptions)
 		authRouter.HandleFunc("/threads/{threadID}/messages/{messageID}", appHandler.MessageHandler.AddFavorite).Methods(http.MethodPost, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{threadID}/messages/{messageID}", appHandler.MessageHandler.Update).Methods(http.MethodPut, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{threadID}/messages/{messageID}", appHandler.MessageHandler.Delete).Methods(http.MethodDelete, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{threadID}/messages/{messageID}/replies", appHandler.MessageHandler.GetReplies).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{threadID}/messages/{messageID}/revisions", appHandler.MessageHandler.GetRevisions).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{threadID}/messages/{messageID}/reactions", appHandler.MessageHandler.AddReaction).Methods(http.MethodPost, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{threadID}/messages/{messageID}/reactions/{reaction}", appHandler.MessageHandler.RemoveReaction).Methods(http.MethodDelete, http.MethodOptions)
+
+		authRouter.HandleFunc("/threads/{threadID}/pins", appHandler.MessageHandler.GetPins).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{threadID}/pins/{messageID}", appHandler.MessageHandler.Pin).Methods(http.MethodPut, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{threadID}/pins/{messageID}", appHandler.MessageHandler.Unpin).Methods(http.MethodDelete, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{threadID}/deletions", appHandler.MessageHandler.GetDeletions).Methods(http.MethodGet, http.MethodOptions)
+
+		authRouter.HandleFunc("/threads/{threadID}/reads", appHandler.MessageHandler.GetReadMarkers).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/threads/{threadID}/reads", appHandler.MessageHandler.MarkAsRead).Methods(http.MethodPut, http.MethodOptions)
 
 		authRouter.HandleFunc("/threads/{threadID}/files", appHandler.FileHandler.Upload).Methods(http.MethodPost, http.MethodOptions)
 		authRouter.HandleFunc("/threads/{threadID}/files/{fileID}", appHandler.FileHandler.Download).Methods(http.MethodGet, http.MethodOptions)
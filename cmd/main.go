@@ -16,6 +16,11 @@ func main() {
 	}
 
 	appHandler := handler.NewAppHandler(sqlHandler)
+	go appHandler.RunHub()
+
+	stopPurge := make(chan struct{})
+	defer close(stopPurge)
+	go appHandler.RunAccountPurge(constants.AccountPurgeInterval, stopPurge)
 
 	srv := server.New(fmt.Sprintf(":%s", constants.ServerPort))
 	srv.Route(appHandler)
@@ -12,7 +12,8 @@ CREATE TABLE IF NOT EXISTS `ls_chat`.`users`(
     `login_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'ログイン日時',
     `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
     `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '更新日時',
-    `password` VARCHAR(70) NOT NULL COMMENT 'パスワード'
+    `password` VARCHAR(70) NOT NULL COMMENT 'パスワード',
+    `deletion_scheduled_at` DATETIME COMMENT '削除予定日時。NULLでなければ削除待ち'
 )
 COMMENT = 'ユーザ';
 
@@ -23,6 +24,8 @@ CREATE TABLE IF NOT EXISTS `ls_chat`.`threads`(
     `limit_users` INTEGER COMMENT '上限人数',
     `user_id` VARCHAR(64) NOT NULL COMMENT '管理者',-- F
     `is_public` TINYINT NOT NULL DEFAULT 0 COMMENT '範囲',
+    `join_policy` VARCHAR(16) NOT NULL DEFAULT 'open' COMMENT '参加方針(open, request, invite_only)',
+    `archived_at` DATETIME COMMENT 'アーカイブした日時。NULLでなければ読み取り専用',
     `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
     `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP COMMENT '更新日時',
     CONSTRAINT `fk_threads_users`
@@ -37,10 +40,21 @@ CREATE TABLE IF NOT EXISTS `ls_chat`.`messages`(
     `id` VARCHAR(36) NOT NULL COMMENT 'id',
     `message` VARCHAR(150) NOT NULL COMMENT '投稿本文',
     `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+    `edited_at` DATETIME COMMENT '最終編集日時',
+    `deleted_at` DATETIME COMMENT '削除日時。削除後は本文を空にして位置だけ残す',
     `grade` INTEGER UNSIGNED NOT NULL DEFAULT 0 COMMENT '発言のグレード' ,
     `user_id` VARCHAR(64) NOT NULL COMMENT 'ユーザID',
     `thread_id` VARCHAR(36) NOT NULL COMMENT 'スレッドID',
+    `parent_id` VARCHAR(36) COMMENT '返信元メッセージID',
     PRIMARY KEY (`id`),
+    INDEX `idx_messages_thread_created` (`thread_id`, `created_at`, `id`),
+    INDEX `idx_messages_parent_created` (`parent_id`, `created_at`, `id`),
+    FULLTEXT INDEX `ft_messages_message` (`message`) WITH PARSER ngram,
+    CONSTRAINT `fk_messages_parent`
+        FOREIGN KEY (`parent_id`)
+        REFERENCES `ls_chat`.`messages` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
     CONSTRAINT `fk_messages_users`
         FOREIGN KEY (`user_id`)
         REFERENCES `ls_chat`.`users` (`id`)
@@ -54,6 +68,49 @@ CREATE TABLE IF NOT EXISTS `ls_chat`.`messages`(
 )
 COMMENT = '投稿メッセージ';
 
+CREATE TABLE IF NOT EXISTS `ls_chat`.`message_revisions`(
+    `id` VARCHAR(36) NOT NULL COMMENT 'id',
+    `message_id` VARCHAR(36) NOT NULL COMMENT 'メッセージID',
+    `message` VARCHAR(150) NOT NULL COMMENT '編集前の本文',
+    `edited_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '編集日時',
+    PRIMARY KEY (`id`),
+    INDEX `idx_message_revisions_message` (`message_id`, `edited_at`),
+    CONSTRAINT `fk_message_revisions_messages`
+        FOREIGN KEY (`message_id`)
+        REFERENCES `ls_chat`.`messages` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION
+)
+COMMENT = 'メッセージの編集履歴';
+
+CREATE TABLE IF NOT EXISTS `ls_chat`.`message_deletions`(
+    `id` VARCHAR(36) NOT NULL COMMENT 'id',
+    `message_id` VARCHAR(36) NOT NULL COMMENT 'メッセージID',
+    `thread_id` VARCHAR(36) NOT NULL COMMENT 'スレッドID',
+    `author_id` VARCHAR(36) NOT NULL COMMENT '投稿者のユーザID',
+    `deleted_by` VARCHAR(36) NOT NULL COMMENT '削除したユーザID',
+    `reason` VARCHAR(150) NOT NULL DEFAULT '' COMMENT '削除理由',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '削除日時',
+    PRIMARY KEY (`id`),
+    INDEX `idx_message_deletions_thread` (`thread_id`, `created_at`),
+    CONSTRAINT `fk_message_deletions_messages`
+        FOREIGN KEY (`message_id`)
+        REFERENCES `ls_chat`.`messages` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT `fk_message_deletions_threads`
+        FOREIGN KEY (`thread_id`)
+        REFERENCES `ls_chat`.`threads` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT `fk_message_deletions_users`
+        FOREIGN KEY (`deleted_by`)
+        REFERENCES `ls_chat`.`users` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION
+)
+COMMENT = 'メッセージ削除の監査ログ';
+
 CREATE TABLE IF NOT EXISTS `ls_chat`.`categories`(
     `id` VARCHAR(36) PRIMARY KEY NOT NULL COMMENT'id',
     `category` VARCHAR(8) NOT NULL COMMENT '大枠名',
@@ -81,6 +138,9 @@ CREATE TABLE IF NOT EXISTS `ls_chat`.`archives`(
     `is_public` TINYINT NOT NULL DEFAULT 1 COMMENT '公開範囲',
     `password` VARCHAR(70) NOT NULL COMMENT 'パスワード' ,
     `thread_id` VARCHAR(36) NOT NULL COMMENT 'スレッドID',
+    `status` VARCHAR(16) NOT NULL DEFAULT 'pending' COMMENT '書き出しの状態',
+    `created_by` VARCHAR(36) NOT NULL COMMENT '書き出したユーザID',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
     CONSTRAINT `fk_archives_threads`
         FOREIGN KEY (`thread_id`)
         REFERENCES `ls_chat`.`threads` (`id`)
@@ -140,7 +200,8 @@ CREATE TABLE IF NOT EXISTS `ls_chat`.`users_threads`(
     `id` VARCHAR(36) NOT NULL COMMENT 'id',
     `user_id` VARCHAR(36) NOT NULL COMMENT 'ユーザーID',
     `thread_id` VARCHAR(36) NOT NULL COMMENT 'スレッドID',
-    `is_admin` TINYINT NOT NULL DEFAULT 0 COMMENT 'スレッドの管理者判断',
+    `role` VARCHAR(16) NOT NULL DEFAULT 'member' COMMENT 'スレッドでの役割(owner, moderator, member, read_only)',
+    `joined_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '参加日時。ownerが抜けたときの後任を決めるのに使う',
     PRIMARY KEY (`id`),
     CONSTRAINT 
         FOREIGN KEY (`user_id`)
@@ -157,25 +218,241 @@ CREATE TABLE IF NOT EXISTS `ls_chat`.`users_threads`(
 )
 COMMENT='ユーザーのスレッド';
 
-CREATE TABLE IF NOT EXISTS `ls_chat`.`users_favorites`(
+CREATE TABLE IF NOT EXISTS `ls_chat`.`thread_bans`(
+    `id` VARCHAR(36) NOT NULL COMMENT 'id',
+    `thread_id` VARCHAR(36) NOT NULL COMMENT 'スレッドID',
+    `user_id` VARCHAR(36) NOT NULL COMMENT 'BANされたユーザーID',
+    `banned_by` VARCHAR(36) NOT NULL COMMENT 'BANしたユーザーID',
+    `reason` VARCHAR(150) NOT NULL DEFAULT '' COMMENT '理由',
+    `expires_at` DATETIME COMMENT '解除日時。NULLなら無期限',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+    PRIMARY KEY (`id`),
+    CONSTRAINT
+        FOREIGN KEY (`thread_id`)
+        REFERENCES `ls_chat`.`threads` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT
+        FOREIGN KEY (`user_id`)
+        REFERENCES `ls_chat`.`users` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT
+        FOREIGN KEY (`banned_by`)
+        REFERENCES `ls_chat`.`users` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT `unique_thread_ban`
+        UNIQUE (`thread_id`,`user_id`)
+)
+COMMENT='スレッドのBANリスト';
+
+CREATE TABLE IF NOT EXISTS `ls_chat`.`thread_invites`(
+    `id` VARCHAR(36) NOT NULL COMMENT 'id',
+    `thread_id` VARCHAR(36) NOT NULL COMMENT 'スレッドID',
+    `code` VARCHAR(32) NOT NULL UNIQUE COMMENT '招待コード',
+    `created_by` VARCHAR(36) NOT NULL COMMENT '招待したユーザーID',
+    `invitee_id` VARCHAR(36) COMMENT '招待されたユーザーID。NULLなら誰でも使える',
+    `max_uses` INT NOT NULL DEFAULT 0 COMMENT '使用回数の上限。0なら無制限',
+    `uses` INT NOT NULL DEFAULT 0 COMMENT '使用回数',
+    `expires_at` DATETIME COMMENT '期限。NULLなら無期限',
+    `revoked_at` DATETIME COMMENT '取り消し日時',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+    PRIMARY KEY (`id`),
+    CONSTRAINT
+        FOREIGN KEY (`thread_id`)
+        REFERENCES `ls_chat`.`threads` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT
+        FOREIGN KEY (`created_by`)
+        REFERENCES `ls_chat`.`users` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT
+        FOREIGN KEY (`invitee_id`)
+        REFERENCES `ls_chat`.`users` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION
+)
+COMMENT='スレッドの招待';
+
+CREATE TABLE IF NOT EXISTS `ls_chat`.`thread_invite_uses`(
+    `id` VARCHAR(36) NOT NULL COMMENT 'id',
+    `invite_id` VARCHAR(36) NOT NULL COMMENT '招待ID',
+    `thread_id` VARCHAR(36) NOT NULL COMMENT 'スレッドID',
+    `user_id` VARCHAR(36) NOT NULL COMMENT '参加したユーザーID',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+    PRIMARY KEY (`id`),
+    INDEX `idx_thread_invite_uses_thread` (`thread_id`, `created_at`),
+    CONSTRAINT
+        FOREIGN KEY (`invite_id`)
+        REFERENCES `ls_chat`.`thread_invites` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT
+        FOREIGN KEY (`thread_id`)
+        REFERENCES `ls_chat`.`threads` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT
+        FOREIGN KEY (`user_id`)
+        REFERENCES `ls_chat`.`users` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION
+)
+COMMENT='招待の使用履歴';
+
+CREATE TABLE IF NOT EXISTS `ls_chat`.`thread_join_requests`(
+    `id` VARCHAR(36) NOT NULL COMMENT 'id',
+    `thread_id` VARCHAR(36) NOT NULL COMMENT 'スレッドID',
+    `user_id` VARCHAR(36) NOT NULL COMMENT '申請したユーザーID',
+    `status` VARCHAR(16) NOT NULL DEFAULT 'pending' COMMENT '状態(pending, approved, rejected)',
+    `decided_by` VARCHAR(36) COMMENT '承認か却下したユーザーID',
+    `decided_at` DATETIME COMMENT '承認か却下した日時',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+    PRIMARY KEY (`id`),
+    INDEX `idx_thread_join_requests_status` (`thread_id`, `status`),
+    CONSTRAINT
+        FOREIGN KEY (`thread_id`)
+        REFERENCES `ls_chat`.`threads` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT
+        FOREIGN KEY (`user_id`)
+        REFERENCES `ls_chat`.`users` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT
+        FOREIGN KEY (`decided_by`)
+        REFERENCES `ls_chat`.`users` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT `unique_thread_join_request`
+        UNIQUE (`thread_id`,`user_id`)
+)
+COMMENT='スレッドへの参加申請';
+
+CREATE TABLE IF NOT EXISTS `ls_chat`.`thread_waitlists`(
+    `id` VARCHAR(36) NOT NULL COMMENT 'id',
+    `thread_id` VARCHAR(36) NOT NULL COMMENT 'スレッドID',
+    `user_id` VARCHAR(36) NOT NULL COMMENT '待っているユーザーID',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+    PRIMARY KEY (`id`),
+    INDEX `idx_thread_waitlists_order` (`thread_id`, `created_at`),
+    CONSTRAINT
+        FOREIGN KEY (`thread_id`)
+        REFERENCES `ls_chat`.`threads` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT
+        FOREIGN KEY (`user_id`)
+        REFERENCES `ls_chat`.`users` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT `unique_thread_waitlist`
+        UNIQUE (`thread_id`,`user_id`)
+)
+COMMENT='満員のスレッドの参加待ち';
+
+-- users_favoritesから置き換え。既存のDBはdb/mysql/migration/favorites_to_reactions.sqlで移行する
+CREATE TABLE IF NOT EXISTS `ls_chat`.`message_reactions`(
     `id` VARCHAR(36) NOT NULL COMMENT 'id',
     `user_id` VARCHAR(36) NOT NULL COMMENT 'ユーザーID',
     `message_id` VARCHAR(36) NOT NULL COMMENT 'メッセージID',
+    `reaction` VARCHAR(32) NOT NULL COMMENT '絵文字かショートコード',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
     PRIMARY KEY (`id`),
-    CONSTRAINT 
+    CONSTRAINT
+        FOREIGN KEY (`user_id`)
+        REFERENCES `ls_chat`.`users` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT
+        FOREIGN KEY (`message_id`)
+        REFERENCES `ls_chat`.`messages` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT `unique_message_user_reaction`
+        UNIQUE (`message_id`,`user_id`,`reaction`)
+)
+COMMENT='メッセージへのリアクション';
+
+CREATE TABLE IF NOT EXISTS `ls_chat`.`message_mentions`(
+    `id` VARCHAR(36) NOT NULL COMMENT 'id',
+    `message_id` VARCHAR(36) NOT NULL COMMENT 'メッセージID',
+    `user_id` VARCHAR(36) NOT NULL COMMENT 'メンションされたユーザーID',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+    PRIMARY KEY (`id`),
+    INDEX `idx_message_mentions_user_created` (`user_id`, `created_at`),
+    CONSTRAINT
+        FOREIGN KEY (`message_id`)
+        REFERENCES `ls_chat`.`messages` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT
         FOREIGN KEY (`user_id`)
         REFERENCES `ls_chat`.`users` (`id`)
         ON DELETE NO ACTION
         ON UPDATE NO ACTION,
+    CONSTRAINT `unique_message_user_mention`
+        UNIQUE (`message_id`,`user_id`)
+)
+COMMENT='メッセージ中のメンション';
+
+CREATE TABLE IF NOT EXISTS `ls_chat`.`pinned_messages`(
+    `id` VARCHAR(36) NOT NULL COMMENT 'id',
+    `thread_id` VARCHAR(36) NOT NULL COMMENT 'スレッドID',
+    `message_id` VARCHAR(36) NOT NULL COMMENT 'メッセージID',
+    `user_id` VARCHAR(36) NOT NULL COMMENT 'ピン留めしたユーザーID',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+    PRIMARY KEY (`id`),
+    CONSTRAINT
+        FOREIGN KEY (`thread_id`)
+        REFERENCES `ls_chat`.`threads` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
     CONSTRAINT
         FOREIGN KEY (`message_id`)
         REFERENCES `ls_chat`.`messages` (`id`)
         ON DELETE NO ACTION
         ON UPDATE NO ACTION,
-    CONSTRAINT `unique_user_id_message_id`
-        UNIQUE (`user_id`,`message_id`)
+    CONSTRAINT
+        FOREIGN KEY (`user_id`)
+        REFERENCES `ls_chat`.`users` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT `unique_thread_message_pin`
+        UNIQUE (`thread_id`,`message_id`)
 )
-COMMENT='ユーザーのいいね';
+COMMENT='スレッドにピン留めされたメッセージ';
+
+CREATE TABLE IF NOT EXISTS `ls_chat`.`read_markers`(
+    `id` VARCHAR(36) NOT NULL COMMENT 'id',
+    `user_id` VARCHAR(36) NOT NULL COMMENT 'ユーザーID',
+    `thread_id` VARCHAR(36) NOT NULL COMMENT 'スレッドID',
+    `message_id` VARCHAR(36) NOT NULL COMMENT '最後に読んだメッセージID',
+    `read_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '既読日時',
+    PRIMARY KEY (`id`),
+    CONSTRAINT
+        FOREIGN KEY (`user_id`)
+        REFERENCES `ls_chat`.`users` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT
+        FOREIGN KEY (`thread_id`)
+        REFERENCES `ls_chat`.`threads` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT
+        FOREIGN KEY (`message_id`)
+        REFERENCES `ls_chat`.`messages` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT `unique_user_thread_read`
+        UNIQUE (`user_id`,`thread_id`)
+)
+COMMENT='既読位置';
 
 CREATE TABLE IF NOT EXISTS `ls_chat`.`evaluation_scores`(
     `id` VARCHAR(36) PRIMARY KEY COMMENT 'id',
@@ -217,3 +494,7 @@ CREATE TABLE IF NOT EXISTS `ls_chat`.`users_followers`(
 )
 COMMENT = 'フォロワー';
 	
+
+-- 削除されたユーザ。退会したユーザのメッセージなどはこのユーザに付け替える。パスワードが空なのでログインできない
+INSERT IGNORE INTO `ls_chat`.`users` (`id`,`user_id`,`name`,`mail`,`image`,`profile`,`password`)
+    VALUES ('00000000-0000-0000-0000-000000000000','deleted_user','deleted user','','','','');
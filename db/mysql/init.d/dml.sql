@@ -87,16 +87,16 @@ INSERT INTO `ls_chat`.`users_tags` (id,user_id,tag_id) VALUES ('11111111-1111-11
 -- INSERT INTO `ls_chat`.`users_tags` (id,user_id,tag_id) VALUES ('66666666-6666-6666-6666-666666666666','66666666-6666-6666-6666-666666666666','66666666-6666-6666-6666-666666666666');
 
 -- users_threads
-INSERT INTO `ls_chat`.`users_threads`(`id`,`user_id`,`thread_id`,`is_admin`) VALUES ("11111111-1111-1111-1111-111111111111","11111111-1111-1111-1111-111111111111","11111111-1111-1111-1111-111111111111",1);
+INSERT INTO `ls_chat`.`users_threads`(`id`,`user_id`,`thread_id`,`role`) VALUES ("11111111-1111-1111-1111-111111111111","11111111-1111-1111-1111-111111111111","11111111-1111-1111-1111-111111111111","owner");
 INSERT INTO `ls_chat`.`users_threads`(`id`,`user_id`,`thread_id`) VALUES ("22222222-2222-2222-2222-222222222222","22222222-2222-2222-2222-222222222222","22222222-2222-2222-2222-222222222222");
 
--- users_favorites
-INSERT INTO `ls_chat`.`users_favorites` (id,user_id,message_id) VALUES ('11111111-1111-1111-1111-111111111111','11111111-1111-1111-1111-111111111111','11111111-1111-1111-1111-111111111111');
-INSERT INTO `ls_chat`.`users_favorites` (id,user_id,message_id) VALUES ('22222222-2222-2222-2222-222222222222','22222222-2222-2222-2222-222222222222','22222222-2222-2222-2222-222222222222');
--- INSERT INTO `ls_chat`.`users_favorites` (id,user_id,message_id) VALUES ('33333333-3333-3333-3333-333333333333','33333333-3333-3333-3333-333333333333','33333333-3333-3333-3333-333333333333');
--- INSERT INTO `ls_chat`.`users_favorites` (id,user_id,message_id) VALUES ('44444444-4444-4444-4444-444444444444','44444444-4444-4444-4444-444444444444','44444444-4444-4444-4444-444444444444');
--- INSERT INTO `ls_chat`.`users_favorites` (id,user_id,message_id) VALUES ('55555555-5555-5555-5555-555555555555','55555555-5555-5555-5555-555555555555','55555555-5555-5555-5555-555555555555');
--- INSERT INTO `ls_chat`.`users_favorites` (id,user_id,message_id) VALUES ('66666666-6666-6666-6666-666666666666','66666666-6666-6666-6666-666666666666','66666666-6666-6666-6666-666666666666');
+-- message_reactions
+INSERT INTO `ls_chat`.`message_reactions` (id,user_id,message_id,reaction) VALUES ('11111111-1111-1111-1111-111111111111','11111111-1111-1111-1111-111111111111','11111111-1111-1111-1111-111111111111','heart');
+INSERT INTO `ls_chat`.`message_reactions` (id,user_id,message_id,reaction) VALUES ('22222222-2222-2222-2222-222222222222','22222222-2222-2222-2222-222222222222','22222222-2222-2222-2222-222222222222','heart');
+-- INSERT INTO `ls_chat`.`message_reactions` (id,user_id,message_id,reaction) VALUES ('33333333-3333-3333-3333-333333333333','33333333-3333-3333-3333-333333333333','33333333-3333-3333-3333-333333333333','heart');
+-- INSERT INTO `ls_chat`.`message_reactions` (id,user_id,message_id,reaction) VALUES ('44444444-4444-4444-4444-444444444444','44444444-4444-4444-4444-444444444444','44444444-4444-4444-4444-444444444444','heart');
+-- INSERT INTO `ls_chat`.`message_reactions` (id,user_id,message_id,reaction) VALUES ('55555555-5555-5555-5555-555555555555','55555555-5555-5555-5555-555555555555','55555555-5555-5555-5555-555555555555','heart');
+-- INSERT INTO `ls_chat`.`message_reactions` (id,user_id,message_id,reaction) VALUES ('66666666-6666-6666-6666-666666666666','66666666-6666-6666-6666-666666666666','66666666-6666-6666-6666-666666666666','heart');
 
 -- threads_tags
 INSERT INTO `ls_chat`.`threads_tags`(`id`,`thread_id`,`tag_id`) VALUES ("11111111-1111-1111-1111-111111111111","11111111-1111-1111-1111-111111111111","11111111-1111-1111-1111-111111111111");
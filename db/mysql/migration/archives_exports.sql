@@ -0,0 +1,5 @@
+-- archivesをスレッドの書き出しに使うため、状態と作成者を追加する
+ALTER TABLE `ls_chat`.`archives`
+    ADD `status` VARCHAR(16) NOT NULL DEFAULT 'pending' COMMENT '書き出しの状態' AFTER `thread_id`,
+    ADD `created_by` VARCHAR(36) NOT NULL COMMENT '書き出したユーザID' AFTER `status`,
+    ADD `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時' AFTER `created_by`;
@@ -0,0 +1,33 @@
+-- users_favoritesをmessage_reactionsに移行する
+-- いいねはデフォルトのリアクション(constants.DefaultReaction)として扱う
+CREATE TABLE IF NOT EXISTS `ls_chat`.`message_reactions`(
+    `id` VARCHAR(36) NOT NULL COMMENT 'id',
+    `user_id` VARCHAR(36) NOT NULL COMMENT 'ユーザーID',
+    `message_id` VARCHAR(36) NOT NULL COMMENT 'メッセージID',
+    `reaction` VARCHAR(32) NOT NULL COMMENT '絵文字かショートコード',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+    PRIMARY KEY (`id`),
+    CONSTRAINT
+        FOREIGN KEY (`user_id`)
+        REFERENCES `ls_chat`.`users` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT
+        FOREIGN KEY (`message_id`)
+        REFERENCES `ls_chat`.`messages` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT `unique_message_user_reaction`
+        UNIQUE (`message_id`,`user_id`,`reaction`)
+)
+COMMENT='メッセージへのリアクション';
+
+START TRANSACTION;
+
+INSERT IGNORE INTO `ls_chat`.`message_reactions` (id, user_id, message_id, reaction)
+SELECT id, user_id, message_id, 'heart'
+FROM `ls_chat`.`users_favorites`;
+
+COMMIT;
+
+DROP TABLE `ls_chat`.`users_favorites`;
@@ -0,0 +1,31 @@
+-- messagesに削除日時を追加し、管理者による削除の監査ログのテーブルを作る
+ALTER TABLE `ls_chat`.`messages`
+    ADD `deleted_at` DATETIME COMMENT '削除日時。削除後は本文を空にして位置だけ残す' AFTER `edited_at`;
+
+CREATE TABLE IF NOT EXISTS `ls_chat`.`message_deletions`(
+    `id` VARCHAR(36) NOT NULL COMMENT 'id',
+    `message_id` VARCHAR(36) NOT NULL COMMENT 'メッセージID',
+    `thread_id` VARCHAR(36) NOT NULL COMMENT 'スレッドID',
+    `author_id` VARCHAR(36) NOT NULL COMMENT '投稿者のユーザID',
+    `deleted_by` VARCHAR(36) NOT NULL COMMENT '削除したユーザID',
+    `reason` VARCHAR(150) NOT NULL DEFAULT '' COMMENT '削除理由',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '削除日時',
+    PRIMARY KEY (`id`),
+    INDEX `idx_message_deletions_thread` (`thread_id`, `created_at`),
+    CONSTRAINT `fk_message_deletions_messages`
+        FOREIGN KEY (`message_id`)
+        REFERENCES `ls_chat`.`messages` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT `fk_message_deletions_threads`
+        FOREIGN KEY (`thread_id`)
+        REFERENCES `ls_chat`.`threads` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION,
+    CONSTRAINT `fk_message_deletions_users`
+        FOREIGN KEY (`deleted_by`)
+        REFERENCES `ls_chat`.`users` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION
+)
+COMMENT = 'メッセージ削除の監査ログ';
@@ -0,0 +1,18 @@
+-- messagesに最終編集日時を追加し、編集履歴のテーブルを作る
+ALTER TABLE `ls_chat`.`messages`
+    ADD `edited_at` DATETIME COMMENT '最終編集日時' AFTER `created_at`;
+
+CREATE TABLE IF NOT EXISTS `ls_chat`.`message_revisions`(
+    `id` VARCHAR(36) NOT NULL COMMENT 'id',
+    `message_id` VARCHAR(36) NOT NULL COMMENT 'メッセージID',
+    `message` VARCHAR(150) NOT NULL COMMENT '編集前の本文',
+    `edited_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '編集日時',
+    PRIMARY KEY (`id`),
+    INDEX `idx_message_revisions_message` (`message_id`, `edited_at`),
+    CONSTRAINT `fk_message_revisions_messages`
+        FOREIGN KEY (`message_id`)
+        REFERENCES `ls_chat`.`messages` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION
+)
+COMMENT = 'メッセージの編集履歴';
@@ -0,0 +1,3 @@
+-- メッセージ検索用のFULLTEXT索引を追加する。日本語も引けるようにngramパーサを使う
+ALTER TABLE `ls_chat`.`messages`
+    ADD FULLTEXT INDEX `ft_messages_message` (`message`) WITH PARSER ngram;
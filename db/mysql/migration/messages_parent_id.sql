@@ -0,0 +1,9 @@
+-- messagesに返信元のメッセージIDを追加する
+ALTER TABLE `ls_chat`.`messages`
+    ADD `parent_id` VARCHAR(36) COMMENT '返信元メッセージID' AFTER `thread_id`,
+    ADD INDEX `idx_messages_parent_created` (`parent_id`, `created_at`, `id`),
+    ADD CONSTRAINT `fk_messages_parent`
+        FOREIGN KEY (`parent_id`)
+        REFERENCES `ls_chat`.`messages` (`id`)
+        ON DELETE NO ACTION
+        ON UPDATE NO ACTION;
@@ -0,0 +1,3 @@
+-- スレッドのメッセージをカーソルでページングするための索引を追加する
+ALTER TABLE `ls_chat`.`messages`
+    ADD INDEX `idx_messages_thread_created` (`thread_id`, `created_at`, `id`);
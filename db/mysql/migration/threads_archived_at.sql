@@ -0,0 +1,3 @@
+-- threadsにアーカイブした日時を追加する
+ALTER TABLE `ls_chat`.`threads`
+    ADD `archived_at` DATETIME COMMENT 'アーカイブした日時。NULLでなければ読み取り専用' AFTER `join_policy`;
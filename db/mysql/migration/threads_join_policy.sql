@@ -0,0 +1,8 @@
+-- threadsに参加方針を追加する
+-- 既存の非公開スレッドは招待制にする
+ALTER TABLE `ls_chat`.`threads`
+    ADD `join_policy` VARCHAR(16) NOT NULL DEFAULT 'open' COMMENT '参加方針(open, request, invite_only)' AFTER `is_public`;
+
+UPDATE `ls_chat`.`threads`
+SET join_policy = 'invite_only'
+WHERE is_public = 0;
@@ -0,0 +1,7 @@
+-- 退会の猶予期間のために削除予定日時を追加する
+ALTER TABLE `ls_chat`.`users`
+    ADD `deletion_scheduled_at` DATETIME COMMENT '削除予定日時。NULLでなければ削除待ち' AFTER `password`;
+
+-- 削除されたユーザ。退会したユーザのメッセージなどはこのユーザに付け替える。パスワードが空なのでログインできない
+INSERT IGNORE INTO `ls_chat`.`users` (`id`,`user_id`,`name`,`mail`,`image`,`profile`,`password`)
+    VALUES ('00000000-0000-0000-0000-000000000000','deleted_user','deleted user','','','','');
@@ -0,0 +1,7 @@
+-- users_threadsに参加日時を追加する。既存の行は参加日時がわからないのでスレッドの作成日時にする
+ALTER TABLE `ls_chat`.`users_threads`
+    ADD `joined_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '参加日時。ownerが抜けたときの後任を決めるのに使う' AFTER `role`;
+
+UPDATE `ls_chat`.`users_threads` AS ut
+    INNER JOIN `ls_chat`.`threads` AS t ON t.id = ut.thread_id
+SET ut.joined_at = COALESCE(t.created_at, ut.joined_at);
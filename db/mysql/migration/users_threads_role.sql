@@ -0,0 +1,15 @@
+-- users_threads.is_adminをroleに置き換える
+-- スレッドの作成者はowner、それ以外の管理者はmoderatorにする
+ALTER TABLE `ls_chat`.`users_threads`
+    ADD `role` VARCHAR(16) NOT NULL DEFAULT 'member' COMMENT 'スレッドでの役割(owner, moderator, member, read_only)' AFTER `is_admin`;
+
+UPDATE `ls_chat`.`users_threads` AS ut
+    INNER JOIN `ls_chat`.`threads` AS t ON t.id = ut.thread_id
+SET ut.role = CASE
+    WHEN ut.user_id = t.user_id THEN 'owner'
+    WHEN ut.is_admin = 1 THEN 'moderator'
+    ELSE 'member'
+END;
+
+ALTER TABLE `ls_chat`.`users_threads`
+    DROP COLUMN `is_admin`;